@@ -15,6 +15,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -28,5 +30,15 @@ func main() {
 	pflag.CommandLine = flags
 
 	root := cmd.NewPGOCommand(os.Stdin, os.Stdout, os.Stderr)
-	cobra.CheckErr(root.Execute())
+	err := root.Execute()
+
+	// Some commands, such as "support export", distinguish partial success
+	// from failure with their exit code.
+	var exit *cmd.ExitError
+	if errors.As(err, &exit) {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(exit.Code)
+	}
+
+	cobra.CheckErr(err)
 }
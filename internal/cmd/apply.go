@@ -0,0 +1,74 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// applyClusterIntent applies the changes of modify to the fields of the
+// PostgresCluster called name that are managed by this plugin. The cluster
+// is fetched to (1) let modify see its current spec and (2) extract the
+// fields managed by this plugin into intent. When the change is accepted, it
+// prints message after the cluster, such as "postgresclusters/hippo user app created".
+func applyClusterIntent(
+	ctx context.Context, config *internal.Config, name, message string,
+	modify func(cluster, intent *unstructured.Unstructured) error,
+) error {
+	mapping, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	cluster, err := client.Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	intent := new(unstructured.Unstructured)
+	if err := internal.ExtractFieldsInto(cluster, intent, config.Patch.FieldManager); err != nil {
+		return err
+	}
+	if err := modify(cluster, intent); err != nil {
+		return err
+	}
+
+	patch, err := intent.MarshalJSON()
+
+	if err == nil {
+		_, err = client.Namespace(namespace).Patch(ctx,
+			name, types.ApplyPatchType, patch,
+			config.Patch.PatchOptions(metav1.PatchOptions{}))
+	}
+
+	if err == nil {
+		fmt.Fprintf(config.Out, "%s/%s %s\n", mapping.Resource.Resource, name, message)
+	}
+
+	return err
+}
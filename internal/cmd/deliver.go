@@ -0,0 +1,420 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tools that can encrypt a support export archive
+const (
+	encryptAge = "age"
+	encryptGPG = "gpg"
+)
+
+// archiveEncryption encrypts the archive, as it is written, to the public keys
+// of its recipients. The archive is piped through the age or gpg command so
+// that it is never written to disk unencrypted.
+type archiveEncryption struct {
+	tool       string
+	path       string
+	recipients []string
+}
+
+// newArchiveEncryption returns the encryption set by the '--encrypt' and
+// '--recipient' flags, or nil when the archive is not encrypted.
+func newArchiveEncryption(tool string, recipients []string) (*archiveEncryption, error) {
+	switch {
+	case tool == "" && len(recipients) > 0:
+		return nil, fmt.Errorf("--recipient requires --encrypt")
+	case tool == "":
+		return nil, nil
+	case tool != encryptAge && tool != encryptGPG:
+		return nil, fmt.Errorf("invalid encrypt %q: types supported: %s,%s", tool, encryptAge, encryptGPG)
+	case len(recipients) == 0:
+		return nil, fmt.Errorf("--encrypt=%s requires at least one --recipient", tool)
+	}
+
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return nil, fmt.Errorf("--encrypt=%s requires the %s command: %w", tool, tool, err)
+	}
+	return &archiveEncryption{tool: tool, path: path, recipients: recipients}, nil
+}
+
+// extension is appended to the name of an encrypted archive.
+func (e *archiveEncryption) extension() string { return "." + e.tool }
+
+// args returns the arguments that encrypt stdin to stdout. A recipient that
+// names a file is read as a file of public keys.
+func (e *archiveEncryption) args() []string {
+	var args []string
+	if e.tool == encryptGPG {
+		args = append(args, "--batch", "--yes", "--no-tty", "--trust-model=always", "--encrypt")
+	} else {
+		args = append(args, "--encrypt")
+	}
+
+	for _, recipient := range e.recipients {
+		_, err := os.Stat(recipient)
+		switch {
+		case err == nil && e.tool == encryptGPG:
+			args = append(args, "--recipient-file", recipient)
+		case err == nil:
+			args = append(args, "--recipients-file", recipient)
+		default:
+			args = append(args, "--recipient", recipient)
+		}
+	}
+
+	if e.tool == encryptGPG {
+		args = append(args, "--output", "-")
+	}
+	return args
+}
+
+// writer starts the encryption command and returns a writer to its stdin. The
+// encrypted archive is written to w. Close waits for the command to finish.
+func (e *archiveEncryption) writer(ctx context.Context, w io.Writer) (io.WriteCloser, error) {
+	return startCommandWriter(ctx, e.tool, e.path, e.args(), w)
+}
+
+// startCommandWriter starts a command that filters its stdin to w, such as a
+// compression or encryption tool, and returns a writer to its stdin.
+func startCommandWriter(ctx context.Context, tool, path string, args []string, w io.Writer,
+) (io.WriteCloser, error) {
+	// #nosec G204 -- The command is one of the tools above and not user input.
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = w
+
+	writer := &commandWriter{cmd: cmd, tool: tool}
+	cmd.Stderr = &writer.stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err == nil {
+		writer.WriteCloser = stdin
+		err = cmd.Start()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not start %s: %w", tool, err)
+	}
+	return writer, nil
+}
+
+// commandWriter writes to the stdin of a running command.
+type commandWriter struct {
+	io.WriteCloser
+	cmd    *exec.Cmd
+	tool   string
+	stderr bytes.Buffer
+	closed bool
+}
+
+// Close closes stdin and waits for the command to write the rest of its
+// output. Its stderr is part of the error when it fails.
+func (w *commandWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	_ = w.WriteCloser.Close()
+	if err := w.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", w.tool, err, strings.TrimSpace(w.stderr.String()))
+	}
+	return nil
+}
+
+// archiveUploader sends a finished archive somewhere other than the local
+// disk. It returns where the archive can be found, without any credentials.
+type archiveUploader interface {
+	upload(ctx context.Context, name string, body io.ReadSeeker, size int64) (string, error)
+}
+
+// newArchiveUploader returns the destination set by the '--upload' flag, or
+// nil when the archive is not uploaded. Targets are either a pre-signed HTTP
+// URL or an S3 bucket, as "s3://BUCKET/PREFIX", at an S3-compatible endpoint.
+func newArchiveUploader(target, endpoint string, getenv func(string) string) (archiveUploader, error) {
+	if target == "" {
+		if endpoint != "" {
+			return nil, fmt.Errorf("--upload-endpoint requires --upload")
+		}
+		return nil, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload %q: %w", redactURL(target), err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if endpoint != "" {
+			return nil, fmt.Errorf("--upload-endpoint can only be used with an s3:// upload")
+		}
+		return &presignedUploader{url: target, client: http.DefaultClient}, nil
+
+	case "s3":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid upload %q: missing bucket", target)
+		}
+
+		s3 := &s3Uploader{
+			bucket: u.Host,
+			prefix: strings.Trim(u.Path, "/"),
+			credentials: s3Credentials{
+				accessKeyID:     getenv("AWS_ACCESS_KEY_ID"),
+				secretAccessKey: getenv("AWS_SECRET_ACCESS_KEY"),
+				sessionToken:    getenv("AWS_SESSION_TOKEN"),
+			},
+			region: getenv("AWS_REGION"),
+			client: http.DefaultClient,
+			now:    time.Now,
+		}
+		if s3.region == "" {
+			s3.region = getenv("AWS_DEFAULT_REGION")
+		}
+		if s3.region == "" {
+			s3.region = "us-east-1"
+		}
+		if s3.credentials.accessKeyID == "" || s3.credentials.secretAccessKey == "" {
+			return nil, fmt.Errorf("an s3:// upload requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+
+		s3.endpoint = strings.TrimRight(endpoint, "/")
+		if s3.endpoint == "" {
+			s3.endpoint = "https://s3." + s3.region + ".amazonaws.com"
+		}
+		if e, err := url.Parse(s3.endpoint); err != nil || e.Host == "" ||
+			(e.Scheme != "http" && e.Scheme != "https") {
+			return nil, fmt.Errorf("invalid upload-endpoint %q: must be an http or https URL", endpoint)
+		}
+		return s3, nil
+	}
+
+	return nil, fmt.Errorf("invalid upload %q: must be an s3:// or https:// URL", redactURL(target))
+}
+
+// redactURL removes the query of a URL, which holds the signature of a
+// pre-signed URL, so that it can be printed.
+func redactURL(s string) string {
+	if i := strings.IndexAny(s, "?#"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// uploadError returns an error for a response that is not successful.
+func uploadError(response *http.Response) error {
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	b, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	return fmt.Errorf("upload failed: %s: %s", response.Status, strings.TrimSpace(string(b)))
+}
+
+// presignedUploader sends the archive to a URL that is already signed, such as
+// one given by Crunchy Data Support.
+type presignedUploader struct {
+	url    string
+	client *http.Client
+}
+
+func (p *presignedUploader) upload(ctx context.Context, _ string, body io.ReadSeeker, size int64) (string, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, p.url, io.NopCloser(body))
+	if err != nil {
+		return "", err
+	}
+	request.ContentLength = size
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		// The error includes the URL and its signature.
+		var urlError *url.Error
+		if errors.As(err, &urlError) {
+			urlError.URL = redactURL(urlError.URL)
+		}
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	return redactURL(p.url), uploadError(response)
+}
+
+// s3Credentials are the keys used to sign requests to S3.
+type s3Credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// s3Uploader puts the archive in a bucket of AWS S3 or another service with
+// the same API. Requests use path-style addressing and Signature Version 4.
+type s3Uploader struct {
+	endpoint    string
+	bucket      string
+	prefix      string
+	region      string
+	credentials s3Credentials
+	client      *http.Client
+	now         func() time.Time
+}
+
+func (s *s3Uploader) upload(ctx context.Context, name string, body io.ReadSeeker, size int64) (string, error) {
+	key := name
+	if s.prefix != "" {
+		key = s.prefix + "/" + name
+	}
+
+	// The payload is signed, so read it once to compute its checksum.
+	sum := sha256.New()
+	if _, err := io.Copy(sum, body); err != nil {
+		return "", err
+	}
+	if _, err := body.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		s.endpoint+"/"+s3Escape(s.bucket+"/"+key), io.NopCloser(body))
+	if err != nil {
+		return "", err
+	}
+	request.ContentLength = size
+	request.Header.Set("Content-Type", "application/octet-stream")
+	signS3Request(request, hex.EncodeToString(sum.Sum(nil)), s.credentials, s.region, s.now())
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("upload failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	return "s3://" + s.bucket + "/" + key, uploadError(response)
+}
+
+// s3Escape encodes every byte of a path except unreserved characters and
+// slashes, as required by Signature Version 4.
+func s3Escape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// signS3Request adds the headers and Authorization of AWS Signature Version 4
+// to request. Every header already set on request is signed.
+// - https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func signS3Request(request *http.Request, payloadHash string,
+	credentials s3Credentials, region string, now time.Time,
+) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + region + "/s3/aws4_request"
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if credentials.sessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", credentials.sessionToken)
+	}
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := request.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		path,
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + credentials.secretAccessKey)
+	for _, part := range []string{now.Format("20060102"), region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.accessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns the query parameters sorted and encoded as required
+// by Signature Version 4.
+func canonicalQuery(query url.Values) string {
+	escape := func(s string) string { return strings.ReplaceAll(s3Escape(s), "/", "%2F") }
+
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escape(name)+"="+escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
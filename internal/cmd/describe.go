@@ -0,0 +1,630 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The describers in this file write the same kind of text as 'kubectl
+// describe'. Much of the layout is pulled from kubectl's describe package:
+// https://github.com/kubernetes/kubectl/blob/release-1.24/pkg/describe/describe.go
+
+// describeTimeFormat is the format of every time in a description.
+const describeTimeFormat = time.RFC1123Z
+
+// describeWriter writes the lines of a description, indented by level.
+type describeWriter struct {
+	out *tabwriter.Writer
+}
+
+// newDescribeWriter returns a describeWriter that aligns the values of each
+// block of lines.
+func newDescribeWriter(out io.Writer) describeWriter {
+	return describeWriter{out: tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)}
+}
+
+// line writes one line at level.
+func (w describeWriter) line(level int, format string, a ...interface{}) {
+	fmt.Fprintf(w.out, strings.Repeat("  ", level)+format+"\n", a...)
+}
+
+// flush writes any buffered lines.
+func (w describeWriter) flush() error { return w.out.Flush() }
+
+// describeEnv decides what to write for the value of an environment variable.
+// It returns the value as it should be written.
+type describeEnv func(container, name, value string) string
+
+// describeMap writes a map, such as labels, as one "key=value" per line.
+func (w describeWriter) describeMap(level int, title string, m map[string]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		w.line(level, "%s:\t<none>", title)
+		return
+	}
+	for i, k := range keys {
+		if i == 0 {
+			w.line(level, "%s:\t%s=%s", title, k, m[k])
+		} else {
+			w.line(level, "\t%s=%s", k, m[k])
+		}
+	}
+}
+
+// describeList writes a list of strings, one per line.
+func (w describeWriter) describeList(level int, title string, items []string) {
+	if len(items) == 0 {
+		w.line(level, "%s:\t<none>", title)
+		return
+	}
+	for i, item := range items {
+		if i == 0 {
+			w.line(level, "%s:\t%s", title, item)
+		} else {
+			w.line(level, "\t%s", item)
+		}
+	}
+}
+
+// describeEvents writes a table of events. They are expected to be sorted.
+func (w describeWriter) describeEvents(events []corev1.Event) {
+	if len(events) == 0 {
+		w.line(0, "Events:\t<none>")
+		return
+	}
+
+	w.line(0, "Events:")
+	w.line(1, "Type\tReason\tAge\tFrom\tMessage")
+	w.line(1, "----\t------\t----\t----\t-------")
+	for _, event := range events {
+		first, last := eventTimes(event)
+		age := translateTimestampSince(metav1.NewTime(first))
+		if count := eventCount(event); count > 1 {
+			age = fmt.Sprintf("%s (x%d over %s)",
+				translateTimestampSince(metav1.NewTime(last)), count, age)
+		}
+
+		from := event.Source.Component
+		if from == "" {
+			from = event.ReportingController
+		}
+
+		w.line(1, "%s\t%s\t%s\t%s\t%s", event.Type, event.Reason, age, from,
+			strings.TrimSpace(event.Message))
+	}
+}
+
+// eventCount returns how many times event happened.
+func eventCount(event corev1.Event) int32 {
+	if event.Series != nil {
+		return event.Series.Count
+	}
+	return event.Count
+}
+
+// eventsFor returns the events about the object of kind named name, oldest
+// first.
+func eventsFor(events []corev1.Event, kind string, object metav1.Object) []corev1.Event {
+	var matched []corev1.Event
+	for _, event := range events {
+		involved := event.InvolvedObject
+		if involved.Kind != kind || involved.Name != object.GetName() {
+			continue
+		}
+		if involved.UID != "" && object.GetUID() != "" && involved.UID != object.GetUID() {
+			continue
+		}
+		matched = append(matched, event)
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, _ := eventTimes(matched[i])
+		b, _ := eventTimes(matched[j])
+		return a.Before(b)
+	})
+	return matched
+}
+
+// describeObjectMeta writes the fields every description starts with.
+func (w describeWriter) describeObjectMeta(meta metav1.ObjectMeta) {
+	w.line(0, "Name:\t%s", meta.Name)
+	w.line(0, "Namespace:\t%s", meta.Namespace)
+	if !meta.CreationTimestamp.IsZero() {
+		w.line(0, "CreationTimestamp:\t%s", meta.CreationTimestamp.Format(describeTimeFormat))
+	}
+	w.describeMap(0, "Labels", meta.Labels)
+	w.describeMap(0, "Annotations", meta.Annotations)
+}
+
+// describePod writes a description of pod followed by its events.
+func describePod(out io.Writer, pod *corev1.Pod, events []corev1.Event, env describeEnv) error {
+	w := newDescribeWriter(out)
+	w.describeObjectMeta(pod.ObjectMeta)
+
+	if pod.Spec.Priority != nil {
+		w.line(0, "Priority:\t%d", *pod.Spec.Priority)
+	}
+	if pod.Spec.PriorityClassName != "" {
+		w.line(0, "Priority Class Name:\t%s", pod.Spec.PriorityClassName)
+	}
+	w.line(0, "Service Account:\t%s", pod.Spec.ServiceAccountName)
+	if pod.Spec.NodeName == "" {
+		w.line(0, "Node:\t<none>")
+	} else {
+		w.line(0, "Node:\t%s/%s", pod.Spec.NodeName, pod.Status.HostIP)
+	}
+	if pod.Status.StartTime != nil {
+		w.line(0, "Start Time:\t%s", pod.Status.StartTime.Format(describeTimeFormat))
+	}
+	if pod.DeletionTimestamp != nil {
+		w.line(0, "Status:\tTerminating (lasts %s)", translateTimestampSince(*pod.DeletionTimestamp))
+	} else {
+		w.line(0, "Status:\t%s", pod.Status.Phase)
+	}
+	if pod.Status.Reason != "" {
+		w.line(0, "Reason:\t%s", pod.Status.Reason)
+	}
+	if pod.Status.Message != "" {
+		w.line(0, "Message:\t%s", pod.Status.Message)
+	}
+	w.line(0, "IP:\t%s", pod.Status.PodIP)
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		w.line(0, "Controlled By:\t%s/%s", owner.Kind, owner.Name)
+	}
+
+	if len(pod.Spec.InitContainers) > 0 {
+		w.line(0, "Init Containers:")
+		w.describeContainers(pod.Spec.InitContainers, pod.Status.InitContainerStatuses, env)
+	}
+	w.line(0, "Containers:")
+	w.describeContainers(pod.Spec.Containers, pod.Status.ContainerStatuses, env)
+
+	if len(pod.Status.Conditions) > 0 {
+		w.line(0, "Conditions:")
+		w.line(1, "Type\tStatus")
+		for _, c := range pod.Status.Conditions {
+			w.line(1, "%v \t%v ", c.Type, c.Status)
+		}
+	}
+
+	w.describeVolumes(pod.Spec.Volumes)
+	w.line(0, "QoS Class:\t%s", pod.Status.QOSClass)
+	w.describeMap(0, "Node-Selectors", pod.Spec.NodeSelector)
+
+	var tolerations []string
+	for _, t := range pod.Spec.Tolerations {
+		s := t.Key
+		if t.Value != "" {
+			s += "=" + t.Value
+		}
+		if t.Effect != "" {
+			s += ":" + string(t.Effect)
+		}
+		if t.Operator == corev1.TolerationOpExists && t.Value == "" {
+			s += " op=Exists"
+		}
+		if t.TolerationSeconds != nil {
+			s += fmt.Sprintf(" for %ds", *t.TolerationSeconds)
+		}
+		tolerations = append(tolerations, s)
+	}
+	w.describeList(0, "Tolerations", tolerations)
+
+	w.describeEvents(events)
+	return w.flush()
+}
+
+// describeContainers writes the spec and status of each container.
+func (w describeWriter) describeContainers(containers []corev1.Container,
+	statuses []corev1.ContainerStatus, env describeEnv,
+) {
+	for _, container := range containers {
+		w.line(1, "%s:", container.Name)
+
+		var status *corev1.ContainerStatus
+		for i := range statuses {
+			if statuses[i].Name == container.Name {
+				status = &statuses[i]
+			}
+		}
+		if status != nil {
+			w.line(2, "Container ID:\t%s", status.ContainerID)
+		}
+		w.line(2, "Image:\t%s", container.Image)
+		if status != nil {
+			w.line(2, "Image ID:\t%s", status.ImageID)
+		}
+
+		var ports []string
+		for _, port := range container.Ports {
+			ports = append(ports, fmt.Sprintf("%d/%s", port.ContainerPort, port.Protocol))
+		}
+		if len(ports) > 0 {
+			w.line(2, "Port:\t%s", strings.Join(ports, ", "))
+		}
+		if len(container.Command) > 0 {
+			w.describeList(2, "Command", container.Command)
+		}
+		if len(container.Args) > 0 {
+			w.describeList(2, "Args", container.Args)
+		}
+
+		if status != nil {
+			w.describeContainerState(2, "State", status.State)
+			if status.LastTerminationState.Terminated != nil {
+				w.describeContainerState(2, "Last State", status.LastTerminationState)
+			}
+			w.line(2, "Ready:\t%t", status.Ready)
+			w.line(2, "Restart Count:\t%d", status.RestartCount)
+		}
+
+		w.describeResources(2, "Limits", container.Resources.Limits)
+		w.describeResources(2, "Requests", container.Resources.Requests)
+
+		if container.ReadinessProbe != nil {
+			w.line(2, "Readiness:\t%s", describeProbe(container.ReadinessProbe))
+		}
+		if container.LivenessProbe != nil {
+			w.line(2, "Liveness:\t%s", describeProbe(container.LivenessProbe))
+		}
+
+		if len(container.Env) == 0 {
+			w.line(2, "Environment:\t<none>")
+		} else {
+			w.line(2, "Environment:")
+		}
+		for _, e := range container.Env {
+			switch {
+			case e.ValueFrom == nil:
+				w.line(3, "%s:\t%s", e.Name, env(container.Name, e.Name, e.Value))
+			case e.ValueFrom.FieldRef != nil:
+				w.line(3, "%s:\t (%s:%s)", e.Name,
+					e.ValueFrom.FieldRef.APIVersion, e.ValueFrom.FieldRef.FieldPath)
+			case e.ValueFrom.ResourceFieldRef != nil:
+				w.line(3, "%s:\t%s (%s)", e.Name,
+					e.ValueFrom.ResourceFieldRef.Resource, e.ValueFrom.ResourceFieldRef.ContainerName)
+			case e.ValueFrom.SecretKeyRef != nil:
+				w.line(3, "%s:\t<set to the key '%s' in secret '%s'>", e.Name,
+					e.ValueFrom.SecretKeyRef.Key, e.ValueFrom.SecretKeyRef.Name)
+			case e.ValueFrom.ConfigMapKeyRef != nil:
+				w.line(3, "%s:\t<set to the key '%s' of config map '%s'>", e.Name,
+					e.ValueFrom.ConfigMapKeyRef.Key, e.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+
+		if len(container.VolumeMounts) == 0 {
+			w.line(2, "Mounts:\t<none>")
+		} else {
+			w.line(2, "Mounts:")
+		}
+		for _, m := range container.VolumeMounts {
+			access := "rw"
+			if m.ReadOnly {
+				access = "ro"
+			}
+			if m.SubPath != "" {
+				access += ",path=\"" + m.SubPath + "\""
+			}
+			w.line(3, "%s from %s (%s)", m.MountPath, m.Name, access)
+		}
+	}
+}
+
+// describeContainerState writes the state of a container.
+func (w describeWriter) describeContainerState(level int, title string, state corev1.ContainerState) {
+	switch {
+	case state.Running != nil:
+		w.line(level, "%s:\tRunning", title)
+		w.line(level+1, "Started:\t%s", state.Running.StartedAt.Format(describeTimeFormat))
+	case state.Waiting != nil:
+		w.line(level, "%s:\tWaiting", title)
+		if state.Waiting.Reason != "" {
+			w.line(level+1, "Reason:\t%s", state.Waiting.Reason)
+		}
+	case state.Terminated != nil:
+		w.line(level, "%s:\tTerminated", title)
+		if state.Terminated.Reason != "" {
+			w.line(level+1, "Reason:\t%s", state.Terminated.Reason)
+		}
+		if state.Terminated.Message != "" {
+			w.line(level+1, "Message:\t%s", state.Terminated.Message)
+		}
+		w.line(level+1, "Exit Code:\t%d", state.Terminated.ExitCode)
+		if state.Terminated.Signal > 0 {
+			w.line(level+1, "Signal:\t%d", state.Terminated.Signal)
+		}
+		w.line(level+1, "Started:\t%s", state.Terminated.StartedAt.Format(describeTimeFormat))
+		w.line(level+1, "Finished:\t%s", state.Terminated.FinishedAt.Format(describeTimeFormat))
+	default:
+		w.line(level, "%s:\tWaiting", title)
+	}
+}
+
+// describeResources writes resource limits or requests.
+func (w describeWriter) describeResources(level int, title string, resources corev1.ResourceList) {
+	if len(resources) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+
+	w.line(level, "%s:", title)
+	for _, name := range names {
+		quantity := resources[corev1.ResourceName(name)]
+		w.line(level+1, "%s:\t%s", name, quantity.String())
+	}
+}
+
+// describeProbe returns a one line summary of probe.
+func describeProbe(probe *corev1.Probe) string {
+	attrs := fmt.Sprintf("delay=%ds timeout=%ds period=%ds #success=%d #failure=%d",
+		probe.InitialDelaySeconds, probe.TimeoutSeconds, probe.PeriodSeconds,
+		probe.SuccessThreshold, probe.FailureThreshold)
+
+	switch {
+	case probe.Exec != nil:
+		return fmt.Sprintf("exec %v %s", probe.Exec.Command, attrs)
+	case probe.HTTPGet != nil:
+		return fmt.Sprintf("http-get %s://%s:%s%s %s", strings.ToLower(string(probe.HTTPGet.Scheme)),
+			probe.HTTPGet.Host, probe.HTTPGet.Port.String(), probe.HTTPGet.Path, attrs)
+	case probe.TCPSocket != nil:
+		return fmt.Sprintf("tcp-socket %s:%s %s", probe.TCPSocket.Host,
+			probe.TCPSocket.Port.String(), attrs)
+	}
+	return "unknown " + attrs
+}
+
+// describeVolumes writes the source of each volume.
+func (w describeWriter) describeVolumes(volumes []corev1.Volume) {
+	if len(volumes) == 0 {
+		w.line(0, "Volumes:\t<none>")
+		return
+	}
+
+	w.line(0, "Volumes:")
+	for _, volume := range volumes {
+		w.line(1, "%s:", volume.Name)
+		source := volume.VolumeSource
+		switch {
+		case source.PersistentVolumeClaim != nil:
+			w.line(2, "Type:\tPersistentVolumeClaim")
+			w.line(2, "ClaimName:\t%s", source.PersistentVolumeClaim.ClaimName)
+			w.line(2, "ReadOnly:\t%t", source.PersistentVolumeClaim.ReadOnly)
+		case source.ConfigMap != nil:
+			w.line(2, "Type:\tConfigMap")
+			w.line(2, "Name:\t%s", source.ConfigMap.Name)
+		case source.Secret != nil:
+			w.line(2, "Type:\tSecret")
+			w.line(2, "SecretName:\t%s", source.Secret.SecretName)
+		case source.EmptyDir != nil:
+			w.line(2, "Type:\tEmptyDir")
+			w.line(2, "Medium:\t%s", source.EmptyDir.Medium)
+			if source.EmptyDir.SizeLimit != nil {
+				w.line(2, "SizeLimit:\t%s", source.EmptyDir.SizeLimit.String())
+			}
+		case source.Projected != nil:
+			w.line(2, "Type:\tProjected")
+			for _, p := range source.Projected.Sources {
+				switch {
+				case p.Secret != nil:
+					w.line(2, "SecretName:\t%s", p.Secret.Name)
+				case p.ConfigMap != nil:
+					w.line(2, "ConfigMapName:\t%s", p.ConfigMap.Name)
+				case p.DownwardAPI != nil:
+					w.line(2, "DownwardAPI:\ttrue")
+				case p.ServiceAccountToken != nil:
+					w.line(2, "TokenExpirationSeconds:\t%d",
+						valueOrZero(p.ServiceAccountToken.ExpirationSeconds))
+				}
+			}
+		case source.DownwardAPI != nil:
+			w.line(2, "Type:\tDownwardAPI")
+		default:
+			w.line(2, "Type:\t<unknown>")
+		}
+	}
+}
+
+// valueOrZero returns the value of p or zero when p is nil.
+func valueOrZero(p *int64) int64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// describeStatefulSet writes a description of sts followed by its events. The
+// pods are used to count the status of those it owns.
+func describeStatefulSet(out io.Writer, sts *appsv1.StatefulSet, pods []corev1.Pod,
+	events []corev1.Event, env describeEnv,
+) error {
+	w := newDescribeWriter(out)
+	w.describeObjectMeta(sts.ObjectMeta)
+
+	if sts.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(sts.Spec.Selector)
+		if err != nil {
+			return err
+		}
+		w.line(0, "Selector:\t%s", selector)
+	}
+
+	desired := int32(1)
+	if sts.Spec.Replicas != nil {
+		desired = *sts.Spec.Replicas
+	}
+	w.line(0, "Replicas:\t%d desired | %d total", desired, sts.Status.Replicas)
+	w.line(0, "Update Strategy:\t%s", sts.Spec.UpdateStrategy.Type)
+	if ru := sts.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil {
+		w.line(1, "Partition:\t%d", *ru.Partition)
+	}
+
+	var running, waiting, succeeded, failed int
+	for i := range pods {
+		if owner := metav1.GetControllerOf(&pods[i]); owner == nil || owner.UID != sts.UID {
+			continue
+		}
+		switch pods[i].Status.Phase {
+		case corev1.PodRunning:
+			running++
+		case corev1.PodPending:
+			waiting++
+		case corev1.PodSucceeded:
+			succeeded++
+		case corev1.PodFailed:
+			failed++
+		}
+	}
+	w.line(0, "Pods Status:\t%d Running / %d Waiting / %d Succeeded / %d Failed",
+		running, waiting, succeeded, failed)
+
+	w.line(0, "Pod Template:")
+	w.describeMap(1, "Labels", sts.Spec.Template.Labels)
+	w.describeMap(1, "Annotations", sts.Spec.Template.Annotations)
+	w.line(1, "Service Account:\t%s", sts.Spec.Template.Spec.ServiceAccountName)
+	if len(sts.Spec.Template.Spec.InitContainers) > 0 {
+		w.line(1, "Init Containers:")
+		w.describeContainers(sts.Spec.Template.Spec.InitContainers, nil, env)
+	}
+	w.line(1, "Containers:")
+	w.describeContainers(sts.Spec.Template.Spec.Containers, nil, env)
+
+	if len(sts.Spec.VolumeClaimTemplates) == 0 {
+		w.line(0, "Volume Claims:\t<none>")
+	} else {
+		w.line(0, "Volume Claims:")
+	}
+	for i := range sts.Spec.VolumeClaimTemplates {
+		pvc := &sts.Spec.VolumeClaimTemplates[i]
+		w.line(1, "Name:\t%s", pvc.Name)
+		w.line(1, "StorageClass:\t%s", storageClassName(pvc))
+		w.describeMap(1, "Labels", pvc.Labels)
+		w.describeMap(1, "Annotations", pvc.Annotations)
+		if capacity, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			w.line(1, "Capacity:\t%s", capacity.String())
+		} else {
+			w.line(1, "Capacity:\t%s", "<default>")
+		}
+		w.line(1, "Access Modes:\t%s", accessModes(pvc.Spec.AccessModes))
+	}
+
+	w.describeEvents(events)
+	return w.flush()
+}
+
+// describePersistentVolumeClaim writes a description of pvc followed by its
+// events. The pods are used to list those that mount it.
+func describePersistentVolumeClaim(out io.Writer, pvc *corev1.PersistentVolumeClaim,
+	pods []corev1.Pod, events []corev1.Event,
+) error {
+	w := newDescribeWriter(out)
+	w.describeObjectMeta(pvc.ObjectMeta)
+
+	w.line(0, "StorageClass:\t%s", storageClassName(pvc))
+	if pvc.DeletionTimestamp != nil {
+		w.line(0, "Status:\tTerminating (lasts %s)", translateTimestampSince(*pvc.DeletionTimestamp))
+	} else {
+		w.line(0, "Status:\t%s", pvc.Status.Phase)
+	}
+	w.line(0, "Volume:\t%s", pvc.Spec.VolumeName)
+	w.describeList(0, "Finalizers", pvc.Finalizers)
+
+	capacity := ""
+	if storage, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		capacity = storage.String()
+	}
+	w.line(0, "Capacity:\t%s", capacity)
+	w.line(0, "Access Modes:\t%s", accessModes(pvc.Status.AccessModes))
+	if pvc.Spec.VolumeMode != nil {
+		w.line(0, "VolumeMode:\t%s", *pvc.Spec.VolumeMode)
+	}
+
+	var usedBy []string
+	for _, pod := range pods {
+		for _, volume := range pod.Spec.Volumes {
+			if claim := volume.PersistentVolumeClaim; claim != nil && claim.ClaimName == pvc.Name {
+				usedBy = append(usedBy, pod.Name)
+				break
+			}
+		}
+	}
+	w.describeList(0, "Used By", usedBy)
+
+	if len(pvc.Status.Conditions) > 0 {
+		w.line(0, "Conditions:")
+		w.line(1, "Type\tStatus\tLastProbeTime\tLastTransitionTime\tReason\tMessage")
+		for _, c := range pvc.Status.Conditions {
+			w.line(1, "%v \t%v \t%s \t%s \t%v \t%v",
+				c.Type, c.Status,
+				c.LastProbeTime.Format(describeTimeFormat),
+				c.LastTransitionTime.Format(describeTimeFormat),
+				c.Reason, c.Message)
+		}
+	}
+
+	w.describeEvents(events)
+	return w.flush()
+}
+
+// storageClassName returns the storage class of pvc, if any.
+func storageClassName(pvc *corev1.PersistentVolumeClaim) string {
+	if pvc.Spec.StorageClassName != nil {
+		return *pvc.Spec.StorageClassName
+	}
+	return pvc.Annotations[corev1.BetaStorageClassAnnotation]
+}
+
+// accessModes returns the short names of modes, such as "RWO,ROX".
+func accessModes(modes []corev1.PersistentVolumeAccessMode) string {
+	short := map[corev1.PersistentVolumeAccessMode]string{
+		corev1.ReadWriteOnce:    "RWO",
+		corev1.ReadOnlyMany:     "ROX",
+		corev1.ReadWriteMany:    "RWX",
+		corev1.ReadWriteOncePod: "RWOP",
+	}
+
+	var buf bytes.Buffer
+	for i, mode := range modes {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		if s, ok := short[mode]; ok {
+			buf.WriteString(s)
+		} else {
+			buf.WriteString(string(mode))
+		}
+	}
+	return buf.String()
+}
@@ -16,8 +16,20 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
 )
 
 // Executor calls commands
@@ -25,6 +37,39 @@ type Executor func(
 	stdin io.Reader, stdout, stderr io.Writer, command ...string,
 ) error
 
+// primaryExecutor returns an Executor for the database container of the
+// primary instance of cluster and the name of its Pod.
+func primaryExecutor(ctx context.Context, config *internal.Config, namespace, cluster string) (Executor, string, error) {
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return nil, "", err
+	}
+	client, err := corev1client.NewForConfig(rest)
+	if err != nil {
+		return nil, "", err
+	}
+	podExec, err := util.NewPodExecutor(rest)
+	if err != nil {
+		return nil, "", err
+	}
+
+	pods, err := client.Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: util.PrimaryInstanceLabels(cluster),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(pods.Items) != 1 {
+		return nil, "", errors.New("primary instance Pod not found")
+	}
+
+	pod := pods.Items[0]
+	return func(stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return podExec(pod.Namespace, pod.Name, util.ContainerDatabase,
+			stdin, stdout, stderr, command...)
+	}, pod.Name, nil
+}
+
 // pgBackRestInfo defines a pgBackRest info command with relevant flags set
 func (exec Executor) pgBackRestInfo(output, repoNum string) (string, string, error) {
 	var stdout, stderr bytes.Buffer
@@ -39,25 +84,55 @@ func (exec Executor) pgBackRestInfo(output, repoNum string) (string, string, err
 	return stdout.String(), stderr.String(), err
 }
 
-// postgresqlListLogFiles returns the full path of numLogs log files.
-func (exec Executor) listPGLogFiles(numLogs int) (string, string, error) {
+// listPGLogFiles returns the full path of every Postgres log file, one per
+// line, after the Unix time it was last modified.
+func (exec Executor) listPGLogFiles() (string, string, error) {
 	var stdout, stderr bytes.Buffer
 
-	command := fmt.Sprintf("ls -1dt pgdata/pg[0-9][0-9]/log/* | head -%d", numLogs)
+	command := "stat -c '%Y %n' pgdata/pg[0-9][0-9]/log/*"
 	err := exec(nil, &stdout, &stderr, "bash", "-ceu", "--", command)
 
 	return stdout.String(), stderr.String(), err
 }
 
-// catFile takes the full path of a file and returns the contents
-// of that file
-func (exec Executor) catFile(filePath string) (string, string, error) {
-	var stdout, stderr bytes.Buffer
+// catFile takes the full path of a file and streams the contents of that file
+// to stdout
+func (exec Executor) catFile(filePath string, stdout io.Writer) (string, error) {
+	var stderr bytes.Buffer
 
 	command := fmt.Sprintf("cat %s", filePath)
-	err := exec(nil, &stdout, &stderr, "bash", "-ceu", "--", command)
+	err := exec(nil, stdout, &stderr, "bash", "-ceu", "--", command)
 
-	return stdout.String(), stderr.String(), err
+	return stderr.String(), err
+}
+
+// tailFiles streams the Postgres log files at paths, oldest first, to stdout.
+// When lines is not negative, only that many lines at the end of the files
+// together are streamed. When follow is true, the last file continues to
+// stream as it grows.
+func (exec Executor) tailFiles(stdout io.Writer, lines int64, follow bool, paths ...string) (string, error) {
+	var stderr bytes.Buffer
+
+	command := `lines="$1" follow="$2"; shift 2; last="${!#}"
+if [[ "${lines}" == all ]]; then
+  lines=+1
+  if (( $# > 1 )); then cat -- "${@:1:$#-1}"; fi
+elif (( $# > 1 )); then
+  have=$(wc -l < "${last}")
+  if (( have < lines )); then cat -- "${@:1:$#-1}" | tail --lines="$(( lines - have ))"; fi
+fi
+if [[ "${follow}" == true ]]; then exec tail --lines="${lines}" --follow=name --retry -- "${last}"; fi
+exec tail --lines="${lines}" -- "${last}"`
+
+	count := "all"
+	if lines >= 0 {
+		count = strconv.FormatInt(lines, 10)
+	}
+	err := exec(nil, stdout, &stderr, append([]string{
+		"bash", "-ceu", "--", command, "-", count, strconv.FormatBool(follow),
+	}, paths...)...)
+
+	return stderr.String(), err
 }
 
 // patronictl takes a patronictl subcommand and returns the output of that command
@@ -79,3 +154,211 @@ func (exec Executor) processes() (string, string, error) {
 
 	return stdout.String(), stderr.String(), err
 }
+
+// withTimeout returns an Executor that stops each command after timeout. The
+// command is killed if it is still running a few seconds after that.
+func (exec Executor) withTimeout(timeout time.Duration) Executor {
+	seconds := strconv.FormatFloat(math.Ceil(timeout.Seconds()), 'f', 0, 64)
+	return func(stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return exec(stdin, stdout, stderr,
+			append([]string{"timeout", "--kill-after=5", seconds}, command...)...)
+	}
+}
+
+// httpGet returns the body of an HTTP GET of path on port of the container. It
+// uses curl or wget when the container has them, and bash otherwise, which
+// cannot use TLS.
+func (exec Executor) httpGet(scheme string, port int32, path string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+
+	url := fmt.Sprintf("%s://localhost:%d%s", scheme, port, path)
+	command := fmt.Sprintf(`
+if command -v curl > /dev/null; then exec curl --silent --show-error --fail --insecure '%[1]s'; fi
+if command -v wget > /dev/null; then exec wget -q -O - --no-check-certificate '%[1]s'; fi
+[[ '%[2]s' == 'http' ]] || { echo >&2 'curl or wget is required for %[2]s'; exit 1; }
+exec 3<> /dev/tcp/127.0.0.1/%[3]d
+printf 'GET %[4]s HTTP/1.0\r\nHost: localhost\r\nConnection: close\r\n\r\n' >&3
+IFS= read -r status <&3
+[[ "${status}" == *' 200 '* ]] || { echo >&2 "${status%%$'\r'}"; exit 1; }
+while IFS= read -r line <&3 && [[ "${line%%$'\r'}" != '' ]]; do :; done
+cat <&3`, url, scheme, port, path)
+	err := exec(nil, &stdout, &stderr, "bash", "-ceu", "--", command)
+
+	return stdout.String(), stderr.String(), err
+}
+
+// psql runs sql with psql and returns its output
+func (exec Executor) psql(sql string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+
+	err := exec(strings.NewReader(sql), &stdout, &stderr,
+		"psql", "--no-psqlrc", "--pset=pager=off", "--set=ON_ERROR_STOP=1", "--file=-")
+
+	return stdout.String(), stderr.String(), err
+}
+
+// psqlLogin connects to database on port as user with password and returns
+// the name of the user. The password is read from stdin so that it does not
+// appear in the arguments of any process.
+func (exec Executor) psqlLogin(user, database string, port int32, password []byte) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+
+	command := `IFS= read -r PGPASSWORD; export PGPASSWORD PGSSLMODE=require PGCONNECT_TIMEOUT=5; ` +
+		`exec psql --no-psqlrc --no-align --tuples-only --host=localhost --port="$1" ` +
+		`--dbname="$2" --username="$3" --command='SELECT current_user'`
+	err := exec(bytes.NewReader(append(append([]byte{}, password...), '\n')), &stdout, &stderr,
+		"bash", "-ceu", "--", command, "-", strconv.Itoa(int(port)), database, user)
+
+	return stdout.String(), stderr.String(), err
+}
+
+// pgbouncerShow runs a SHOW command in the PgBouncer admin console at host and
+// port as the PgBouncer user of PGO and returns the result as CSV. The password
+// is read from stdin so that it does not appear in the arguments of any process.
+func (exec Executor) pgbouncerShow(host string, port int32, password []byte, show string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+
+	command := `IFS= read -r PGPASSWORD; export PGPASSWORD PGSSLMODE=require PGCONNECT_TIMEOUT=5; ` +
+		`exec psql --no-psqlrc --csv --host="$1" --port="$2" ` +
+		`--dbname=pgbouncer --username=_crunchypgbouncer --command="$3"`
+	err := exec(bytes.NewReader(append(append([]byte{}, password...), '\n')), &stdout, &stderr,
+		"bash", "-ceu", "--", command, "-", host, strconv.Itoa(int(port)), show)
+
+	return stdout.String(), stderr.String(), err
+}
+
+// pgSettings returns every Postgres setting that differs from its default
+func (exec Executor) pgSettings() (string, string, error) {
+	return exec.psql(`SELECT name, setting, unit, source, boot_val, reset_val, pending_restart
+  FROM pg_catalog.pg_settings
+ WHERE source NOT IN ('default', 'override') OR setting IS DISTINCT FROM boot_val
+ ORDER BY name;`)
+}
+
+// pgSettingDefinitions returns a JSON array of every Postgres setting along
+// with its type, context and limits
+func (exec Executor) pgSettingDefinitions() (string, string, error) {
+	var stdout, stderr bytes.Buffer
+
+	sql := `SELECT pg_catalog.json_agg(pg_catalog.json_build_object(
+         'name', name, 'setting', setting, 'current', pg_catalog.current_setting(name),
+         'unit', unit, 'vartype', vartype, 'context', context, 'min_val', min_val,
+         'max_val', max_val, 'enumvals', enumvals, 'pending_restart', pending_restart
+       ) ORDER BY name)
+  FROM pg_catalog.pg_settings;`
+	err := exec(strings.NewReader(sql), &stdout, &stderr,
+		"psql", "--no-psqlrc", "--quiet", "--no-align", "--tuples-only",
+		"--set=ON_ERROR_STOP=1", "--file=-")
+
+	return stdout.String(), stderr.String(), err
+}
+
+// standbyStatus returns a JSON object with the WAL positions, replay lag and
+// WAL receiver of a standby
+func (exec Executor) standbyStatus() (string, string, error) {
+	var stdout, stderr bytes.Buffer
+
+	sql := `SELECT pg_catalog.json_build_object(
+         'in_recovery', pg_catalog.pg_is_in_recovery(),
+         'receive_lsn', pg_catalog.pg_last_wal_receive_lsn(),
+         'replay_lsn', pg_catalog.pg_last_wal_replay_lsn(),
+         'replay_timestamp', pg_catalog.pg_last_xact_replay_timestamp(),
+         'replay_lag_bytes', pg_catalog.pg_wal_lsn_diff(
+           pg_catalog.pg_last_wal_receive_lsn(), pg_catalog.pg_last_wal_replay_lsn()),
+         'receiver_status', r.status, 'sender_host', r.sender_host, 'sender_port', r.sender_port)
+  FROM (SELECT 1) AS one LEFT JOIN pg_catalog.pg_stat_wal_receiver AS r ON true;`
+	err := exec(strings.NewReader(sql), &stdout, &stderr,
+		"psql", "--no-psqlrc", "--quiet", "--no-align", "--tuples-only",
+		"--set=ON_ERROR_STOP=1", "--file=-")
+
+	return stdout.String(), stderr.String(), err
+}
+
+// normalizedQuery returns SQL that reads the query text in column without its
+// literal values, which may be passwords or other data. Everything after a
+// dollar quote is removed because its end cannot be found reliably.
+func normalizedQuery(column string) string {
+	return `left(regexp_replace(regexp_replace(regexp_replace(` + column + `,
+         '\$([A-Za-z_][A-Za-z_0-9]*)?\$.*$', '?'),
+         '[EeBbXxUu]?''([^''\\]|''''|\\.)*(''|$)', '?', 'g'),
+         '\m[0-9]+(\.[0-9]+)?\M', '?', 'g'), 1024)`
+}
+
+// pgStatActivity returns what every Postgres backend is doing. Literal values
+// are removed from the query text.
+func (exec Executor) pgStatActivity() (string, string, error) {
+	return exec.psql(`SELECT pid, usename, datname, application_name, client_addr, backend_type,
+       state, wait_event_type, wait_event, backend_start, xact_start, query_start,
+       ` + normalizedQuery("query") + ` AS query
+  FROM pg_catalog.pg_stat_activity
+ ORDER BY backend_start;`)
+}
+
+// pgBlockingLocks returns every Postgres backend waiting on a lock along with
+// the backends holding it. Literal values are removed from the query text.
+func (exec Executor) pgBlockingLocks() (string, string, error) {
+	return exec.psql(`SELECT waiting.pid, waiting.usename, waiting.datname,
+       now() - waiting.query_start AS waiting_for, blocking.pid AS blocking_pid,
+       blocking.usename AS blocking_usename, blocking.state AS blocking_state,
+       ` + normalizedQuery("waiting.query") + ` AS query,
+       ` + normalizedQuery("blocking.query") + ` AS blocking_query
+  FROM pg_catalog.pg_stat_activity AS waiting
+  JOIN LATERAL unnest(pg_catalog.pg_blocking_pids(waiting.pid)) AS b(pid) ON true
+  JOIN pg_catalog.pg_stat_activity AS blocking ON blocking.pid = b.pid
+ ORDER BY waiting.pid, blocking.pid;`)
+}
+
+// pgStatReplication returns the state of every Postgres replication connection
+func (exec Executor) pgStatReplication() (string, string, error) {
+	return exec.psql(`SELECT * FROM pg_catalog.pg_stat_replication ORDER BY application_name;`)
+}
+
+// pgReplicationSlots returns every Postgres replication slot
+func (exec Executor) pgReplicationSlots() (string, string, error) {
+	return exec.psql(`SELECT * FROM pg_catalog.pg_replication_slots ORDER BY slot_name;`)
+}
+
+// pgDatabaseSizes returns the size of every Postgres database
+func (exec Executor) pgDatabaseSizes() (string, string, error) {
+	return exec.psql(`SELECT datname, pg_catalog.pg_size_pretty(pg_catalog.pg_database_size(datname)) AS size,
+       pg_catalog.pg_database_size(datname) AS bytes
+  FROM pg_catalog.pg_database
+ ORDER BY bytes DESC;`)
+}
+
+// pgBackRestCheck returns the output of a pgBackRest check command
+func (exec Executor) pgBackRestCheck() (string, string, error) {
+	var stdout, stderr bytes.Buffer
+
+	command := "pgbackrest check --stanza=db"
+	err := exec(nil, &stdout, &stderr, "bash", "-ceu", "--", command)
+
+	return stdout.String(), stderr.String(), err
+}
+
+// diskUsage returns the free space of the pgdata and WAL volumes along with
+// the size of the Postgres data and WAL directories
+func (exec Executor) diskUsage() (string, string, error) {
+	var stdout, stderr bytes.Buffer
+
+	command := "df -h /pgdata; if [ -d /pgwal ]; then df -h /pgwal; fi; " +
+		"du -sh /pgdata/pg[0-9][0-9] /pgdata/pg[0-9][0-9]_wal /pgwal/pg[0-9][0-9]_wal 2>/dev/null || true"
+	err := exec(nil, &stdout, &stderr, "bash", "-ceu", "--", command)
+
+	return stdout.String(), stderr.String(), err
+}
+
+// memoryLimits returns the memory of the node along with the memory and CPU
+// limits of the container's cgroup, either version 1 or 2
+func (exec Executor) memoryLimits() (string, string, error) {
+	var stdout, stderr bytes.Buffer
+
+	command := "head -n 3 /proc/meminfo; for f in " +
+		"/sys/fs/cgroup/memory.max /sys/fs/cgroup/memory.current /sys/fs/cgroup/cpu.max " +
+		"/sys/fs/cgroup/memory/memory.limit_in_bytes /sys/fs/cgroup/memory/memory.usage_in_bytes " +
+		"/sys/fs/cgroup/cpu/cpu.cfs_quota_us /sys/fs/cgroup/cpu/cpu.cfs_period_us; " +
+		`do if [ -r "$f" ]; then echo "$f: $(cat "$f")"; fi; done`
+	err := exec(nil, &stdout, &stderr, "bash", "-ceu", "--", command)
+
+	return stdout.String(), stderr.String(), err
+}
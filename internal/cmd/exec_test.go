@@ -15,9 +15,15 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"gotest.tools/v3/assert"
 )
@@ -77,12 +83,12 @@ func TestListPGLogFiles(t *testing.T) {
 		exec := func(
 			stdin io.Reader, stdout, stderr io.Writer, command ...string,
 		) error {
-			assert.DeepEqual(t, command, []string{"bash", "-ceu", "--", "ls -1dt pgdata/pg[0-9][0-9]/log/* | head -1"})
+			assert.DeepEqual(t, command, []string{"bash", "-ceu", "--", "stat -c '%Y %n' pgdata/pg[0-9][0-9]/log/*"})
 			assert.Assert(t, stdout != nil, "should capture stdout")
 			assert.Assert(t, stderr != nil, "should capture stderr")
 			return expected
 		}
-		_, _, err := Executor(exec).listPGLogFiles(1)
+		_, _, err := Executor(exec).listPGLogFiles()
 		assert.ErrorContains(t, err, "pass-through")
 
 	})
@@ -93,21 +99,58 @@ func TestCatFile(t *testing.T) {
 
 	t.Run("default", func(t *testing.T) {
 		expected := errors.New("pass-through")
+		var out bytes.Buffer
 		exec := func(
 			stdin io.Reader, stdout, stderr io.Writer, command ...string,
 		) error {
 			assert.DeepEqual(t, command, []string{"bash", "-ceu", "--", "cat /path/to/file"})
-			assert.Assert(t, stdout != nil, "should capture stdout")
+			assert.Equal(t, stdout, io.Writer(&out), "should stream stdout")
 			assert.Assert(t, stderr != nil, "should capture stderr")
 			return expected
 		}
-		_, _, err := Executor(exec).catFile("/path/to/file")
+		_, err := Executor(exec).catFile("/path/to/file", &out)
 		assert.ErrorContains(t, err, "pass-through")
 
 	})
 
 }
 
+func TestTailFiles(t *testing.T) {
+	if _, err := osexec.LookPath("bash"); err != nil {
+		t.Skip("requires the bash command")
+	}
+
+	dir := t.TempDir()
+	older := filepath.Join(dir, "postgresql-Mon.log")
+	newer := filepath.Join(dir, "postgresql-Tue.log")
+	assert.NilError(t, os.WriteFile(older, []byte("one\ntwo\nthree\n"), 0o600))
+	assert.NilError(t, os.WriteFile(newer, []byte("four\nfive\n"), 0o600))
+
+	local := func(stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		cmd := osexec.Command(command[0], command[1:]...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = stdin, stdout, stderr
+		return cmd.Run()
+	}
+
+	for _, tt := range []struct {
+		lines    int64
+		paths    []string
+		expected string
+	}{
+		{lines: -1, paths: []string{older, newer}, expected: "one\ntwo\nthree\nfour\nfive\n"},
+		{lines: 1, paths: []string{older, newer}, expected: "five\n"},
+		{lines: 4, paths: []string{older, newer}, expected: "two\nthree\nfour\nfive\n"},
+		{lines: 9, paths: []string{older, newer}, expected: "one\ntwo\nthree\nfour\nfive\n"},
+		{lines: 0, paths: []string{older, newer}, expected: ""},
+		{lines: 4, paths: []string{newer}, expected: "four\nfive\n"},
+	} {
+		var stdout strings.Builder
+		stderr, err := Executor(local).tailFiles(&stdout, tt.lines, false, tt.paths...)
+		assert.NilError(t, err, stderr)
+		assert.Equal(t, stdout.String(), tt.expected, "lines=%d paths=%d", tt.lines, len(tt.paths))
+	}
+}
+
 func TestPatronictl(t *testing.T) {
 
 	t.Run("default", func(t *testing.T) {
@@ -145,3 +188,173 @@ func TestProcesses(t *testing.T) {
 	})
 
 }
+
+func TestWithTimeout(t *testing.T) {
+	exec := func(
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.DeepEqual(t, command, []string{
+			"timeout", "--kill-after=5", "2", "bash", "-ceu", "--", "pgbackrest check --stanza=db",
+		})
+		return nil
+	}
+
+	_, _, err := Executor(exec).withTimeout(1500 * time.Millisecond).pgBackRestCheck()
+	assert.NilError(t, err)
+}
+
+func TestPSQL(t *testing.T) {
+	expected := errors.New("pass-through")
+	exec := func(
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.DeepEqual(t, command, []string{
+			"psql", "--no-psqlrc", "--pset=pager=off", "--set=ON_ERROR_STOP=1", "--file=-",
+		})
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+		assert.Assert(t, strings.Contains(string(b), "pg_catalog.pg_settings"))
+		assert.Assert(t, strings.Contains(string(b), "pending_restart"))
+		_, _ = stdout.Write([]byte("settings"))
+		return expected
+	}
+
+	stdout, _, err := Executor(exec).pgSettings()
+	assert.ErrorContains(t, err, "pass-through")
+	assert.Equal(t, stdout, "settings")
+
+	for _, query := range []func(Executor) (string, string, error){
+		Executor.pgSettingDefinitions, Executor.pgStatActivity, Executor.pgBlockingLocks, Executor.pgStatReplication,
+		Executor.pgReplicationSlots, Executor.pgDatabaseSizes, Executor.standbyStatus,
+	} {
+		var sql string
+		_, _, err := query(func(
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			assert.Equal(t, command[0], "psql")
+			b, err := io.ReadAll(stdin)
+			sql = string(b)
+			return err
+		})
+		assert.NilError(t, err)
+		assert.Assert(t, strings.HasPrefix(sql, "SELECT"))
+	}
+}
+
+func TestNormalizedQuery(t *testing.T) {
+	for _, query := range []func(Executor) (string, string, error){
+		Executor.pgStatActivity, Executor.pgBlockingLocks,
+	} {
+		var sql string
+		_, _, _ = query(func(
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			b, err := io.ReadAll(stdin)
+			sql = string(b)
+			return err
+		})
+
+		// Query text is read only through normalizedQuery.
+		assert.Assert(t, strings.Contains(sql, normalizedQuery("query")) ||
+			strings.Contains(sql, normalizedQuery("blocking.query")), sql)
+		for _, raw := range []string{"left(query", "left(waiting.query", "left(blocking.query"} {
+			assert.Assert(t, !strings.Contains(sql, raw), sql)
+		}
+	}
+}
+
+func TestDiagnosticCommands(t *testing.T) {
+	for name, tc := range map[string]struct {
+		run      func(Executor) (string, string, error)
+		contains []string
+	}{
+		"pgBackRestCheck": {Executor.pgBackRestCheck, []string{"pgbackrest check --stanza=db"}},
+		"diskUsage":       {Executor.diskUsage, []string{"df -h /pgdata", "du -sh", "|| true"}},
+		"memoryLimits": {Executor.memoryLimits, []string{
+			"/proc/meminfo", "/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes",
+		}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expected := errors.New("pass-through")
+			exec := func(
+				stdin io.Reader, stdout, stderr io.Writer, command ...string,
+			) error {
+				assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+				for _, s := range tc.contains {
+					assert.Assert(t, strings.Contains(command[3], s), "missing %q", s)
+				}
+				assert.Assert(t, stdout != nil, "should capture stdout")
+				assert.Assert(t, stderr != nil, "should capture stderr")
+				return expected
+			}
+			_, _, err := tc.run(exec)
+			assert.ErrorContains(t, err, "pass-through")
+		})
+	}
+}
+
+func TestHTTPGet(t *testing.T) {
+	expected := errors.New("pass-through")
+	exec := func(
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+		script := command[3]
+		assert.Assert(t, strings.Contains(script, "curl --silent --show-error --fail --insecure 'http://localhost:9187/metrics'"))
+		assert.Assert(t, strings.Contains(script, "wget -q -O - --no-check-certificate 'http://localhost:9187/metrics'"))
+		assert.Assert(t, strings.Contains(script, "/dev/tcp/127.0.0.1/9187"))
+		assert.Assert(t, strings.Contains(script, `printf 'GET /metrics HTTP/1.0\r\n`))
+		_, _ = stdout.Write([]byte("metrics"))
+		return expected
+	}
+
+	stdout, _, err := Executor(exec).httpGet("http", 9187, "/metrics")
+	assert.ErrorContains(t, err, "pass-through")
+	assert.Equal(t, stdout, "metrics")
+}
+
+func TestPSQLLogin(t *testing.T) {
+	expected := errors.New("pass-through")
+	exec := func(
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+		assert.Assert(t, strings.Contains(command[3], "IFS= read -r PGPASSWORD"))
+		assert.Assert(t, strings.Contains(command[3], "PGSSLMODE=require"))
+		assert.Assert(t, !strings.Contains(command[3], "s3cr3t"), "password should not be an argument")
+		assert.DeepEqual(t, command[4:], []string{"-", "5432", "zoo", "rhino"})
+
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), "s3cr3t\n")
+		_, _ = stdout.Write([]byte("rhino\n"))
+		return expected
+	}
+
+	stdout, _, err := Executor(exec).psqlLogin("rhino", "zoo", 5432, []byte("s3cr3t"))
+	assert.ErrorContains(t, err, "pass-through")
+	assert.Equal(t, stdout, "rhino\n")
+}
+
+func TestPGBouncerShow(t *testing.T) {
+	expected := errors.New("pass-through")
+	exec := func(
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.DeepEqual(t, command[:3], []string{"bash", "-ceu", "--"})
+		assert.Assert(t, strings.Contains(command[3], "--dbname=pgbouncer"))
+		assert.Assert(t, strings.Contains(command[3], "--csv"))
+		assert.Assert(t, !strings.Contains(command[3], "s3cr3t"), "password should not be an argument")
+		assert.DeepEqual(t, command[4:], []string{"-", "10.0.0.7", "5432", "SHOW POOLS"})
+
+		b, err := io.ReadAll(stdin)
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), "s3cr3t\n")
+		_, _ = stdout.Write([]byte("database,user\n"))
+		return expected
+	}
+
+	stdout, _, err := Executor(exec).pgbouncerShow("10.0.0.7", 5432, []byte("s3cr3t"), "SHOW POOLS")
+	assert.ErrorContains(t, err, "pass-through")
+	assert.Equal(t, stdout, "database,user\n")
+}
@@ -17,16 +17,21 @@ package cmd
 import (
 	"archive/tar"
 	"bytes"
-	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/tabwriter"
 	"time"
 
 	"github.com/spf13/cobra"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
@@ -34,17 +39,18 @@ import (
 	networkingv1 "k8s.io/api/networking/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/duration"
 	"k8s.io/cli-runtime/pkg/printers"
-	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"sigs.k8s.io/yaml"
 
 	"github.com/crunchydata/postgres-operator-client/internal"
 	"github.com/crunchydata/postgres-operator-client/internal/apis/postgres-operator.crunchydata.com/v1beta1"
@@ -70,6 +76,11 @@ const (
 | Please request file share link by emailing
 | support@crunchydata.com` + "\n"
 
+	// Support export message. Shown when the archive is uploaded.
+	msg3 = "\n" + `| Archive file size: %.2f MiB
+| Uploaded to %s
+| Let support@crunchydata.com or your existing Support Ticket know` + "\n"
+
 	// time formatting for CLI logs
 	logTimeFormat = "2006-01-02 15:04:05.000 -0700 MST"
 )
@@ -150,36 +161,150 @@ var otherNamespacedResources = []schema.GroupVersionResource{{
 // newSupportCommand returns the support subcommand of the PGO plugin.
 func newSupportExportCommand(config *internal.Config) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "export CLUSTER_NAME",
-		Short: "Export a snapshot of a PostgresCluster",
+		Use:   "export [CLUSTER_NAME...]",
+		Short: "Export a snapshot of one or more PostgresClusters",
 		Long: `The support export tool will collect information that is commonly necessary for troubleshooting a
 PostgresCluster.
 
+#### PostgresClusters
+    Name one or more PostgresClusters, or use '--all' to export every
+    PostgresCluster in the namespace or '--selector' to export those with
+    matching labels. Every PostgresCluster is written to one archive. Data
+    that is common to all of them, such as nodes, events and the operator, is
+    collected once. When more than one PostgresCluster is exported, that data
+    is in the 'shared' directory of the archive and each PostgresCluster has
+    its own directory.
+
+    Note: '-l' is the short form of '--selector'. It was the short form of
+    '--pg-logs-count' in earlier versions, which has no short form now.
+
 #### RBAC Requirements
-    Resources                                           Verbs
-    ---------                                           -----
-    configmaps                                          [list]
-    cronjobs.batch                                      [list]
-    deployments.apps                                    [list]
-    endpoints                                           [list]
-    events                                              [get list]
-    ingresses.networking.k8s.io                         [list]
-    jobs.batch                                          [list]
-    limitranges                                         [list]
-    namespaces                                          [get]
-    nodes                                               [list]
-    persistentvolumeclaims                              [list]
-    poddisruptionbudgets.policy                         [list]
-    pods                                                [list]
-    pods/exec                                           [create]
-    pods/log                                            [get]
-    postgresclusters.postgres-operator.crunchydata.com  [get]
-    replicasets.apps                                    [list]
-    serviceaccounts                                     [list]
-    services                                            [list]
-    statefulsets.apps                                   [list]
-
-    Note: This RBAC needs to be cluster-scoped to retrieve information on nodes.
+    Resources                                                     Verbs
+    ---------                                                     -----
+    clusterrolebindings.rbac.authorization.k8s.io                 [list]
+    clusterroles.rbac.authorization.k8s.io                        [get]
+    configmaps                                                    [list]
+    cronjobs.batch                                                [list]
+    customresourcedefinitions.apiextensions.k8s.io                [list]
+    deployments.apps                                              [list]
+    endpoints                                                     [list]
+    events                                                        [get list]
+    ingresses.networking.k8s.io                                   [list]
+    jobs.batch                                                    [list]
+    limitranges                                                   [list]
+    mutatingwebhookconfigurations.admissionregistration.k8s.io    [list]
+    namespaces                                                    [get]
+    nodes                                                         [list]
+    persistentvolumeclaims                                        [list]
+    poddisruptionbudgets.policy                                   [list]
+    pods                                                          [list]
+    pods/exec                                                     [create]
+    pods/log                                                      [get]
+    postgresclusters.postgres-operator.crunchydata.com            [get list]
+    replicasets.apps                                              [list]
+    rolebindings.rbac.authorization.k8s.io                        [list]
+    roles.rbac.authorization.k8s.io                               [get]
+    serviceaccounts                                               [list]
+    services                                                      [list]
+    statefulsets.apps                                             [list]
+    validatingwebhookconfigurations.admissionregistration.k8s.io  [list]
+
+    Note: This RBAC needs to be cluster-scoped to retrieve information on nodes,
+    on the operator and on its CRDs, RBAC and webhooks.
+
+#### Redaction
+    Values that may be sensitive are removed from every collected object, log
+    and command output before it is written to the archive. The '--redact-level'
+    flag controls what is removed:
+    - none: nothing is removed.
+    - standard: environment variables and ConfigMap keys with names such as
+      "password" or "token", connection URI passwords, password parameters
+      and SQL passwords, bearer tokens, private keys, password verifiers and
+      the last-applied configuration annotation of kubectl.
+    - strict: every environment variable value and ConfigMap value in addition
+      to everything removed by 'standard'.
+    The archive includes a 'redactions.json' file listing every value that was
+    removed, but not the values themselves. The '--audit' flag lists the same
+    findings without writing an archive.
+
+#### Sections
+    The archive is made of the following sections. Use '--include' to collect
+    only some of them and '--exclude' to skip some of them. The outcome of each
+    section is recorded in the 'summary.json' file of the archive and every
+    error is listed in the 'errors.json' file. Sections, and the Pods, log
+    files and resource types within them, are collected at the same time up
+    to the limit set by '--parallelism'. The archive is the same regardless.
+` + sectionsHelp() + `
+
+#### Instances
+    Postgres log files, Patroni state, diagnostics and a snapshot of the
+    exporter's metrics are collected from every instance of every instance
+    set. The files of each instance are in the 'CLUSTER/instances/INSTANCE'
+    directory of the archive, and the 'CLUSTER/instances/roles' file lists
+    every instance and its role, either primary or replica. The query text
+    in the activity and blocking lock diagnostics has its literal values
+    replaced by '?' at every redact level.
+
+#### Monitoring
+    The Deployments, ConfigMaps and Pod logs of the monitoring stack, such as
+    Prometheus, Alertmanager and Grafana, are collected from the namespace set
+    by '--monitoring-namespace'. The alerts active in Prometheus and
+    Alertmanager at the time of the export are in 'monitoring/alerts'.
+
+#### Manifest
+    The 'manifest.json' file at the root of the archive lists every other file
+    along with its source (api, exec, logs or export), when it was collected,
+    its size and its SHA-256 checksum. The 'pgo support inspect' command uses
+    it to verify the archive and summarizes the archive without a Kubernetes
+    cluster.
+
+#### Archive Formats
+    The '--format' flag chooses how the archive is written:
+    - tar.gz: a tar archive compressed by gzip. This is the default.
+    - tar.zst: a tar archive compressed by the 'zstd' command.
+    - zip: a zip archive.
+    - dir: a directory of files rather than one archive file.
+    The archive is named by '--filename' or, by default, with a timestamp and
+    the extension of its format. Use '--output -' to write it to stdout, such
+    as to pipe it to another command; messages then go to stderr. The names of
+    files in the archive are made safe to extract: characters other than
+    letters, digits, '.', '_' and '-' are replaced by '_', and no file is
+    outside the archive.
+
+#### Encryption and Upload
+    The '--encrypt' flag encrypts the archive as it is written, so that it is
+    never stored unencrypted, by piping it through the 'age' or 'gpg' command.
+    Each '--recipient' is a public key, or a file of public keys, that can
+    decrypt it. Decrypt the archive before using 'pgo support inspect'.
+    The '--upload' flag sends the finished archive to either:
+    - a pre-signed https:// URL, such as one given by Crunchy Data Support.
+    - an S3 bucket, as s3://BUCKET/PREFIX, of AWS or of an S3-compatible
+      service at '--upload-endpoint'. Credentials and region are read from
+      the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+      AWS_REGION environment variables.
+    The archive is also kept in the '--output' directory.
+
+#### Time Window
+    The '--since', '--since-time' and '--until' flags focus the export on a
+    period of time, such as an incident. They limit:
+    - Pod logs to the lines written during the window.
+    - Postgres log files to those modified during the window, in place of
+      the '--pg-logs-count' most recent files.
+    - Events to those seen during the window.
+
+#### Size Limits
+    Logs are streamed to temporary files rather than held in memory. A log
+    larger than '--max-log-size' is cut to its most recent lines. Once the
+    collected files reach '--max-archive-size' in total, the file being written
+    is cut short and the files after it are empty. Truncated files are listed
+    in the 'summary.json' file of the archive and carry the PAX records
+    'PGO.truncated' and 'PGO.originalsize'.
+
+#### Exit Status
+    A section that fails does not stop the others from being collected.
+    - 0: every section was collected.
+    - 1: every section failed, or the archive could not be written.
+    - 2: the archive was written, but some sections were not fully collected.
 
 #### Event Capture
     Support export captures all Events in the PostgresCluster's Namespace.
@@ -190,57 +315,280 @@ PostgresCluster.
 
 	// Set output to log and write to buffer for writing to file
 	var cliOutput bytes.Buffer
+	var cliOutputMutex sync.Mutex
+	cliLog := &exportLog{
+		lockedWriter: lockedWriter{mu: &cliOutputMutex, w: &cliOutput},
+		info:         os.Stdout,
+	}
 	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
 		// error messages should go to both stderr and the CLI log file
 		errMW := io.MultiWriter(os.Stderr, &cliOutput)
-		cmd.SetErr(errMW)
+		// Collectors run concurrently, so writes to the buffer are serialized.
+		cmd.SetErr(&lockedWriter{mu: &cliOutputMutex, w: errMW})
 		// Messages printed with cmd.Print (those from the 'writeDebug' function)
 		// will go only to the CLI log file. To print to the CLI log file and
 		// stdout, the writeInfo function should be used.
-		cmd.SetOut(&cliOutput)
+		cmd.SetOut(cliLog)
 
 		return nil
 	}
 
 	var outputDir string
-	cmd.Flags().StringVarP(&outputDir, "output", "o", "", "Path to save export tarball")
-	cobra.CheckErr(cmd.MarkFlagRequired("output"))
+	cmd.Flags().StringVarP(&outputDir, "output", "o", "",
+		"Directory to save the export archive, or - to write it to stdout")
+
+	var format string
+	cmd.Flags().StringVar(&format, "format", formatTarGz,
+		"Format of the export archive. types supported: "+strings.Join(archiveFormats, ","))
+
+	var filename string
+	cmd.Flags().StringVar(&filename, "filename", "",
+		"Name of the export archive. Default is crunchy_k8s_support_export_ with a timestamp and the extension of its format")
+
+	var redactLevel string
+	cmd.Flags().StringVar(&redactLevel, "redact-level", string(redactStandard),
+		"How much to redact sensitive values. types supported: none,standard,strict")
+
+	var audit bool
+	cmd.Flags().BoolVar(&audit, "audit", false,
+		"List values that would be redacted without writing an archive")
 
 	var numLogs int
-	cmd.Flags().IntVarP(&numLogs, "pg-logs-count", "l", 2, "Number of pg_log files to save")
+	cmd.Flags().IntVar(&numLogs, "pg-logs-count", 2, "Number of pg_log files to save")
+
+	var all bool
+	cmd.Flags().BoolVar(&all, "all", false,
+		"Export every PostgresCluster in the namespace")
+
+	var selector string
+	cmd.Flags().StringVarP(&selector, "selector", "l", "",
+		"Export the PostgresClusters that match this label selector")
 
 	var monitoringNamespace string
 	cmd.Flags().StringVarP(&monitoringNamespace, "monitoring-namespace", "", "", "Monitoring namespace override")
 
-	cmd.Args = cobra.ExactArgs(1)
+	var operatorNamespace string
+	cmd.Flags().StringVar(&operatorNamespace, "operator-namespace", "",
+		"Namespace of the operator. Default is to look for it in every namespace")
+
+	var since time.Duration
+	cmd.Flags().DurationVar(&since, "since", 0,
+		"Collect only logs and events newer than a relative duration like 5s, 2m, or 3h")
+	var sinceTime, until string
+	cmd.Flags().StringVar(&sinceTime, "since-time", "",
+		"Collect only logs and events after a date (RFC3339)")
+	cmd.Flags().StringVar(&until, "until", "",
+		"Collect only logs and events before a date (RFC3339)")
+
+	var maxLogSize, maxArchiveSize string
+	cmd.Flags().StringVar(&maxLogSize, "max-log-size", "100Mi",
+		"Keep only the end of each log larger than this size. Zero means no limit")
+	cmd.Flags().StringVar(&maxArchiveSize, "max-archive-size", "0",
+		"Truncate collected files once their total size reaches this size. Zero means no limit")
+
+	var execTimeout time.Duration
+	cmd.Flags().DurationVar(&execTimeout, "exec-timeout", 30*time.Second,
+		"Stop each diagnostic command that runs longer than this")
+
+	var parallelism int
+	cmd.Flags().IntVar(&parallelism, "parallelism", 4,
+		"Maximum number of sections and items to collect at the same time")
+
+	var include, exclude []string
+	cmd.Flags().StringSliceVar(&include, "include", nil,
+		"Sections to collect; can be used multiple times. Default is every section")
+	cmd.Flags().StringSliceVar(&exclude, "exclude", nil,
+		"Sections to skip; can be used multiple times")
+
+	var encrypt string
+	var recipients []string
+	cmd.Flags().StringVar(&encrypt, "encrypt", "",
+		"Encrypt the archive with the age or gpg command. types supported: age,gpg")
+	cmd.Flags().StringArrayVar(&recipients, "recipient", nil,
+		"Public key, or file of public keys, that can decrypt the archive; can be used multiple times")
+
+	var upload, uploadEndpoint string
+	cmd.Flags().StringVar(&upload, "upload", "",
+		"Upload the archive to a pre-signed https:// URL or to s3://BUCKET/PREFIX")
+	cmd.Flags().StringVar(&uploadEndpoint, "upload-endpoint", "",
+		"URL of an S3-compatible service. Default is AWS S3 in AWS_REGION")
+
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		return exportTargetArgs(args, all, selector)
+	}
 
 	cmd.Example = internal.FormatExample(`
 # Short Flags
-kubectl pgo support export daisy -o . -l 2
+kubectl pgo support export daisy -o .
 
 # Long Flags
 kubectl pgo support export daisy --output . --pg-logs-count 2
 
+# Export two PostgresClusters to one archive
+kubectl pgo support export daisy hippo --output .
+
+# Export every PostgresCluster in the namespace
+kubectl pgo support export --all --output .
+
+# Export the PostgresClusters with a label
+kubectl pgo support export -l team=payments --output .
+
+# Operator namespace override
+# This skips looking for the operator in every namespace.
+kubectl pgo support export daisy --operator-namespace postgres-operator --output .
+
 # Monitoring namespace override
 # This is only required when monitoring is not deployed in the PostgresCluster's namespace.
 kubectl pgo support export daisy --monitoring-namespace another-namespace --output .
+
+# Redact every environment variable and ConfigMap value
+kubectl pgo support export daisy --redact-level strict --output .
+
+# List the values that would be redacted without writing an archive
+kubectl pgo support export daisy --audit
+
+# Collect only the Postgres and Patroni sections
+kubectl pgo support export daisy --include pglogs,patroni --output .
+
+# Collect everything except the process lists
+kubectl pgo support export daisy --exclude processes --output .
+
+# Collect the logs and events of the last two hours
+kubectl pgo support export daisy --since 2h --output .
+
+# Collect the logs and events of an incident
+kubectl pgo support export daisy --since-time 2023-01-01T10:00:00Z --until 2023-01-01T11:00:00Z --output .
+
+# Keep the last 10MiB of each log and at most 1GiB in total
+kubectl pgo support export daisy --max-log-size 10Mi --max-archive-size 1Gi --output .
+
+# Give slow diagnostic commands two minutes to finish
+kubectl pgo support export daisy --exec-timeout 2m --output .
+
+# Collect one section, Pod log or file at a time
+kubectl pgo support export daisy --parallelism 1 --output .
+
+# Write a zip archive with a chosen name
+kubectl pgo support export daisy --format zip --filename daisy-incident.zip --output .
+
+# Write a tar.zst archive to stdout
+kubectl pgo support export daisy --format tar.zst --output - > daisy.tar.zst
+
+# Encrypt the archive to an age public key
+kubectl pgo support export daisy --encrypt age --recipient age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p --output .
+
+# Encrypt the archive to a GPG public key file and upload it to a pre-signed URL
+kubectl pgo support export daisy --encrypt gpg --recipient support.asc --upload 'https://example.com/upload?signature=...' --output .
+
+# Upload the archive to a bucket of an S3-compatible service
+kubectl pgo support export daisy --upload s3://support-exports/daisy --upload-endpoint https://minio.example.com --output .
 	`)
 
 	cmd.RunE = func(cmd *cobra.Command, args []string) error {
 		ctx := context.Background()
 
+		if outputDir == "" && !audit {
+			return fmt.Errorf(`required flag(s) "output" not set`)
+		}
+
+		if err := archiveFormat(format); err != nil {
+			return err
+		}
+		if filename != "" {
+			if err := archiveFilename(filename); err != nil {
+				return err
+			}
+		}
+		if err := archiveTargetFlags(outputDir, format, filename, encrypt, upload); err != nil {
+			return err
+		}
+		if outputDir == stdoutOutput && !audit {
+			if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+				return fmt.Errorf("refusing to write the archive to a terminal; redirect stdout to a file or command")
+			}
+
+			// Messages go to stderr so that stdout holds only the archive.
+			cliLog.info = os.Stderr
+		}
+
+		if parallelism < 1 {
+			return fmt.Errorf("invalid parallelism %d: must be at least 1", parallelism)
+		}
+
+		if execTimeout <= 0 {
+			return fmt.Errorf("invalid exec-timeout %s: must be positive", execTimeout)
+		}
+
+		window, err := newTimeWindow(time.Now(), since, sinceTime, until)
+		if err != nil {
+			return err
+		}
+
+		logLimit, err := sizeFlag("max-log-size", maxLogSize)
+		if err != nil {
+			return err
+		}
+		archiveLimit, err := sizeFlag("max-archive-size", maxArchiveSize)
+		if err != nil {
+			return err
+		}
+
+		redact, err := newRedactor(redactLevel)
+		if err != nil {
+			return err
+		}
+
+		enabled, err := enabledSections(include, exclude)
+		if err != nil {
+			return err
+		}
+
+		encryption, err := newArchiveEncryption(encrypt, recipients)
+		if err != nil {
+			return err
+		}
+		uploader, err := newArchiveUploader(upload, uploadEndpoint, os.Getenv)
+		if err != nil {
+			return err
+		}
+
 		writeInfo(cmd, preBox)
 		writeInfo(cmd, "| PGO CLI Support Export Tool")
 		writeInfo(cmd, "| The support export tool will collect information that is")
 		writeInfo(cmd, "| commonly necessary for troubleshooting a PostgresCluster.")
-		writeInfo(cmd, "| Note: No data or k8s secrets are collected.")
+		if redact.level == redactNone {
+			writeInfo(cmd, "| Warning: Sensitive values are NOT redacted (level: none);")
+			writeInfo(cmd, "| the archive may contain passwords, tokens and keys.")
+		} else {
+			writeInfo(cmd, fmt.Sprintf("| Sensitive values are redacted (level: %s);", redact.level))
+			writeInfo(cmd, "| review the archive before sharing it.")
+		}
 		writeInfo(cmd, postBox)
 
-		clusterName := args[0]
-		writeDebug(cmd, fmt.Sprintf("Arg - PostgresCluster Name: %s\n", clusterName))
+		for _, name := range args {
+			writeDebug(cmd, fmt.Sprintf("Arg - PostgresCluster Name: %s\n", name))
+		}
+		writeDebug(cmd, fmt.Sprintf("Flag - All: %t\n", all))
+		writeDebug(cmd, fmt.Sprintf("Flag - Selector: %s\n", selector))
 		writeDebug(cmd, fmt.Sprintf("Flag - Output Directory: %s\n", outputDir))
+		writeDebug(cmd, fmt.Sprintf("Flag - Format: %s\n", format))
+		writeDebug(cmd, fmt.Sprintf("Flag - Filename: %s\n", filename))
 		writeDebug(cmd, fmt.Sprintf("Flag - Num Logs: %d\n", numLogs))
 		writeDebug(cmd, fmt.Sprintf("Flag - Monitoring Namespace: %s\n", monitoringNamespace))
+		writeDebug(cmd, fmt.Sprintf("Flag - Operator Namespace: %s\n", operatorNamespace))
+		writeDebug(cmd, fmt.Sprintf("Flag - Redact Level: %s\n", redactLevel))
+		writeDebug(cmd, fmt.Sprintf("Flag - Audit: %t\n", audit))
+		writeDebug(cmd, fmt.Sprintf("Flag - Time Window: %s\n", window))
+		writeDebug(cmd, fmt.Sprintf("Flag - Max Log Size: %s\n", maxLogSize))
+		writeDebug(cmd, fmt.Sprintf("Flag - Max Archive Size: %s\n", maxArchiveSize))
+		writeDebug(cmd, fmt.Sprintf("Flag - Exec Timeout: %s\n", execTimeout))
+		writeDebug(cmd, fmt.Sprintf("Flag - Parallelism: %d\n", parallelism))
+		writeDebug(cmd, fmt.Sprintf("Flag - Include: %v\n", include))
+		writeDebug(cmd, fmt.Sprintf("Flag - Exclude: %v\n", exclude))
+		writeDebug(cmd, fmt.Sprintf("Flag - Encrypt: %s\n", encrypt))
+		writeDebug(cmd, fmt.Sprintf("Flag - Recipients: %v\n", recipients))
+		writeDebug(cmd, fmt.Sprintf("Flag - Upload: %s\n", redactURL(upload)))
+		writeDebug(cmd, fmt.Sprintf("Flag - Upload Endpoint: %s\n", uploadEndpoint))
 
 		namespace, err := config.Namespace()
 		if err != nil {
@@ -262,282 +610,1030 @@ kubectl pgo support export daisy --monitoring-namespace another-namespace --outp
 			return err
 		}
 
-		discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+		apiExtensions, err := apiextensionsv1.NewForConfig(restConfig)
+		if err != nil {
+			return err
+		}
+
+		podExec, err := util.NewPodExecutor(restConfig)
 		if err != nil {
 			return err
 		}
 
-		// Ensure cluster exists in the namespace before we create a file or gather
-		// any information.
-		// Since we check for the cluster before creating the file, these logs only
-		// appear in stdout/stderr
+		// Ensure the clusters exist in the namespace before we create a file or
+		// gather any information.
+		// Since we check for the clusters before creating the file, these logs
+		// only appear in stdout/stderr
 		_, postgresClient, err := v1beta1.NewPostgresClusterClient(config)
 		if err != nil {
 			return err
 		}
-		get, err := postgresClient.Namespace(namespace).Get(ctx,
-			clusterName, metav1.GetOptions{})
-		if err != nil || get == nil {
-			if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
-				return err
-			}
-			return fmt.Errorf("could not find cluster %s in namespace %s: %w", clusterName, namespace, err)
+		clusters, err := selectClusters(ctx, postgresClient.Namespace(namespace),
+			namespace, args, all, selector)
+		if err != nil {
+			return err
+		}
+
+		// Files that are common to every cluster are in the directory of the
+		// cluster when there is only one.
+		sharedDir := sharedExportDir
+		if len(clusters) == 1 {
+			sharedDir = clusters[0].GetName()
+		}
+		var clusterNames []string
+		for _, cluster := range clusters {
+			clusterNames = append(clusterNames, cluster.GetName())
 		}
+		writeInfo(cmd, "PostgresClusters: "+strings.Join(clusterNames, ", "))
 
 		// Name file with year-month-day-HrMinSecTimezone suffix
 		// Example: crunchy_k8s_support_export_2022-08-08-115726-0400.tar.gz
-		outputFile := "crunchy_k8s_support_export_" + time.Now().Format("2006-01-02-150405-0700") + ".tar.gz"
-		// #nosec G304 -- We intentionally write to the directory supplied by the user.
-		tarFile, err := os.Create(outputDir + "/" + outputFile)
-		if err != nil {
-			return err
+		outputFile := filename
+		if outputFile == "" {
+			outputFile = "crunchy_k8s_support_export_" + time.Now().Format("2006-01-02-150405-0700") +
+				archiveExtension(format)
+			if encryption != nil {
+				outputFile += encryption.extension()
+			}
 		}
+		outputPath := outputDir + "/" + outputFile
+
+		// An audit collects everything an export would but discards the
+		// archive; only the redaction findings are reported.
+		var out io.Writer = io.Discard
+		var outFile *os.File
+		var stdout *countingWriter
+		var encryptor io.WriteCloser
+		var archive archiveWriter = &tarArchive{tw: tar.NewWriter(io.Discard)}
+		if !audit {
+			switch {
+			case outputDir == stdoutOutput:
+				stdout = &countingWriter{w: os.Stdout}
+				out = stdout
+			case format != formatDir:
+				// #nosec G304 -- We intentionally write to the directory supplied by the user.
+				outFile, err = os.Create(outputPath)
+				if err != nil {
+					return err
+				}
+				out = outFile
+			}
 
-		gw, err := gzip.NewWriterLevel(tarFile, gzip.BestCompression)
-		if err != nil {
-			return err
+			if encryption != nil {
+				encryptor, err = encryption.writer(ctx, out)
+				if err == nil {
+					out = encryptor
+				}
+			}
+			if err == nil {
+				archive, err = newArchiveWriter(ctx, format, out, outputPath)
+			}
+			if err != nil {
+				if outFile != nil {
+					_ = outFile.Close()
+				}
+				return err
+			}
 		}
-		tw := tar.NewWriter(gw)
 		defer func() {
 			// ignore any errors from Close functions, the writers will be
 			// closed when the program exits
-			if gw != nil {
-				_ = gw.Close()
+			if archive != nil {
+				_ = archive.Close()
 			}
-			if tw != nil {
-				_ = tw.Close()
+			if encryptor != nil {
+				_ = encryptor.Close()
 			}
-			if tarFile != nil {
-				_ = tarFile.Close()
+			if outFile != nil {
+				_ = outFile.Close()
 			}
 		}()
 
-		// PGO CLI version
-		err = gatherPGOCLIVersion(ctx, clusterName, tw, cmd)
-
-		// Current Kubernetes context
-		if err == nil {
-			err = gatherKubeContext(ctx, config, clusterName, tw, cmd)
+		if monitoringNamespace == "" {
+			monitoringNamespace = namespace
 		}
 
-		// Gather cluster wide resources
-		if err == nil {
-			err = gatherKubeServerVersion(ctx, discoveryClient, clusterName, tw, cmd)
+		export := &supportExport{
+			config:              config,
+			clientset:           clientset,
+			dynamicClient:       dynamicClient,
+			apiExtensions:       apiExtensions,
+			podExec:             podExec,
+			clusters:            clusters,
+			sharedDir:           sharedDir,
+			namespace:           namespace,
+			monitoringNamespace: monitoringNamespace,
+			operatorNamespace:   operatorNamespace,
+			numLogs:             numLogs,
+			window:              window,
+			maxLogSize:          logLimit,
+			execTimeout:         execTimeout,
+			redact:              redact,
+			archive:             archive,
+			budget:              &archiveBudget{limit: archiveLimit},
+			manifest: &archiveManifest{
+				Created: time.Now().UTC(), Clusters: clusterNames, Shared: sharedDir,
+			},
+			cmd:     cmd,
+			workers: make(chan struct{}, parallelism),
 		}
 
-		if err == nil {
-			err = gatherNodes(ctx, clientset, clusterName, tw, cmd)
+		results := runCollectors(ctx, export, supportCollectors, enabled)
+
+		// Record the outcome of each collector
+		if !audit {
+			err = gatherSummary(export, results)
 		}
 
-		if err == nil {
-			err = gatherCurrentNamespace(ctx, clientset, namespace, clusterName, tw, cmd)
+		// List what was redacted
+		if err == nil && !audit {
+			err = gatherRedactions(export)
 		}
 
-		// Namespaced resources
-		if err == nil {
-			err = gatherClusterSpec(get, clusterName, tw, cmd)
+		// Print cli output
+		writeInfo(cmd, "Collecting PGO CLI logs...")
+		path := sharedDir + "/logs/cli"
+		if logErr := export.writeNow(path, redact.text(path, cliOutput.Bytes())); logErr != nil {
+			return logErr
 		}
 
-		// TODO (jmckulk): pod describe output
-		if err == nil {
-			// get Namespaced resources that have cluster label
-			nsListOpts := metav1.ListOptions{
-				LabelSelector: "postgres-operator.crunchydata.com/cluster=" + clusterName,
-			}
-			err = gatherNamespacedAPIResources(ctx, dynamicClient, namespace,
-				clusterName, clusterNamespacedResources, nsListOpts, tw, cmd)
+		// List every file of the archive
+		if err == nil && !audit {
+			err = writeManifest(archive, export.manifest, cmd)
 		}
 
-		if err == nil {
-			// get other Namespaced resources that do not have the cluster label
-			// but may otherwise impact the PostgresCluster's operation
-			otherListOpts := metav1.ListOptions{}
-			err = gatherNamespacedAPIResources(ctx, dynamicClient, namespace,
-				clusterName, otherNamespacedResources, otherListOpts, tw, cmd)
+		// Print audit findings instead of the archive size
+		if err == nil && audit {
+			fmt.Fprint(cliLog.info, redact.report())
+			fmt.Fprint(cliLog.info, exportFailureReport(results))
+			return exportResultError(results)
 		}
 
+		// Print final message
 		if err == nil {
-			err = gatherEvents(ctx, clientset, namespace, clusterName, tw, cmd)
+			// Close the archive so its size is final
+			err = archive.Close()
+			if err == nil && encryptor != nil {
+				err = encryptor.Close()
+			}
+			if err == nil && outFile != nil {
+				err = outFile.Close()
+			}
 		}
+		if err == nil {
+			var size int64
+			switch {
+			case stdout != nil:
+				size = stdout.n
+			case format == formatDir:
+				size = archive.(*dirArchive).size
+			default:
+				var info os.FileInfo
+				if info, err = os.Stat(outputPath); err == nil {
+					size = info.Size()
+				}
+			}
+			archive, encryptor, outFile = nil, nil, nil
+
+			var location string
+			if err == nil && uploader != nil {
+				writeInfo(cmd, "Uploading archive...")
+				location, err = uploadArchive(ctx, uploader, outputDir, outputFile)
+			}
 
-		// Logs
-		if numLogs > 0 {
 			if err == nil {
-				err = gatherPostgresqlLogs(ctx, clientset, restConfig, namespace, clusterName, numLogs, tw, cmd)
+				fmt.Fprint(cliLog.info, exportFailureReport(results))
+				if location != "" {
+					fmt.Fprint(cliLog.info, exportUploadReport(float64(size), location))
+				} else {
+					fmt.Fprint(cliLog.info, exportSizeReport(float64(size)))
+				}
+			}
+			if err != nil {
+				return err
 			}
-		}
 
-		// get PostgresCluster Pod logs
-		if err == nil {
-			writeInfo(cmd, "Collecting PostgresCluster pod logs...")
-			err = gatherPodLogs(ctx, clientset, namespace, fmt.Sprintf("%s=%s", util.LabelCluster, clusterName), clusterName, tw, cmd)
+			return exportResultError(results)
 		}
 
-		// get monitoring Pod logs
-		if monitoringNamespace == "" {
-			monitoringNamespace = namespace
-		}
-		if err == nil {
-			writeInfo(cmd, "Collecting monitoring pod logs...")
-			err = gatherPodLogs(ctx, clientset, monitoringNamespace, util.LabelMonitoring, "monitoring", tw, cmd)
-		}
+		return err
+	}
 
-		// Exec resources
-		if err == nil {
-			err = gatherPatroniInfo(ctx, clientset, restConfig, namespace, clusterName, tw, cmd)
-		}
+	return cmd
+}
 
-		// Exec to get Container processes
-		if err == nil {
-			err = gatherProcessInfo(ctx, clientset, restConfig, namespace, clusterName, tw, cmd)
-		}
+// stdoutOutput is the value of '--output' that writes the archive to stdout.
+const stdoutOutput = "-"
+
+// archiveTargetFlags validates the flags that decide where the archive goes
+// against its format.
+func archiveTargetFlags(output, format, filename, encrypt, upload string) error {
+	switch {
+	case output == stdoutOutput && format == formatDir:
+		return fmt.Errorf("--format=%s cannot be written to stdout", formatDir)
+	case output == stdoutOutput && filename != "":
+		return fmt.Errorf("--filename cannot be used when writing to stdout")
+	case output == stdoutOutput && upload != "":
+		return fmt.Errorf("--upload cannot be used when writing to stdout")
+	case format == formatDir && encrypt != "":
+		return fmt.Errorf("--encrypt cannot be used with --format=%s", formatDir)
+	case format == formatDir && upload != "":
+		return fmt.Errorf("--upload cannot be used with --format=%s", formatDir)
+	}
+	return nil
+}
 
-		// Print cli output
-		writeInfo(cmd, "Collecting PGO CLI logs...")
-		path := clusterName + "/logs/cli"
-		if logErr := writeTar(tw, cliOutput.Bytes(), path, cmd); logErr != nil {
-			return logErr
+// sharedExportDir is the directory of the archive that holds the files common
+// to every PostgresCluster when more than one is exported.
+const sharedExportDir = "shared"
+
+// exportTargetArgs validates the PostgresClusters to export: either names, or
+// every cluster, or those matching a selector.
+func exportTargetArgs(args []string, all bool, selector string) error {
+	switch {
+	case selector != "" && strings.Trim(selector, "0123456789") == "":
+		// The short form of --pg-logs-count used to be "-l".
+		return fmt.Errorf(
+			"invalid selector %q: -l is short for --selector; use --pg-logs-count for the number of log files",
+			selector)
+	case all && selector != "":
+		return fmt.Errorf("only one of --all or --selector may be used")
+	case (all || selector != "") && len(args) > 0:
+		return fmt.Errorf("PostgresCluster names cannot be used with --all or --selector")
+	case !all && selector == "" && len(args) == 0:
+		return fmt.Errorf("requires a PostgresCluster name, --all or --selector")
+	}
+	return nil
+}
+
+// selectClusters returns the PostgresClusters named, in order, or every one
+// matching selector, sorted by name. It is an error when a named
+// cluster does not exist or when nothing matches.
+func selectClusters(ctx context.Context, client dynamic.ResourceInterface, namespace string,
+	names []string, all bool, selector string,
+) ([]*unstructured.Unstructured, error) {
+	var clusters []*unstructured.Unstructured
+
+	if all || selector != "" {
+		list, err := client.List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			clusters = append(clusters, &list.Items[i])
+		}
+		if len(clusters) == 0 {
+			return nil, fmt.Errorf("no PostgresClusters found in namespace %s", namespace)
 		}
+		sort.Slice(clusters, func(i, j int) bool {
+			return clusters[i].GetName() < clusters[j].GetName()
+		})
+		return clusters, nil
+	}
 
-		// Print final message
-		if err == nil {
-			info, err := os.Stat(outputDir + "/" + outputFile)
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
 
-			if err == nil {
-				fmt.Print(exportSizeReport(float64(info.Size())))
+		get, err := client.Get(ctx, name, metav1.GetOptions{})
+		if err != nil || get == nil {
+			if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+				return nil, err
 			}
+			return nil, fmt.Errorf("could not find cluster %s in namespace %s: %w", name, namespace, err)
 		}
-
-		return err
+		clusters = append(clusters, get)
 	}
+	return clusters, nil
+}
 
-	return cmd
+// supportExport holds the clients, settings and archive shared by the
+// collectors of a support export.
+type supportExport struct {
+	config        *internal.Config
+	clientset     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	apiExtensions apiextensionsv1.ApiextensionsV1Interface
+	podExec       func(namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string) error
+
+	clusters            []*unstructured.Unstructured
+	sharedDir           string
+	namespace           string
+	monitoringNamespace string
+	operatorNamespace   string
+	numLogs             int
+	window              timeWindow
+	maxLogSize          int64
+	execTimeout         time.Duration
+
+	redact   *redactor
+	archive  archiveWriter
+	budget   *archiveBudget
+	manifest *archiveManifest
+	cmd      *cobra.Command
+
+	// workers limits how many collectors and items run at once. Each running
+	// collector or item holds one value in the channel.
+	workers chan struct{}
+
+	// cluster is the PostgresCluster gathered by the collector currently
+	// running. It is nil for collectors of what is shared by every cluster.
+	cluster     *unstructured.Unstructured
+	clusterName string
+
+	// section is the outcome of the collector currently running, and entries
+	// are the files it has written so far. Each collector and each item of
+	// [supportExport.parallel] is given its own copy of the supportExport.
+	section *exportSection
+	entries *[]archiveEntry
 }
 
-// exportSizeReport defines the message displayed when a support export archive
-// is created. If the size of the archive file is greater than 25MiB, an alternate
-// message is displayed.
-func exportSizeReport(size float64) string {
+// exportSection is the outcome of a collector that may be shared by many
+// goroutines.
+type exportSection struct {
+	sync.Mutex
+	result sectionResult
+}
 
-	finalMsg := preBox + fmt.Sprintf(msg1, size/mebibyte)
+// archiveEntry is a file waiting to be written to the archive. Its content is
+// either in memory or in a spool.
+type archiveEntry struct {
+	name      string
+	source    string
+	collected time.Time
+	content   []byte
+	spool     *spool
+}
 
-	// if file size is > 25 MiB, print alternate message
-	if size > mebibyte*25 {
-		finalMsg = preBox + fmt.Sprintf(msg2, size/mebibyte)
+// close releases the spool of the entry, if any.
+func (entry archiveEntry) close() {
+	if entry.spool != nil {
+		_ = entry.spool.Close()
 	}
-	finalMsg = finalMsg + postBox + "\n"
-
-	return finalMsg
 }
 
-// gatherPGOCLIVersion collects the PGO CLI version
-func gatherPGOCLIVersion(_ context.Context,
-	clusterName string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
-) error {
-	writeInfo(cmd, "Collecting PGO CLI version...")
-	path := clusterName + "/pgo-cli-version"
-	if err := writeTar(tw, []byte(clientVersion), path, cmd); err != nil {
-		return err
-	}
+// write adds a file to the archive with content from source, one of the
+// source constants. Files written by a collector appear in
+// the archive in the order they were written.
+func (export *supportExport) write(source, path string, content []byte) error {
+	*export.entries = append(*export.entries, archiveEntry{
+		name: path, source: source, collected: time.Now(), content: content,
+	})
 	return nil
 }
 
-// gatherKubeContext collects the current Kubernetes context
-func gatherKubeContext(_ context.Context,
-	config *internal.Config,
-	clusterName string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
+// stream adds a file to the archive with content from source written by fn,
+// such as a log
+// that may be too large to hold in memory. The content is redacted as it is
+// written and, when limit is more than zero, only its last limit bytes are
+// kept. Nothing is added when fn returns an error.
+func (export *supportExport) stream(source, path string, limit int64,
+	fn func(w io.Writer) error,
 ) error {
-	writeInfo(cmd, "Collecting current Kubernetes context...")
-	path := clusterName + "/current-context"
-
-	rawConfig, err := config.ConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	s, err := newSpool(limit)
 	if err != nil {
 		return err
 	}
 
-	if err := writeTar(tw, []byte(rawConfig.CurrentContext), path, cmd); err != nil {
+	w := export.redact.writer(path, s)
+	err = fn(w)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		_ = s.Close()
 		return err
 	}
+
+	*export.entries = append(*export.entries, archiveEntry{
+		name: path, source: source, collected: time.Now(), spool: s,
+	})
 	return nil
 }
 
-// gatherKubeServerVersion collects the server version from the Kubernetes cluster
-func gatherKubeServerVersion(_ context.Context,
-	client *discovery.DiscoveryClient,
-	clusterName string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
+// truncation describes a file that was shortened to fit a size limit.
+type truncation struct {
+	Path         string `json:"path"`
+	Size         int64  `json:"size"`
+	OriginalSize int64  `json:"originalSize"`
+	Reason       string `json:"reason"`
+}
+
+// archiveBudget limits the total size of the collected files in the archive
+// and remembers which files were truncated. It is used only by the goroutine
+// that writes the archive.
+type archiveBudget struct {
+	limit int64 // zero means no limit
+	used  int64
+
+	truncated []truncation
+}
+
+// write writes entry to archive. An entry that does not fit in the space left is
+// truncated to fit, and the entries after it are empty. Truncated entries are
+// tagged with PAX records naming the limit they exceeded and their original
+// size. The entry is listed in manifest, if any.
+func (budget *archiveBudget) write(archive archiveWriter, manifest *archiveManifest,
+	entry archiveEntry, cmd *cobra.Command,
 ) error {
-	writeInfo(cmd, "Collecting Kubernetes version...")
-	ver, err := client.ServerVersion()
-	if err != nil {
-		return err
+	var r io.Reader
+	var size, original int64
+	var reason string
+
+	entry.name = sanitizeEntryName(entry.name)
+	if entry.spool != nil {
+		var err error
+		if r, size, err = entry.spool.reader(); err != nil {
+			return err
+		}
+		original = entry.spool.written
+		if entry.spool.truncated() {
+			reason = "max-log-size"
+		}
+	} else {
+		r, size = bytes.NewReader(entry.content), int64(len(entry.content))
+		original = size
 	}
 
-	path := clusterName + "/server-version"
-	if err := writeTar(tw, []byte(ver.String()), path, cmd); err != nil {
-		return err
+	if budget.limit > 0 {
+		if remaining := budget.limit - budget.used; size > remaining {
+			r, size = io.LimitReader(r, remaining), remaining
+			reason = "max-archive-size"
+		}
+		budget.used += size
 	}
-	return nil
+
+	var records map[string]string
+	if reason != "" {
+		writeInfo(cmd, fmt.Sprintf("Truncated %s to %d bytes (%s)", entry.name, size, reason))
+		budget.truncated = append(budget.truncated, truncation{
+			Path: entry.name, Size: size, OriginalSize: original, Reason: reason,
+		})
+		records = map[string]string{
+			"PGO.truncated":    reason,
+			"PGO.originalsize": strconv.FormatInt(original, 10),
+		}
+	}
+
+	return manifest.write(archive, r, size, entry.name, records, entry.source, entry.collected, cmd)
 }
 
-// gatherNodes gets list of nodes in the Kubernetes Cluster and prints them
-// to a file using the `-o wide` output
-func gatherNodes(ctx context.Context,
-	clientset *kubernetes.Clientset,
-	clusterName string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
+// writeNow writes a file that describes the export itself straight to the
+// archive, regardless of the archive budget, and lists it in the manifest.
+// It must only be called after every collector has finished.
+func (export *supportExport) writeNow(path string, content []byte) error {
+	return export.manifest.write(export.archive, bytes.NewReader(content), int64(len(content)),
+		path, nil, sourceExport, time.Now(), export.cmd)
+}
+
+// warn logs a problem that did not stop the current collector and records it
+// in the summary.
+func (export *supportExport) warn(err error) {
+	writeInfo(export.cmd, err.Error())
+	if export.section != nil {
+		export.section.Lock()
+		defer export.section.Unlock()
+		export.section.result.Errors = append(export.section.result.Errors, err.Error())
+	}
+}
+
+// parallel calls fn once for every index below n using at most --parallelism
+// workers. Files written by each call appear in the archive in index order,
+// regardless of the order in which the calls finish. It returns the first
+// error in index order.
+func (export *supportExport) parallel(n int,
+	fn func(item *supportExport, i int) error,
 ) error {
-	writeInfo(cmd, "Collecting nodes...")
-	list, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		if apierrors.IsForbidden(err) {
-			writeInfo(cmd, err.Error())
-			return nil
-		}
-		return err
+	// Give up the worker of this collector while its items run so that the
+	// items cannot wait on it.
+	if export.workers != nil {
+		<-export.workers
+		defer func() { export.workers <- struct{}{} }()
 	}
 
-	var buf bytes.Buffer
-	w := tabwriter.NewWriter(&buf, 10, 1, 1, ' ', tabwriter.Debug)
-	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", "NAME",
-		"STATUS", "ROLES", "AGE", "VERSION", "INTERNAL-IP", "EXTERNAL-IP",
-		"OS-IMAGE", "KERNEL-VERSION", "CONTAINER-RUNTIME")
+	entries := make([][]archiveEntry, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if export.workers != nil {
+				export.workers <- struct{}{}
+				defer func() { <-export.workers }()
+			}
 
-	for _, item := range list.Items {
+			item := *export
+			item.entries = &entries[i]
+			errs[i] = fn(&item, i)
+		}(i)
+	}
+	wg.Wait()
 
-		b, err := yaml.Marshal(item)
+	for i := range entries {
+		*export.entries = append(*export.entries, entries[i]...)
+	}
+	for _, err := range errs {
 		if err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		path := clusterName + "/nodes/" + item.GetName() + ".yaml"
-		if err := writeTar(tw, b, path, cmd); err != nil {
-			return err
-		}
+// supportCollector gathers one section of a support export. Either or both of
+// its functions may be set.
+type supportCollector struct {
+	name        string
+	description string
 
-		// NAME
-		fmt.Fprintf(w, "%s\t", item.GetName())
+	// shared gathers what is common to every PostgresCluster. It is called
+	// once and writes to the shared directory of the archive.
+	shared func(context.Context, *supportExport) error
 
-		// STATUS
-		for _, c := range item.Status.Conditions {
-			if c.Type == "Ready" {
-				if c.Status == "True" {
-					fmt.Fprintf(w, "%s\t", "Ready")
-				} else {
-					fmt.Fprintf(w, "%s\t", "Not Ready")
-				}
-			}
-		}
+	// collect gathers one PostgresCluster. It is called once for each.
+	collect func(context.Context, *supportExport) error
+}
 
-		// ROLES
-		rolePrefix := "node-role.kubernetes.io/"
-		for k := range item.Labels {
+// supportCollectors lists every section of a support export in the order they
+// are collected. Each name can be passed to the --include and --exclude flags.
+var supportCollectors = []supportCollector{
+	{name: "cli-version", description: "PGO CLI version", shared: gatherPGOCLIVersion},
+	{name: "context", description: "Current Kubernetes context", shared: gatherKubeContext},
+	{name: "server-version", description: "Kubernetes server version", shared: gatherKubeServerVersion},
+	{name: "nodes", description: "Nodes of the Kubernetes cluster", shared: gatherNodes},
+	{name: "namespace", description: "Namespace of the PostgresClusters", shared: gatherCurrentNamespace},
+	{name: "postgrescluster", description: "PostgresCluster spec and status", collect: gatherClusterSpec},
+	{name: "resources", description: "API resources in the PostgresClusters' Namespace",
+		shared: gatherNamespaceResources, collect: gatherClusterResources},
+	{name: "events", description: "Events in the PostgresClusters' Namespace", shared: gatherEvents},
+	{name: "describe", description: "Descriptions of PostgresCluster Pods, StatefulSets and PVCs",
+		collect: gatherDescriptions},
+	{name: "instances", description: "Instances of the PostgresCluster and which one is the primary",
+		collect: gatherInstances},
+	{name: "pglogs", description: "Postgres log files of every instance", collect: gatherPostgresqlLogs},
+	{name: "pods", description: "Current and previous logs of every PostgresCluster Pod",
+		collect: gatherClusterPodLogs},
+	{name: "monitoring", description: "Deployments, ConfigMaps, active alerts and logs of the monitoring stack",
+		shared: gatherMonitoring},
+	{name: "metrics", description: "Snapshot of the metrics of every instance's exporter",
+		collect: gatherExporterMetrics},
+	{name: "operator", description: "Operator Deployment, logs, CRDs, RBAC and webhooks",
+		shared: gatherOperator},
+	{name: "patroni", description: "Patroni cluster state and history seen by every instance",
+		collect: gatherPatroniInfo},
+	{name: "diagnostics", description: "Postgres, pgBackRest and Patroni diagnostics of every instance",
+		collect: gatherDiagnostics},
+	{name: "processes", description: "Running processes of every PostgresCluster container",
+		collect: gatherProcessInfo},
+}
+
+// sectionsHelp describes every section of a support export for the help text.
+func sectionsHelp() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, c := range supportCollectors {
+		fmt.Fprintf(w, "    %s\t%s\n", c.name, c.description)
+	}
+	_ = w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// enabledSections returns the names of collectors that should run given the
+// --include and --exclude flags. An empty include list means every collector.
+func enabledSections(include, exclude []string) (map[string]bool, error) {
+	known := make(map[string]bool, len(supportCollectors))
+	var names []string
+	for _, c := range supportCollectors {
+		known[c.name] = true
+		names = append(names, c.name)
+	}
+
+	for _, name := range append(append([]string{}, include...), exclude...) {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown section %q: expected one of %s",
+				name, strings.Join(names, ", "))
+		}
+	}
+
+	enabled := make(map[string]bool, len(supportCollectors))
+	for _, name := range names {
+		enabled[name] = len(include) == 0 || containsString(include, name)
+	}
+	for _, name := range exclude {
+		enabled[name] = false
+	}
+	return enabled, nil
+}
+
+const (
+	// sectionComplete means a collector gathered everything it could.
+	sectionComplete = "complete"
+
+	// sectionPartial means a collector finished but could not gather some
+	// things, for example because of missing RBAC permissions.
+	sectionPartial = "partial"
+
+	// sectionFailed means a collector stopped because of an error.
+	sectionFailed = "failed"
+
+	// sectionExcluded means a collector was excluded by a flag.
+	sectionExcluded = "excluded"
+)
+
+const (
+	// exitPartial is the exit code of a support export that wrote an archive
+	// but could not collect everything. An export that failed entirely exits
+	// with 1, like any other command that returns an error.
+	exitPartial = 2
+)
+
+// sectionResult records the outcome of one collector.
+type sectionResult struct {
+	Name     string   `json:"name"`
+	Cluster  string   `json:"cluster,omitempty"`
+	Status   string   `json:"status"`
+	Duration string   `json:"duration,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// label names the section and, when it gathered one PostgresCluster, that
+// cluster.
+func (result sectionResult) label() string {
+	if result.Cluster == "" {
+		return result.Name
+	}
+	return result.Name + " (" + result.Cluster + ")"
+}
+
+// collectorTask is one call of a collector: either of its shared function or
+// of its function for one PostgresCluster.
+type collectorTask struct {
+	collector supportCollector
+	cluster   *unstructured.Unstructured // nil for the shared function
+}
+
+// runCollectors calls every enabled collector concurrently, using at most
+// --parallelism workers, and records the outcome of each. The shared function
+// of a collector is called once, and its other function is called once for
+// each PostgresCluster. A collector that fails does not prevent the others
+// from running. The calling goroutine is the only one that writes to the
+// archive: it writes the files of each call in registry order, then cluster
+// order, as soon as that call is done, so the archive is the same regardless
+// of the order in which calls finish.
+func runCollectors(ctx context.Context,
+	export *supportExport,
+	collectors []supportCollector,
+	enabled map[string]bool,
+) []sectionResult {
+	var tasks []collectorTask
+	for _, c := range collectors {
+		if c.shared != nil {
+			tasks = append(tasks, collectorTask{collector: c})
+		}
+		if c.collect != nil {
+			for _, cluster := range export.clusters {
+				tasks = append(tasks, collectorTask{collector: c, cluster: cluster})
+			}
+		}
+	}
+
+	results := make([]sectionResult, len(tasks))
+	sections := make([]exportSection, len(tasks))
+	entries := make([][]archiveEntry, len(tasks))
+	done := make([]chan struct{}, len(tasks))
+
+	for i, task := range tasks {
+		c := task.collector
+		sections[i].result.Name = c.name
+		if task.cluster != nil {
+			sections[i].result.Cluster = task.cluster.GetName()
+		}
+		done[i] = make(chan struct{})
+
+		if !enabled[c.name] {
+			sections[i].result.Status = sectionExcluded
+			writeDebug(export.cmd, fmt.Sprintf("Section %s excluded\n", c.name))
+			close(done[i])
+			continue
+		}
+
+		go func(i int, task collectorTask) {
+			defer close(done[i])
+			if export.workers != nil {
+				export.workers <- struct{}{}
+				defer func() { <-export.workers }()
+			}
+
+			section := *export
+			section.section = &sections[i]
+			section.entries = &entries[i]
+
+			collect := task.collector.shared
+			if task.cluster != nil {
+				collect = task.collector.collect
+				section.cluster = task.cluster
+				section.clusterName = task.cluster.GetName()
+			}
+
+			start := time.Now()
+			err := collect(ctx, &section)
+
+			sections[i].Lock()
+			defer sections[i].Unlock()
+			result := &sections[i].result
+			result.Duration = time.Since(start).Round(time.Millisecond).String()
+
+			switch {
+			case err != nil:
+				writeInfo(export.cmd, fmt.Sprintf("Section %s failed: %s", result.label(), err))
+				result.Status = sectionFailed
+				result.Errors = append(result.Errors, err.Error())
+			case len(result.Errors) > 0:
+				result.Status = sectionPartial
+			default:
+				result.Status = sectionComplete
+			}
+		}(i, task)
+	}
+
+	for i := range tasks {
+		<-done[i]
+
+		var err error
+		for _, entry := range entries[i] {
+			if err == nil {
+				err = export.budget.write(export.archive, export.manifest, entry, export.cmd)
+			}
+			entry.close()
+		}
+		entries[i] = nil
+
+		result := &sections[i].result
+		if err != nil {
+			result.Status = sectionFailed
+			result.Errors = append(result.Errors, err.Error())
+		}
+
+		results[i] = *result
+	}
+
+	return results
+}
+
+// exportStatus summarizes results as a whole: complete when every enabled
+// section is complete, failed when every enabled section failed, and partial
+// otherwise.
+func exportStatus(results []sectionResult) string {
+	var complete, failed, total int
+	for _, result := range results {
+		switch result.Status {
+		case sectionExcluded:
+			continue
+		case sectionComplete:
+			complete++
+		case sectionFailed:
+			failed++
+		}
+		total++
+	}
+
+	switch {
+	case complete == total:
+		return sectionComplete
+	case failed == total:
+		return sectionFailed
+	default:
+		return sectionPartial
+	}
+}
+
+// exportResultError returns an error describing the sections that were not
+// fully collected, or nil when the export is complete. The error of a partial
+// export carries the exitPartial exit code.
+func exportResultError(results []sectionResult) error {
+	status := exportStatus(results)
+	if status == sectionComplete {
+		return nil
+	}
+
+	var incomplete []string
+	for _, result := range results {
+		if result.Status == sectionFailed || result.Status == sectionPartial {
+			incomplete = append(incomplete, result.Name)
+		}
+	}
+
+	err := fmt.Errorf("support export is %s; sections not fully collected: %s",
+		status, strings.Join(incomplete, ", "))
+	if status == sectionPartial {
+		return &ExitError{Code: exitPartial, Err: err}
+	}
+	return err
+}
+
+// exportFailureReport defines the message displayed when some sections of a
+// support export could not be fully collected.
+func exportFailureReport(results []sectionResult) string {
+	var b strings.Builder
+	for _, result := range results {
+		if result.Status != sectionFailed && result.Status != sectionPartial {
+			continue
+		}
+		fmt.Fprintf(&b, "| %s: %s (%d errors)\n", result.label(), result.Status, len(result.Errors))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+
+	return preBox + "\n| Some sections were not fully collected. Details are in\n" +
+		"| the summary.json and errors.json files of the archive.\n" +
+		b.String() + postBox + "\n"
+}
+
+// gatherSummary writes the outcome of each collector to the archive along
+// with a separate list of every error
+func gatherSummary(export *supportExport, results []sectionResult) error {
+	writeInfo(export.cmd, "Collecting export summary...")
+	b, err := json.MarshalIndent(struct {
+		Status    string          `json:"status"`
+		Sections  []sectionResult `json:"sections"`
+		Truncated []truncation    `json:"truncated"`
+	}{
+		Status:    exportStatus(results),
+		Sections:  results,
+		Truncated: append([]truncation{}, export.budget.truncated...),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := export.sharedDir + "/summary.json"
+	if err := export.writeNow(path, b); err != nil {
+		return err
+	}
+
+	type sectionError struct {
+		Section string `json:"section"`
+		Error   string `json:"error"`
+	}
+	errs := []sectionError{}
+	for _, result := range results {
+		for _, e := range result.Errors {
+			errs = append(errs, sectionError{Section: result.Name, Error: e})
+		}
+	}
+
+	b, err = json.MarshalIndent(errs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path = export.sharedDir + "/errors.json"
+	if err := export.writeNow(path, b); err != nil {
+		return err
+	}
+	return nil
+}
+
+// exportSizeReport defines the message displayed when a support export archive
+// is created. If the size of the archive file is greater than 25MiB, an alternate
+// message is displayed.
+func exportSizeReport(size float64) string {
+
+	finalMsg := preBox + fmt.Sprintf(msg1, size/mebibyte)
+
+	// if file size is > 25 MiB, print alternate message
+	if size > mebibyte*25 {
+		finalMsg = preBox + fmt.Sprintf(msg2, size/mebibyte)
+	}
+	finalMsg = finalMsg + postBox + "\n"
+
+	return finalMsg
+}
+
+// exportUploadReport defines the message displayed when a support export
+// archive is uploaded.
+func exportUploadReport(size float64, location string) string {
+	return preBox + fmt.Sprintf(msg3, size/mebibyte, location) + postBox + "\n"
+}
+
+// uploadArchive sends the archive file in dir to uploader and returns where
+// it was uploaded. The file is kept when the upload fails.
+func uploadArchive(ctx context.Context, uploader archiveUploader, dir, name string) (string, error) {
+	// #nosec G304 -- We intentionally read the archive written to the directory supplied by the user.
+	file, err := os.Open(dir + "/" + name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	location, err := uploader.upload(ctx, name, file, info.Size())
+	if err != nil {
+		return "", fmt.Errorf("archive %s was written but not uploaded: %w", dir+"/"+name, err)
+	}
+	return location, nil
+}
+
+// gatherPGOCLIVersion collects the PGO CLI version
+func gatherPGOCLIVersion(_ context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting PGO CLI version...")
+	path := export.sharedDir + "/pgo-cli-version"
+	if err := export.write(sourceExport, path, []byte(clientVersion)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// gatherKubeContext collects the current Kubernetes context
+func gatherKubeContext(_ context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting current Kubernetes context...")
+	path := export.sharedDir + "/current-context"
+
+	rawConfig, err := export.config.ConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := export.write(sourceExport, path, []byte(rawConfig.CurrentContext)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// gatherKubeServerVersion collects the server version from the Kubernetes cluster
+func gatherKubeServerVersion(_ context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting Kubernetes version...")
+	ver, err := export.clientset.Discovery().ServerVersion()
+	if err != nil {
+		return err
+	}
+
+	path := export.sharedDir + "/server-version"
+	if err := export.write(sourceAPI, path, []byte(ver.String())); err != nil {
+		return err
+	}
+	return nil
+}
+
+// gatherNodes gets list of nodes in the Kubernetes Cluster and prints them
+// to a file using the `-o wide` output
+func gatherNodes(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting nodes...")
+	list, err := export.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			export.warn(err)
+			return nil
+		}
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 10, 1, 1, ' ', tabwriter.Debug)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", "NAME",
+		"STATUS", "ROLES", "AGE", "VERSION", "INTERNAL-IP", "EXTERNAL-IP",
+		"OS-IMAGE", "KERNEL-VERSION", "CONTAINER-RUNTIME")
+
+	for _, item := range list.Items {
+
+		path := export.sharedDir + "/nodes/" + item.GetName() + ".yaml"
+		b, err := export.redact.marshal(path, item)
+		if err != nil {
+			return err
+		}
+
+		if err := export.write(sourceAPI, path, b); err != nil {
+			return err
+		}
+
+		// NAME
+		fmt.Fprintf(w, "%s\t", item.GetName())
+
+		// STATUS
+		for _, c := range item.Status.Conditions {
+			if c.Type == "Ready" {
+				if c.Status == "True" {
+					fmt.Fprintf(w, "%s\t", "Ready")
+				} else {
+					fmt.Fprintf(w, "%s\t", "Not Ready")
+				}
+			}
+		}
+
+		// ROLES
+		rolePrefix := "node-role.kubernetes.io/"
+		for k := range item.Labels {
 			if strings.Contains(k, rolePrefix) {
 				sa := strings.Split(k, rolePrefix)
 				if len(sa) > 1 {
@@ -580,8 +1676,8 @@ func gatherNodes(ctx context.Context,
 		return err
 	}
 
-	path := clusterName + "/nodes/list"
-	if err := writeTar(tw, buf.Bytes(), path, cmd); err != nil {
+	path := export.sharedDir + "/nodes/list"
+	if err := export.write(sourceAPI, path, buf.Bytes()); err != nil {
 		return err
 	}
 
@@ -589,69 +1685,77 @@ func gatherNodes(ctx context.Context,
 }
 
 // gatherCurrentNamespace collects the yaml output of the current namespace
-func gatherCurrentNamespace(ctx context.Context,
-	clientset *kubernetes.Clientset,
-	namespace string,
-	clusterName string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
-) error {
-	writeInfo(cmd, "Collecting namespace...")
-	get, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+func gatherCurrentNamespace(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting namespace...")
+	get, err := export.clientset.CoreV1().Namespaces().Get(ctx, export.namespace, metav1.GetOptions{})
 	if err != nil {
 		if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
-			writeInfo(cmd, err.Error())
+			export.warn(err)
 			return nil
 		}
 		return err
 	}
 
-	b, err := yaml.Marshal(get)
+	path := export.sharedDir + "/current-namespace.yaml"
+	b, err := export.redact.marshal(path, get)
 	if err != nil {
 		return err
 	}
 
-	path := clusterName + "/current-namespace.yaml"
-	if err = writeTar(tw, b, path, cmd); err != nil {
+	if err = export.write(sourceAPI, path, b); err != nil {
 		return err
 	}
 	return nil
 }
 
-func gatherClusterSpec(postgresCluster *unstructured.Unstructured,
-	clusterName string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
-) error {
-	writeInfo(cmd, "Collecting PostgresCluster...")
-	b, err := yaml.Marshal(postgresCluster)
+// gatherClusterSpec collects the yaml output of the PostgresCluster
+func gatherClusterSpec(_ context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting PostgresCluster...")
+	path := export.clusterName + "/postgrescluster.yaml"
+	b, err := export.redact.marshal(path, export.cluster)
 	if err != nil {
 		return err
 	}
 
-	path := clusterName + "/postgrescluster.yaml"
-	if err := writeTar(tw, b, path, cmd); err != nil {
+	if err := export.write(sourceAPI, path, b); err != nil {
 		return err
 	}
 	return nil
 }
 
+// gatherClusterResources collects the namespaced resources that have the
+// cluster label
+func gatherClusterResources(ctx context.Context, export *supportExport) error {
+	nsListOpts := metav1.ListOptions{
+		LabelSelector: util.LabelCluster + "=" + export.clusterName,
+	}
+	return gatherNamespacedAPIResources(ctx, export, export.clusterName,
+		clusterNamespacedResources, nsListOpts)
+}
+
+// gatherNamespaceResources collects the namespaced resources that do not have
+// the cluster label but may otherwise impact the operation of every
+// PostgresCluster in the namespace
+func gatherNamespaceResources(ctx context.Context, export *supportExport) error {
+	otherListOpts := metav1.ListOptions{}
+	return gatherNamespacedAPIResources(ctx, export, export.sharedDir,
+		otherNamespacedResources, otherListOpts)
+}
+
 // gatherNamespacedAPIResources writes yaml and list output for each api-resource
 // defined to an file. Using statefulsets as an example, two (or more) files will be created
 // one with a list of statefulsets that were found and one yaml file for each
-// statefulset
+// statefulset. The files are written to dir.
 func gatherNamespacedAPIResources(ctx context.Context,
-	client dynamic.Interface,
-	namespace string,
-	clusterName string,
+	export *supportExport,
+	dir string,
 	namespacedResources []schema.GroupVersionResource,
 	listOpts metav1.ListOptions,
-	tw *tar.Writer,
-	cmd *cobra.Command,
 ) error {
-	for _, gvr := range namespacedResources {
-		writeInfo(cmd, "Collecting "+gvr.Resource+"...")
-		list, err := client.Resource(gvr).Namespace(namespace).List(ctx, listOpts)
+	return export.parallel(len(namespacedResources), func(export *supportExport, i int) error {
+		gvr := namespacedResources[i]
+		writeInfo(export.cmd, "Collecting "+gvr.Resource+"...")
+		list, err := export.dynamicClient.Resource(gvr).Namespace(export.namespace).List(ctx, listOpts)
 		// If the API returns an IsNotFound error, it is likely because the kube version in use
 		// doesn't support the version of the resource we are attempting to use and there is an
 		// earlier version we can use. This block will check the "removed" resources for a match
@@ -660,25 +1764,22 @@ func gatherNamespacedAPIResources(ctx context.Context,
 			for _, bgvr := range removedNamespacedResources {
 				if bgvr.Resource == gvr.Resource {
 					gvr = bgvr
-					list, err = client.Resource(gvr).Namespace(namespace).
+					list, err = export.dynamicClient.Resource(gvr).Namespace(export.namespace).
 						List(ctx, listOpts)
 					break
 				}
 			}
 		}
 		if err != nil {
-			if apierrors.IsForbidden(err) {
-				writeInfo(cmd, err.Error())
-				// Continue and output errors for each resource type
-				// Allow the user to see and address all issues at once
-				continue
-			}
-			return err
+			// Continue and output errors for each resource type
+			// Allow the user to see and address all issues at once
+			export.warn(err)
+			return nil
 		}
 		if len(list.Items) == 0 {
 			// If we didn't find any resources, skip
-			writeInfo(cmd, fmt.Sprintf("Resource %s not found, skipping", gvr.Resource))
-			continue
+			writeInfo(export.cmd, fmt.Sprintf("Resource %s not found, skipping", gvr.Resource))
+			return nil
 		}
 
 		// Create a buffer to generate string with the table formatted list
@@ -690,40 +1791,34 @@ func gatherNamespacedAPIResources(ctx context.Context,
 
 		// Define the file name/path where the list file will be created and
 		// write to the tar
-		path := clusterName + "/" + gvr.Resource + "/list"
-		if err := writeTar(tw, buf.Bytes(), path, cmd); err != nil {
+		path := dir + "/" + gvr.Resource + "/list"
+		if err := export.write(sourceAPI, path, buf.Bytes()); err != nil {
 			return err
 		}
 
 		for _, obj := range list.Items {
-			b, err := yaml.Marshal(obj)
+			path := dir + "/" + gvr.Resource + "/" + obj.GetName() + ".yaml"
+			b, err := export.redact.marshal(path, obj.Object)
 			if err != nil {
 				return err
 			}
 
-			path := clusterName + "/" + gvr.Resource + "/" + obj.GetName() + ".yaml"
-			if err := writeTar(tw, b, path, cmd); err != nil {
+			if err := export.write(sourceAPI, path, b); err != nil {
 				return err
 			}
 		}
-	}
-	return nil
+		return nil
+	})
 }
 
 // gatherEvents gathers all events from a namespace, selects information (based on
 // what kubectl outputs), formats the data then prints to the tar file
-func gatherEvents(ctx context.Context,
-	clientset *kubernetes.Clientset,
-	namespace string,
-	clusterName string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
-) error {
-	writeInfo(cmd, "Collecting events...")
-	list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+func gatherEvents(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting events...")
+	list, err := export.clientset.CoreV1().Events(export.namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		if apierrors.IsForbidden(err) {
-			writeInfo(cmd, err.Error())
+			export.warn(err)
 			return nil
 		}
 		return err
@@ -745,6 +1840,10 @@ func gatherEvents(ctx context.Context,
 	p := printers.GetNewTabWriter(&buf)
 	fmt.Fprintf(p, "Last Seen\tTYPE\tREASON\tOBJECT\tMESSAGE\n")
 	for _, event := range list.Items {
+		if first, last := eventTimes(event); !first.IsZero() && !export.window.overlaps(first, last) {
+			continue
+		}
+
 		var interval string
 		firstTimestampSince := translateMicroTimestampSince(event.EventTime)
 		if event.EventTime.IsZero() {
@@ -768,88 +1867,122 @@ func gatherEvents(ctx context.Context,
 		return err
 	}
 
-	path := clusterName + "/events"
-	if err := writeTar(tw, buf.Bytes(), path, cmd); err != nil {
+	path := export.sharedDir + "/events"
+	if err := export.write(sourceAPI, path, export.redact.text(path, buf.Bytes())); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// gatherLogs takes a client and buffer to write logs to a buffer
-func gatherPostgresqlLogs(ctx context.Context,
-	clientset *kubernetes.Clientset,
-	config *rest.Config,
-	namespace string,
-	clusterName string,
-	numLogs int,
-	tw *tar.Writer,
-	cmd *cobra.Command,
-) error {
-	writeInfo(cmd, "Collecting Postgres logs...")
-	// Get the primary instance Pod by its labels
-	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		// TODO(jmckulk): should we be getting replica logs?
-		LabelSelector: util.PrimaryInstanceLabels(clusterName),
-	})
-	if err != nil {
+// gatherDescriptions writes a description, like that of 'kubectl describe', of
+// every PostgresCluster Pod, StatefulSet and PersistentVolumeClaim along with
+// the events about each of them
+func gatherDescriptions(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting descriptions...")
+	core := export.clientset.CoreV1()
+	listOpts := metav1.ListOptions{
+		LabelSelector: util.LabelCluster + "=" + export.clusterName,
+	}
+
+	// A list that is forbidden is reported and left empty so that the other
+	// descriptions can still be written.
+	forbidden := func(err error) error {
 		if apierrors.IsForbidden(err) {
-			writeInfo(cmd, err.Error())
+			export.warn(err)
 			return nil
 		}
 		return err
 	}
-	if len(pods.Items) != 1 {
-		writeInfo(cmd, "No primary instance pod found for gathering logs")
-		return nil
-	}
 
-	podExec, err := util.NewPodExecutor(config)
+	var events []corev1.Event
+	eventList, err := core.Events(export.namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
-		return err
+		if err := forbidden(err); err != nil {
+			return err
+		}
+	} else {
+		for _, event := range eventList.Items {
+			if first, last := eventTimes(event); first.IsZero() || export.window.overlaps(first, last) {
+				events = append(events, event)
+			}
+		}
 	}
 
-	exec := func(stdin io.Reader, stdout, stderr io.Writer, command ...string,
-	) error {
-		return podExec(namespace, pods.Items[0].GetName(), util.ContainerDatabase,
-			stdin, stdout, stderr, command...)
+	pods := &corev1.PodList{}
+	if list, err := core.Pods(export.namespace).List(ctx, listOpts); err != nil {
+		if err := forbidden(err); err != nil {
+			return err
+		}
+	} else {
+		pods = list
 	}
 
-	stdout, stderr, err := Executor(exec).listPGLogFiles(numLogs)
-	if err != nil {
-		if apierrors.IsForbidden(err) {
-			writeInfo(cmd, err.Error())
-			return nil
+	statefulSets := &appsv1.StatefulSetList{}
+	if list, err := export.clientset.AppsV1().StatefulSets(export.namespace).List(ctx, listOpts); err != nil {
+		if err := forbidden(err); err != nil {
+			return err
 		}
-		return err
+	} else {
+		statefulSets = list
 	}
-	if stderr != "" {
-		writeInfo(cmd, stderr)
+
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if list, err := core.PersistentVolumeClaims(export.namespace).List(ctx, listOpts); err != nil {
+		if err := forbidden(err); err != nil {
+			return err
+		}
+	} else {
+		pvcs = list
 	}
 
-	logFiles := strings.Split(strings.TrimSpace(stdout), "\n")
-	for _, logFile := range logFiles {
-		var buf bytes.Buffer
+	// env redacts environment variables like the YAML of every object.
+	env := func(path string) describeEnv {
+		return func(container, name, value string) string {
+			redacted, reason := export.redact.envValue(name, value)
+			if reason != "" {
+				export.redact.record(path, container+".env."+name, reason)
+			}
+			return redacted
+		}
+	}
 
-		stdout, stderr, err := Executor(exec).catFile(logFile)
-		if err != nil {
-			if apierrors.IsForbidden(err) {
-				writeInfo(cmd, err.Error())
-				// Continue and output errors for each log file
-				// Allow the user to see and address all issues at once
-				continue
-			}
+	write := func(path string, describe func(w io.Writer) error) error {
+		var buf bytes.Buffer
+		if err := describe(&buf); err != nil {
 			return err
 		}
+		return export.write(sourceAPI, path, export.redact.text(path, buf.Bytes()))
+	}
 
-		buf.Write([]byte(stdout))
-		if stderr != "" {
-			str := fmt.Sprintf("\nError returned: %s\n", stderr)
-			buf.Write([]byte(str))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		path := export.clusterName + "/describe/pods/" + pod.Name
+		if err := write(path, func(w io.Writer) error {
+			return describePod(w, pod, eventsFor(events, "Pod", pod), env(path))
+		}); err != nil {
+			return err
 		}
+	}
+
+	for i := range statefulSets.Items {
+		sts := &statefulSets.Items[i]
+		path := export.clusterName + "/describe/statefulsets/" + sts.Name
+		if err := write(path, func(w io.Writer) error {
+			return describeStatefulSet(w, sts, pods.Items,
+				eventsFor(events, "StatefulSet", sts), env(path))
+		}); err != nil {
+			return err
+		}
+	}
 
-		path := clusterName + "/logs/postgresql/" + logFile
-		if err := writeTar(tw, buf.Bytes(), path, cmd); err != nil {
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		path := export.clusterName + "/describe/persistentvolumeclaims/" + pvc.Name
+		if err := write(path, func(w io.Writer) error {
+			return describePersistentVolumeClaim(w, pvc, pods.Items,
+				eventsFor(events, "PersistentVolumeClaim", pvc))
+		}); err != nil {
 			return err
 		}
 	}
@@ -857,218 +1990,1037 @@ func gatherPostgresqlLogs(ctx context.Context,
 	return nil
 }
 
-// gatherPodLogs uses the clientset to gather logs from each container in every
-// pod
-func gatherPodLogs(ctx context.Context,
-	clientset *kubernetes.Clientset,
-	namespace string,
-	labelSelector string,
-	rootDir string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
-) error {
-	// Get all Pods that match the given Label
-	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
+// eventTimes returns when event was first and last seen. Both are zero when
+// the event has no time at all.
+func eventTimes(event corev1.Event) (first, last time.Time) {
+	first = event.EventTime.Time
+	if first.IsZero() {
+		first = event.FirstTimestamp.Time
+	}
+
+	switch {
+	case event.Series != nil && !event.Series.LastObservedTime.IsZero():
+		last = event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		last = event.LastTimestamp.Time
+	default:
+		last = first
+	}
+	if first.IsZero() {
+		first = last
+	}
+	return first, last
+}
+
+// instancePod is the Pod of one PostgresCluster instance.
+type instancePod struct {
+	name    string // the instance, which is also the name of its StatefulSet
+	set     string // the instance set
+	pod     *corev1.Pod
+	primary bool
+}
+
+// listInstancePods returns the Pod of every instance of the PostgresCluster
+// in every instance set, sorted by instance name.
+func listInstancePods(ctx context.Context, export *supportExport) ([]instancePod, error) {
+	pods, err := export.clientset.CoreV1().Pods(export.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: util.InstanceLabels(export.clusterName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]instancePod, 0, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		name := pod.Labels[util.LabelInstance]
+		if name == "" {
+			name = pod.Name
+		}
+		instances = append(instances, instancePod{
+			name:    name,
+			set:     pod.Labels[util.LabelInstanceSet],
+			pod:     pod,
+			primary: pod.Labels[util.LabelRole] == util.RolePatroniLeader,
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].name < instances[j].name
 	})
+	return instances, nil
+}
+
+// exec returns an Executor that runs commands in the database container of
+// the instance.
+func (instance instancePod) exec(export *supportExport) Executor {
+	return func(stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return export.podExec(instance.pod.Namespace, instance.pod.Name, util.ContainerDatabase,
+			stdin, stdout, stderr, command...)
+	}
+}
+
+// role returns "primary" or "replica".
+func (instance instancePod) role() string {
+	if instance.primary {
+		return "primary"
+	}
+	return "replica"
+}
+
+// instanceDir returns the directory of the archive that holds the files of
+// one instance.
+func (export *supportExport) instanceDir(instance instancePod) string {
+	return export.clusterName + "/instances/" + instance.name
+}
+
+// gatherInstances writes a table of every instance of the PostgresCluster
+// that marks which one is the primary.
+func gatherInstances(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting instances...")
+	instances, err := listInstancePods(ctx, export)
 	if err != nil {
 		if apierrors.IsForbidden(err) {
-			writeInfo(cmd, err.Error())
+			export.warn(err)
 			return nil
 		}
 		return err
 	}
+	if len(instances) == 0 {
+		writeInfo(export.cmd, "No instance pods found, skipping")
+		return nil
+	}
 
-	if len(pods.Items) == 0 {
-		// If we didn't find any Pods, skip
-		writeInfo(cmd, fmt.Sprintf("%s Pods not found, skipping", rootDir))
+	var buf bytes.Buffer
+	w := printers.GetNewTabWriter(&buf)
+	fmt.Fprintf(w, "INSTANCE\tINSTANCE SET\tPOD\tROLE\tNODE\tPHASE\n")
+	for _, instance := range instances {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", instance.name, instance.set,
+			instance.pod.Name, instance.role(), instance.pod.Spec.NodeName, instance.pod.Status.Phase)
+		if instance.primary {
+			writeInfo(export.cmd, "Primary instance: "+instance.name)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
 	}
 
-	for _, pod := range pods.Items {
-		containers := pod.Spec.Containers
-		containers = append(containers, pod.Spec.InitContainers...)
-		for _, container := range containers {
-			result := clientset.CoreV1().Pods(namespace).
-				GetLogs(pod.GetName(), &corev1.PodLogOptions{
-					// TODO (jmckulk): we have the option to grab previous logs
-					Container: container.Name,
-				}).Do(ctx)
-
-			if result.Error() != nil {
-				if apierrors.IsForbidden(result.Error()) {
-					writeInfo(cmd, result.Error().Error())
-					// Continue and output errors for each pod log
-					// Allow the user to see and address all issues at once
-					continue
+	return export.write(sourceAPI, export.clusterName+"/instances/roles", buf.Bytes())
+}
+
+// gatherPostgresqlLogs gathers the Postgres log files of every instance. It
+// collects the --pg-logs-count most recent files of each, or those written
+// during the time window.
+func gatherPostgresqlLogs(ctx context.Context, export *supportExport) error {
+	if export.numLogs <= 0 {
+		writeInfo(export.cmd, "Postgres log count is zero, skipping")
+		return nil
+	}
+
+	writeInfo(export.cmd, "Collecting Postgres logs...")
+	instances, err := listInstancePods(ctx, export)
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			export.warn(err)
+			return nil
+		}
+		return err
+	}
+	if len(instances) == 0 {
+		writeInfo(export.cmd, "No instance pods found for gathering logs")
+		return nil
+	}
+
+	return export.parallel(len(instances), func(export *supportExport, i int) error {
+		instance := instances[i]
+		exec := instance.exec(export)
+
+		stdout, stderr, err := exec.listPGLogFiles()
+		if err != nil {
+			// Continue so one instance does not hide the others
+			export.warn(fmt.Errorf("instance %s: %w", instance.name, err))
+			return nil
+		}
+		if stderr != "" {
+			writeInfo(export.cmd, stderr)
+		}
+
+		files, err := parsePGLogFiles(stdout)
+		if err != nil {
+			export.warn(fmt.Errorf("instance %s: %w", instance.name, err))
+			return nil
+		}
+
+		logFiles := selectPGLogFiles(files, export.numLogs, export.window)
+		if len(logFiles) == 0 {
+			writeInfo(export.cmd, fmt.Sprintf(
+				"No Postgres log files found on instance %s, skipping", instance.name))
+			return nil
+		}
+		return export.parallel(len(logFiles), func(export *supportExport, i int) error {
+			logFile := logFiles[i]
+			path := export.instanceDir(instance) + "/" + logFile
+
+			err := export.stream(sourceExec, path, export.maxLogSize, func(w io.Writer) error {
+				stderr, err := exec.catFile(logFile, w)
+				if err == nil && stderr != "" {
+					_, err = fmt.Fprintf(w, "\nError returned: %s\n", stderr)
 				}
 				return err
-			}
-
-			b, err := result.Raw()
+			})
 			if err != nil {
-				return err
+				// Continue and output errors for each log file
+				// Allow the user to see and address all issues at once
+				export.warn(fmt.Errorf("failed to read %s of instance %s: %w",
+					logFile, instance.name, err))
 			}
+			return nil
+		})
+	})
+}
 
-			path := rootDir + "/logs/" + pod.GetName() + "/" + container.Name
-			if err := writeTar(tw, b, path, cmd); err != nil {
-				return err
-			}
+// gatherClusterPodLogs gathers the logs of every PostgresCluster Pod
+func gatherClusterPodLogs(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting PostgresCluster pod logs...")
+	return gatherPodLogs(ctx, export, export.namespace,
+		fmt.Sprintf("%s=%s", util.LabelCluster, export.clusterName), export.clusterName)
+}
+
+// monitoringAlerts are the APIs of the monitoring stack that list its active
+// alerts, by the name of the container that serves them.
+var monitoringAlerts = []struct {
+	container string
+	port      int32
+	path      string
+}{
+	{container: "prometheus", port: 9090, path: "/api/v1/alerts"},
+	{container: "alertmanager", port: 9093, path: "/api/v2/alerts?active=true&silenced=false&inhibited=false"},
+}
+
+// gatherMonitoring gathers the Deployments, ConfigMaps, active alerts and
+// Pod logs of the monitoring stack: Prometheus, Alertmanager and Grafana.
+func gatherMonitoring(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting monitoring deployments...")
+	namespace := export.monitoringNamespace
+	listOpts := metav1.ListOptions{LabelSelector: util.LabelMonitoring}
+
+	// The label may be on the Deployment or only on its Pods.
+	selector, err := labels.Parse(util.LabelMonitoring)
+	if err != nil {
+		return err
+	}
+	deployments, err := export.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if !apierrors.IsForbidden(err) {
+			return err
 		}
+		export.warn(err)
+		deployments = &appsv1.DeploymentList{}
 	}
+	for i := range deployments.Items {
+		if !selector.Matches(labels.Set(deployments.Items[i].Labels)) &&
+			!selector.Matches(labels.Set(deployments.Items[i].Spec.Template.Labels)) {
+			continue
+		}
 
-	return nil
+		path := "monitoring/deployments/" + deployments.Items[i].Name + ".yaml"
+		b, err := export.redact.marshal(path, &deployments.Items[i])
+		if err != nil {
+			return err
+		}
+		if err := export.write(sourceAPI, path, b); err != nil {
+			return err
+		}
+	}
+
+	writeInfo(export.cmd, "Collecting monitoring configmaps...")
+	configMaps, err := export.clientset.CoreV1().ConfigMaps(namespace).List(ctx, listOpts)
+	if err != nil {
+		if !apierrors.IsForbidden(err) {
+			return err
+		}
+		export.warn(err)
+		configMaps = &corev1.ConfigMapList{}
+	}
+	for i := range configMaps.Items {
+		path := "monitoring/configmaps/" + configMaps.Items[i].Name + ".yaml"
+		b, err := export.redact.marshal(path, &configMaps.Items[i])
+		if err != nil {
+			return err
+		}
+		if err := export.write(sourceAPI, path, b); err != nil {
+			return err
+		}
+	}
+
+	if err := gatherMonitoringAlerts(ctx, export); err != nil {
+		return err
+	}
+
+	writeInfo(export.cmd, "Collecting monitoring pod logs...")
+	return gatherPodLogs(ctx, export, namespace, util.LabelMonitoring, "monitoring")
 }
 
-// gatherExecInfo takes a client and buffer
-// execs into relevant pods to grab information
-func gatherPatroniInfo(ctx context.Context,
-	clientset *kubernetes.Clientset,
-	config *rest.Config,
-	namespace string,
-	clusterName string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
-) error {
-	writeInfo(cmd, "Collecting Patroni info...")
-	// Get the primary instance Pod by its labels
-	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: util.PrimaryInstanceLabels(clusterName),
+// gatherMonitoringAlerts asks Prometheus and Alertmanager for their active
+// alerts from within their containers. Their images have wget but not bash.
+func gatherMonitoringAlerts(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting monitoring alerts...")
+	pods, err := export.clientset.CoreV1().Pods(export.monitoringNamespace).List(ctx,
+		metav1.ListOptions{LabelSelector: util.LabelMonitoring})
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			export.warn(err)
+			return nil
+		}
+		return err
+	}
+
+	type alertSource struct {
+		pod       *corev1.Pod
+		container string
+		url       string
+	}
+	var sources []alertSource
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			for _, api := range monitoringAlerts {
+				if container.Name != api.container {
+					continue
+				}
+				port := api.port
+				if len(container.Ports) > 0 {
+					port = container.Ports[0].ContainerPort
+				}
+				sources = append(sources, alertSource{pod: pod, container: container.Name,
+					url: fmt.Sprintf("http://localhost:%d%s", port, api.path)})
+			}
+		}
+	}
+
+	return export.parallel(len(sources), func(export *supportExport, i int) error {
+		source := sources[i]
+		var stdout, stderr bytes.Buffer
+		err := export.podExec(source.pod.Namespace, source.pod.Name, source.container,
+			nil, &stdout, &stderr, "wget", "-q", "-O", "-", source.url)
+		if err != nil {
+			// Continue so one failing Pod does not hide the others
+			export.warn(fmt.Errorf("pod %s: %s alerts: %w: %s",
+				source.pod.Name, source.container, err, strings.TrimSpace(stderr.String())))
+			return nil
+		}
+
+		// Indent the JSON response so that it is easier to read
+		content := stdout.Bytes()
+		var indented bytes.Buffer
+		if json.Indent(&indented, content, "", "  ") == nil {
+			content = append(indented.Bytes(), '\n')
+		}
+
+		path := "monitoring/alerts/" + source.pod.Name + "/" + source.container + ".json"
+		return export.write(sourceExec, path, export.redact.text(path, content))
 	})
+}
+
+// exporterScheme returns the scheme of the exporter's metrics endpoint, which
+// uses TLS when the PostgresCluster has a certificate for it.
+func exporterScheme(cluster *unstructured.Unstructured) string {
+	if cluster != nil {
+		if _, found, _ := unstructured.NestedMap(cluster.Object,
+			"spec", "monitoring", "pgmonitor", "exporter", "customTLSSecret"); found {
+			return "https"
+		}
+	}
+	return "http"
+}
+
+// exporterPort returns the port of the exporter's metrics endpoint in pod and
+// whether pod has an exporter at all.
+func exporterPort(pod *corev1.Pod) (int32, bool) {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != util.ContainerExporter {
+			continue
+		}
+		port := int32(9187)
+		for _, p := range container.Ports {
+			if p.Name == util.ContainerExporter || len(container.Ports) == 1 {
+				port = p.ContainerPort
+			}
+		}
+		return port, true
+	}
+	return 0, false
+}
+
+// gatherExporterMetrics takes a snapshot of the metrics reported by the
+// exporter of every instance, as Prometheus would scrape them.
+func gatherExporterMetrics(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting exporter metrics...")
+	instances, err := listInstancePods(ctx, export)
 	if err != nil {
 		if apierrors.IsForbidden(err) {
-			writeInfo(cmd, err.Error())
+			export.warn(err)
 			return nil
 		}
 		return err
 	}
-	if len(pods.Items) < 1 {
-		writeInfo(cmd, "No pod found for patroni info")
+
+	var exporters []instancePod
+	ports := map[string]int32{}
+	for _, instance := range instances {
+		if port, ok := exporterPort(instance.pod); ok {
+			exporters = append(exporters, instance)
+			ports[instance.name] = port
+		}
+	}
+	if len(exporters) == 0 {
+		writeInfo(export.cmd, "No exporter found, skipping")
 		return nil
 	}
 
-	podExec, err := util.NewPodExecutor(config)
+	scheme := exporterScheme(export.cluster)
+	return export.parallel(len(exporters), func(export *supportExport, i int) error {
+		instance := exporters[i]
+		var exec Executor = func(stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+			return export.podExec(instance.pod.Namespace, instance.pod.Name, util.ContainerExporter,
+				stdin, stdout, stderr, command...)
+		}
+		if export.execTimeout > 0 {
+			exec = exec.withTimeout(export.execTimeout)
+		}
+
+		path := export.instanceDir(instance) + "/metrics"
+		err := export.stream(sourceExec, path, export.maxLogSize, func(w io.Writer) error {
+			stdout, stderr, err := exec.httpGet(scheme, ports[instance.name], "/metrics")
+			if err != nil {
+				return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+			}
+			_, err = io.WriteString(w, stdout)
+			return err
+		})
+		if err != nil {
+			// Continue so one failing instance does not hide the others
+			export.warn(fmt.Errorf("instance %s: metrics: %w", instance.name, err))
+		}
+		return nil
+	})
+}
+
+// operatorGroup is the API group of the operator's custom resources.
+const operatorGroup = "postgres-operator.crunchydata.com"
+
+// gatherOperator gathers the operator Deployment and its logs, the operator's
+// CRDs, the RBAC granted to the operator and the webhooks it serves. The
+// operator is found by its labels in the operator namespace or, when that is
+// not set, in every namespace.
+func gatherOperator(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting PGO deployment...")
+	deployments, err := export.clientset.AppsV1().Deployments(export.operatorNamespace).
+		List(ctx, metav1.ListOptions{LabelSelector: util.LabelControlPlane})
 	if err != nil {
-		return err
+		if !apierrors.IsForbidden(err) {
+			return err
+		}
+		export.warn(err)
+		deployments = &appsv1.DeploymentList{}
+	}
+	if len(deployments.Items) == 0 {
+		writeInfo(export.cmd, "PGO deployment not found, skipping")
 	}
 
-	exec := func(stdin io.Reader, stdout, stderr io.Writer, command ...string,
-	) error {
-		return podExec(namespace, pods.Items[0].GetName(), util.ContainerDatabase,
-			stdin, stdout, stderr, command...)
+	// The namespaces of the operator and the names of its ServiceAccounts
+	namespaces := map[string]bool{}
+	accounts := map[string]bool{}
+
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		namespaces[deployment.Namespace] = true
+
+		account := deployment.Spec.Template.Spec.ServiceAccountName
+		if account == "" {
+			account = "default"
+		}
+		accounts[deployment.Namespace+"/"+account] = true
+
+		path := "operator/deployments/" + deployment.Namespace + "/" + deployment.Name + ".yaml"
+		b, err := export.redact.marshal(path, deployment)
+		if err != nil {
+			return err
+		}
+		if err := export.write(sourceAPI, path, b); err != nil {
+			return err
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err != nil {
+			return err
+		}
+
+		writeInfo(export.cmd, "Collecting PGO logs...")
+		if err := gatherPodLogs(ctx, export, deployment.Namespace,
+			selector.String(), "operator/"+deployment.Namespace); err != nil {
+			return err
+		}
 	}
 
-	var buf bytes.Buffer
+	if err := gatherOperatorCRDs(ctx, export); err != nil {
+		return err
+	}
+	if err := gatherOperatorRBAC(ctx, export, accounts); err != nil {
+		return err
+	}
+	return gatherOperatorWebhooks(ctx, export, namespaces)
+}
 
-	buf.Write([]byte("patronictl list\n"))
-	stdout, stderr, err := Executor(exec).patronictl("list")
+// gatherOperatorCRDs writes a table of the operator's CRDs and their versions
+func gatherOperatorCRDs(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting PGO CRDs...")
+	list, err := export.apiExtensions.CustomResourceDefinitions().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		if apierrors.IsForbidden(err) {
-			writeInfo(cmd, err.Error())
+			export.warn(err)
 			return nil
 		}
 		return err
 	}
 
-	buf.Write([]byte(stdout))
-	if stderr != "" {
-		buf.Write([]byte(stderr))
+	var buf bytes.Buffer
+	w := printers.GetNewTabWriter(&buf)
+	fmt.Fprintf(w, "NAME\tPGO VERSION\tSERVED\tSTORAGE\tSTORED\tCREATED\n")
+	for _, crd := range list.Items {
+		if crd.Spec.Group != operatorGroup {
+			continue
+		}
+
+		var served, storage []string
+		for _, version := range crd.Spec.Versions {
+			if version.Served {
+				served = append(served, version.Name)
+			}
+			if version.Storage {
+				storage = append(storage, version.Name)
+			}
+		}
+
+		// This is the label read by 'pgo version'
+		version := crd.Labels["app.kubernetes.io/version"]
+		if version == "" {
+			version = "<unknown>"
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", crd.Name, version,
+			strings.Join(served, ","), strings.Join(storage, ","),
+			strings.Join(crd.Status.StoredVersions, ","),
+			crd.CreationTimestamp.Format(time.RFC3339))
+	}
+	if err := w.Flush(); err != nil {
+		return err
 	}
 
-	buf.Write([]byte("patronictl history\n"))
-	stdout, stderr, err = Executor(exec).patronictl("history")
-	if err != nil {
-		if apierrors.IsForbidden(err) {
-			writeInfo(cmd, err.Error())
+	return export.write(sourceAPI, "operator/crds", buf.Bytes())
+}
+
+// gatherOperatorRBAC gathers the bindings that grant permissions to the
+// operator's ServiceAccounts, named "namespace/name", and the roles they bind
+func gatherOperatorRBAC(ctx context.Context, export *supportExport, accounts map[string]bool) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	writeInfo(export.cmd, "Collecting PGO RBAC...")
+	rbac := export.clientset.RbacV1()
+
+	bindsOperator := func(subjects []rbacv1.Subject) bool {
+		for _, s := range subjects {
+			if s.Kind == rbacv1.ServiceAccountKind && accounts[s.Namespace+"/"+s.Name] {
+				return true
+			}
+		}
+		return false
+	}
+
+	write := func(path string, obj interface{}) error {
+		b, err := export.redact.marshal(path, obj)
+		if err != nil {
+			return err
+		}
+		return export.write(sourceAPI, path, b)
+	}
+
+	// Write each ClusterRole or Role once, even when many bindings refer to it
+	written := map[string]bool{}
+	writeRole := func(namespace string, ref rbacv1.RoleRef) error {
+		var path string
+		var role interface{}
+		var err error
+
+		switch ref.Kind {
+		case "ClusterRole":
+			path = "operator/rbac/clusterroles/" + ref.Name + ".yaml"
+			if !written[path] {
+				role, err = rbac.ClusterRoles().Get(ctx, ref.Name, metav1.GetOptions{})
+			}
+		case "Role":
+			path = "operator/rbac/roles/" + namespace + "/" + ref.Name + ".yaml"
+			if !written[path] {
+				role, err = rbac.Roles(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+			}
+		default:
 			return nil
 		}
-		return err
+		if written[path] {
+			return nil
+		}
+		written[path] = true
+
+		if err != nil {
+			if apierrors.IsForbidden(err) || apierrors.IsNotFound(err) {
+				export.warn(err)
+				return nil
+			}
+			return err
+		}
+		return write(path, role)
 	}
 
-	buf.Write([]byte(stdout))
-	if stderr != "" {
-		buf.Write([]byte(stderr))
+	clusterBindings, err := rbac.ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if !apierrors.IsForbidden(err) {
+			return err
+		}
+		export.warn(err)
+		clusterBindings = &rbacv1.ClusterRoleBindingList{}
+	}
+	for i := range clusterBindings.Items {
+		binding := &clusterBindings.Items[i]
+		if !bindsOperator(binding.Subjects) {
+			continue
+		}
+		if err := write("operator/rbac/clusterrolebindings/"+binding.Name+".yaml", binding); err != nil {
+			return err
+		}
+		if err := writeRole("", binding.RoleRef); err != nil {
+			return err
+		}
 	}
 
-	path := clusterName + "/patroni-info"
-	if err := writeTar(tw, buf.Bytes(), path, cmd); err != nil {
-		return err
+	// An operator that watches only some namespaces is bound to Roles in them
+	bindings, err := rbac.RoleBindings(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if !apierrors.IsForbidden(err) {
+			return err
+		}
+		export.warn(err)
+		bindings = &rbacv1.RoleBindingList{}
+	}
+	for i := range bindings.Items {
+		binding := &bindings.Items[i]
+		if !bindsOperator(binding.Subjects) {
+			continue
+		}
+		path := "operator/rbac/rolebindings/" + binding.Namespace + "/" + binding.Name + ".yaml"
+		if err := write(path, binding); err != nil {
+			return err
+		}
+		if err := writeRole(binding.Namespace, binding.RoleRef); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// gatherProcessInfo takes a client and buffer execs into relevant pods to grab
-// running process information for each Pod.
-func gatherProcessInfo(ctx context.Context,
-	clientset *kubernetes.Clientset,
-	config *rest.Config,
+// gatherOperatorWebhooks gathers the webhook configurations that call services
+// in the operator's namespaces or that are labeled as part of the operator
+func gatherOperatorWebhooks(ctx context.Context, export *supportExport, namespaces map[string]bool) error {
+	writeInfo(export.cmd, "Collecting PGO webhooks...")
+	admission := export.clientset.AdmissionregistrationV1()
+
+	ours := func(meta metav1.ObjectMeta, configs []admissionregistrationv1.WebhookClientConfig) bool {
+		if labels.Set(meta.Labels).Has(util.LabelControlPlane) {
+			return true
+		}
+		for _, config := range configs {
+			if config.Service != nil && namespaces[config.Service.Namespace] {
+				return true
+			}
+		}
+		return false
+	}
+
+	write := func(path string, obj interface{}) error {
+		b, err := export.redact.marshal(path, obj)
+		if err != nil {
+			return err
+		}
+		return export.write(sourceAPI, path, b)
+	}
+
+	validating, err := admission.ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if !apierrors.IsForbidden(err) {
+			return err
+		}
+		export.warn(err)
+		validating = &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+	}
+	for i := range validating.Items {
+		config := &validating.Items[i]
+		var clients []admissionregistrationv1.WebhookClientConfig
+		for _, webhook := range config.Webhooks {
+			clients = append(clients, webhook.ClientConfig)
+		}
+		if ours(config.ObjectMeta, clients) {
+			if err := write("operator/webhooks/validating/"+config.Name+".yaml", config); err != nil {
+				return err
+			}
+		}
+	}
+
+	mutating, err := admission.MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		if !apierrors.IsForbidden(err) {
+			return err
+		}
+		export.warn(err)
+		mutating = &admissionregistrationv1.MutatingWebhookConfigurationList{}
+	}
+	for i := range mutating.Items {
+		config := &mutating.Items[i]
+		var clients []admissionregistrationv1.WebhookClientConfig
+		for _, webhook := range config.Webhooks {
+			clients = append(clients, webhook.ClientConfig)
+		}
+		if ours(config.ObjectMeta, clients) {
+			if err := write("operator/webhooks/mutating/"+config.Name+".yaml", config); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// gatherPodLogs uses the clientset to gather logs from each container in every
+// pod
+func gatherPodLogs(ctx context.Context,
+	export *supportExport,
 	namespace string,
-	clusterName string,
-	tw *tar.Writer,
-	cmd *cobra.Command,
+	labelSelector string,
+	rootDir string,
 ) error {
-	writeInfo(cmd, "Collecting processes...")
-	// Get the cluster Pods by label
-	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: "postgres-operator.crunchydata.com/cluster=" + clusterName,
+	// Get all Pods that match the given Label
+	pods, err := export.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector,
 	})
 	if err != nil {
 		if apierrors.IsForbidden(err) {
-			writeInfo(cmd, err.Error())
+			export.warn(err)
 			return nil
 		}
 		return err
 	}
 
 	if len(pods.Items) == 0 {
-		// If we didn't find any resources, skip
-		writeInfo(cmd, "PostgresCluster Pods not found when gathering process information, skipping")
+		// If we didn't find any Pods, skip
+		writeInfo(export.cmd, fmt.Sprintf("%s Pods not found, skipping", rootDir))
+	}
+
+	// Collect the logs of every container along with the logs of its previous
+	// instance when it has restarted, as when it is crash looping.
+	var logs []podContainer
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		restarts := map[string]int32{}
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts[status.Name] = status.RestartCount
+		}
+		for _, status := range pod.Status.InitContainerStatuses {
+			restarts[status.Name] = status.RestartCount
+		}
+
+		for _, container := range containerNames(pod) {
+			logs = append(logs, podContainer{pod: pod.GetName(), container: container})
+			if restarts[container] > 0 {
+				logs = append(logs, podContainer{
+					pod: pod.GetName(), container: container, previous: true,
+				})
+			}
+		}
+	}
+
+	return export.parallel(len(logs), func(export *supportExport, i int) error {
+		pod, container, previous := logs[i].pod, logs[i].container, logs[i].previous
+		path := rootDir + "/logs/" + pod + "/" + container
+		description := "logs"
+		if previous {
+			path += ".previous"
+			description = "previous logs"
+		}
+
+		err := export.stream(sourceLogs, path, export.maxLogSize, func(w io.Writer) error {
+			stream, err := export.clientset.CoreV1().Pods(namespace).
+				GetLogs(pod, export.podLogOptions(container, previous)).Stream(ctx)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+
+			if export.window.until.IsZero() {
+				_, err = io.Copy(w, stream)
+				return err
+			}
+
+			// The API has no end time, so stop at the first line after it.
+			uw := &untilWriter{w: w, until: export.window.until}
+			if _, err = io.Copy(uw, stream); err == nil {
+				err = uw.Close()
+			}
+			if errors.Is(err, errLogWindowEnd) {
+				err = nil
+			}
+			return err
+		})
+		if err != nil {
+			// Continue and output errors for each pod log
+			// Allow the user to see and address all issues at once
+			export.warn(fmt.Errorf("failed to get %s of container %q in pod %q: %w",
+				description, container, pod, err))
+		}
 		return nil
+	})
+}
+
+// podLogOptions returns the options for reading the logs of container, or of
+// its previous instance, during the time window. Timestamps are requested when
+// the window has an end so that lines after it can be dropped.
+func (export *supportExport) podLogOptions(container string, previous bool) *corev1.PodLogOptions {
+	options := &corev1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+	}
+	if !export.window.since.IsZero() {
+		options.SinceTime = &metav1.Time{Time: export.window.since}
+	}
+	if !export.window.until.IsZero() {
+		options.Timestamps = true
 	}
+	return options
+}
+
+// podContainer identifies a container in a Pod and, optionally, its previous
+// instance.
+type podContainer struct {
+	pod, container string
+	previous       bool
+}
 
-	podExec, err := util.NewPodExecutor(config)
+// gatherPatroniInfo execs into every instance Pod to gather the Patroni
+// cluster state and history as seen by that instance
+func gatherPatroniInfo(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting Patroni info...")
+	instances, err := listInstancePods(ctx, export)
 	if err != nil {
+		if apierrors.IsForbidden(err) {
+			export.warn(err)
+			return nil
+		}
 		return err
 	}
+	if len(instances) < 1 {
+		writeInfo(export.cmd, "No pod found for patroni info")
+		return nil
+	}
 
-	for _, pod := range pods.Items {
-		for _, container := range pod.Spec.Containers {
-			// Attempt to exec in and run 'ps' command in all available containers,
-			// regardless of state, etc. Many of the resulting process lists will
-			// be nearly identical because certain Pods use a shared process
-			// namespace, but this function aims to gather as much detail as possible.
-			// - https://kubernetes.io/docs/tasks/configure-pod-container/share-process-namespace/
-			exec := func(stdin io.Reader, stdout, stderr io.Writer, command ...string,
-			) error {
-				return podExec(namespace, pod.GetName(), container.Name,
-					stdin, stdout, stderr, command...)
-			}
+	return export.parallel(len(instances), func(export *supportExport, i int) error {
+		instance := instances[i]
+		exec := instance.exec(export)
 
-			stdout, stderr, err := Executor(exec).processes()
+		var buf bytes.Buffer
+
+		for _, subcommand := range []string{"list", "history"} {
+			buf.Write([]byte("patronictl " + subcommand + "\n"))
+			stdout, stderr, err := exec.patronictl(subcommand)
 			if err != nil {
-				// If we get an RBAC error, let the user know. Otherwise, just
-				// try the next container.
-				if apierrors.IsForbidden(err) {
-					writeInfo(cmd, fmt.Sprintf(
-						"Failed to get processes for Container \"%s\" in Pod \"%s\". Error: \"%s\"",
-						container.Name, pod.GetName(), err.Error()))
-				}
+				// Continue so one failing command does not hide the others
+				export.warn(fmt.Errorf("instance %s: patronictl %s: %w",
+					instance.name, subcommand, err))
+				buf.Write([]byte(fmt.Sprintf("Error returned: %s\n", err)))
 				continue
 			}
 
-			var buf bytes.Buffer
 			buf.Write([]byte(stdout))
 			if stderr != "" {
 				buf.Write([]byte(stderr))
 			}
+		}
 
-			path := clusterName + "/" + "processes" + "/" + pod.GetName() + "/" + container.Name
-			if err := writeTar(tw, buf.Bytes(), path, cmd); err != nil {
-				return err
+		path := export.instanceDir(instance) + "/patroni-info"
+		return export.write(sourceExec, path, export.redact.text(path, buf.Bytes()))
+	})
+}
+
+// diagnostic is a command run on instances by gatherDiagnostics.
+type diagnostic struct {
+	name string
+	run  func(Executor) (string, string, error)
+
+	// primary is true for commands that are only run on the primary because
+	// they describe the whole PostgresCluster.
+	primary bool
+}
+
+// diagnostics lists the commands run by gatherDiagnostics in the order they
+// appear in the archive.
+var diagnostics = []diagnostic{
+	{name: "pg-settings", run: Executor.pgSettings},
+	{name: "pg-stat-activity", run: Executor.pgStatActivity},
+	{name: "pg-blocking-locks", run: Executor.pgBlockingLocks},
+	{name: "pg-stat-replication", run: Executor.pgStatReplication},
+	{name: "pg-replication-slots", run: Executor.pgReplicationSlots},
+	{name: "pg-database-sizes", run: Executor.pgDatabaseSizes, primary: true},
+	{name: "pgbackrest-info", primary: true, run: func(exec Executor) (string, string, error) {
+		return exec.pgBackRestInfo("text", "")
+	}},
+	{name: "pgbackrest-check", run: Executor.pgBackRestCheck, primary: true},
+	{name: "patroni-config", primary: true, run: func(exec Executor) (string, string, error) {
+		return exec.patronictl("show-config")
+	}},
+	{name: "disk-usage", run: Executor.diskUsage},
+	{name: "memory", run: Executor.memoryLimits},
+}
+
+// gatherDiagnostics execs into every instance Pod to gather the settings,
+// activity and replication of Postgres and the disk and memory available to
+// it. On the primary, it also gathers the database sizes, the state of the
+// pgBackRest repositories and the Patroni configuration. Each command is
+// stopped after --exec-timeout.
+func gatherDiagnostics(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting diagnostics...")
+	instances, err := listInstancePods(ctx, export)
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			export.warn(err)
+			return nil
+		}
+		return err
+	}
+	if len(instances) < 1 {
+		writeInfo(export.cmd, "No pod found for diagnostics")
+		return nil
+	}
+
+	return export.parallel(len(instances), func(export *supportExport, i int) error {
+		instance := instances[i]
+		exec := instance.exec(export)
+		if export.execTimeout > 0 {
+			exec = exec.withTimeout(export.execTimeout)
+		}
+
+		var commands []diagnostic
+		for _, d := range diagnostics {
+			if instance.primary || !d.primary {
+				commands = append(commands, d)
+			}
+		}
+
+		return export.parallel(len(commands), func(export *supportExport, i int) error {
+			var buf bytes.Buffer
+
+			stdout, stderr, err := commands[i].run(exec)
+			buf.WriteString(stdout)
+			buf.WriteString(stderr)
+			if err != nil {
+				// Continue so one failing command does not hide the others
+				export.warn(fmt.Errorf("instance %s: %s: %w", instance.name, commands[i].name, err))
+				buf.WriteString(fmt.Sprintf("Error returned: %s\n", err))
+			}
+
+			path := export.instanceDir(instance) + "/diagnostics/" + commands[i].name
+			return export.write(sourceExec, path, export.redact.text(path, buf.Bytes()))
+		})
+	})
+}
+
+// gatherProcessInfo execs into every PostgresCluster container to gather its
+// running process information.
+func gatherProcessInfo(ctx context.Context, export *supportExport) error {
+	writeInfo(export.cmd, "Collecting processes...")
+	// Get the cluster Pods by label
+	pods, err := export.clientset.CoreV1().Pods(export.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: util.LabelCluster + "=" + export.clusterName,
+	})
+	if err != nil {
+		if apierrors.IsForbidden(err) {
+			export.warn(err)
+			return nil
+		}
+		return err
+	}
+
+	if len(pods.Items) == 0 {
+		// If we didn't find any resources, skip
+		writeInfo(export.cmd, "PostgresCluster Pods not found when gathering process information, skipping")
+		return nil
+	}
+
+	// Attempt to exec in and run 'ps' command in all available containers,
+	// regardless of state, etc. Many of the resulting process lists will
+	// be nearly identical because certain Pods use a shared process
+	// namespace, but this function aims to gather as much detail as possible.
+	// - https://kubernetes.io/docs/tasks/configure-pod-container/share-process-namespace/
+	var targets []podContainer
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			targets = append(targets, podContainer{pod: pod.GetName(), container: container.Name})
+		}
+	}
+
+	return export.parallel(len(targets), func(export *supportExport, i int) error {
+		pod, container := targets[i].pod, targets[i].container
+		exec := func(stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			return export.podExec(export.namespace, pod, container,
+				stdin, stdout, stderr, command...)
+		}
+
+		stdout, stderr, err := Executor(exec).processes()
+		if err != nil {
+			// If we get an RBAC error, let the user know. Otherwise, just
+			// try the next container.
+			if apierrors.IsForbidden(err) {
+				export.warn(fmt.Errorf(
+					"Failed to get processes for Container \"%s\" in Pod \"%s\". Error: \"%s\"",
+					container, pod, err.Error()))
 			}
+			return nil
 		}
+
+		var buf bytes.Buffer
+		buf.Write([]byte(stdout))
+		if stderr != "" {
+			buf.Write([]byte(stderr))
+		}
+
+		path := export.clusterName + "/" + "processes" + "/" + pod + "/" + container
+		return export.write(sourceExec, path, export.redact.text(path, buf.Bytes()))
+	})
+}
+
+// gatherRedactions writes the list of values removed from the archive
+func gatherRedactions(export *supportExport) error {
+	writeInfo(export.cmd, fmt.Sprintf("Redacted %d values...", len(export.redact.findings)))
+	b, err := export.redact.manifest()
+	if err != nil {
+		return err
 	}
 
+	path := export.sharedDir + "/redactions.json"
+	if err := export.writeNow(path, b); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -1082,29 +3034,15 @@ func translateTimestampSince(timestamp metav1.Time) string {
 	return duration.HumanDuration(time.Since(timestamp.Time))
 }
 
-// writeTar takes content as a byte slice and writes the content to a tar writer
-func writeTar(tw *tar.Writer, content []byte, name string, cmd *cobra.Command) error {
-	hdr := &tar.Header{
-		Name:    name,
-		Mode:    0600,
-		ModTime: time.Now(),
-		Size:    int64(len(content)),
-	}
-
-	writeDebug(cmd, fmt.Sprintf("File: %s Size: %d\n", name, hdr.Size))
-	if err := tw.WriteHeader(hdr); err != nil {
-		return err
-	}
-	if _, err := tw.Write(content); err != nil {
-		return err
-	}
-
-	// After we write content to the file, call Flush to ensure the files block is fully padded.
-	// This shouldn't be necessary based on the tar docs: https://pkg.go.dev/archive/tar#Writer.Flush
-	if err := tw.Flush(); err != nil {
-		return err
-	}
-	return nil
+// writeArchiveReader copies size bytes from r to a file of the archive. The
+// name is sanitized first and the records describe the file, such as whether
+// it was truncated.
+func writeArchiveReader(archive archiveWriter, r io.Reader, size int64, name string,
+	records map[string]string, cmd *cobra.Command,
+) error {
+	name = sanitizeEntryName(name)
+	writeDebug(cmd, fmt.Sprintf("File: %s Size: %d\n", name, size))
+	return archive.writeFile(name, r, size, records)
 }
 
 // writeInfo logs to both the PGO CLI log file and stdout
@@ -1113,8 +3051,41 @@ func writeInfo(cmd *cobra.Command, s string) {
 	t := time.Now()
 	// write to CLI log buffer
 	cmd.Printf("%s - INFO - %s\n", t.Format(logTimeFormat), s)
-	// write to stdout
-	fmt.Println(s)
+	// write to stdout, or stderr when the archive is written to stdout
+	var info io.Writer = os.Stdout
+	if log, ok := cmd.OutOrStdout().(*exportLog); ok {
+		info = log.info
+	}
+	fmt.Fprintln(info, s)
+}
+
+// sizeFlag parses the value of a size flag, such as "100Mi", into bytes.
+func sizeFlag(name, value string) (int64, error) {
+	q, err := resource.ParseQuantity(value)
+	if err != nil || q.Sign() < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a size such as 100Mi", name, value)
+	}
+	return q.Value(), nil
+}
+
+// lockedWriter serializes writes to w, which may be shared by many writers.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+// exportLog is the output of the support export command. What is written to
+// it goes to the CLI log file. Messages from writeInfo are also printed to
+// info, which is stderr while the archive is written to stdout.
+type exportLog struct {
+	lockedWriter
+	info io.Writer
 }
 
 // writeDebug logs to only the PGO CLI log file
@@ -15,10 +15,35 @@
 package cmd
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/spf13/cobra"
 	"gotest.tools/v3/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsfake "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/fake"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/crunchydata/postgres-operator-client/internal/util"
 )
 
 func TestFileSizeReport(t *testing.T) {
@@ -41,3 +66,1201 @@ func TestFileSizeReport(t *testing.T) {
 		})
 	}
 }
+
+// newTestExport returns a supportExport for the "hippo" PostgresCluster that
+// uses fake clients holding objects. The returned function closes the archive
+// and returns the content of each file in it.
+func newTestExport(t *testing.T, objects ...runtime.Object) (
+	*supportExport, func() map[string]string,
+) {
+	t.Helper()
+
+	var archive bytes.Buffer
+	tw := &tarArchive{tw: tar.NewWriter(&archive)}
+
+	cmd := &cobra.Command{}
+	cmd.SetOut(io.Discard)
+
+	redact, err := newRedactor("standard")
+	assert.NilError(t, err)
+
+	hippo := &unstructured.Unstructured{}
+	hippo.SetName("hippo")
+	hippo.SetNamespace("postgres-operator")
+
+	var entries []archiveEntry
+	export := &supportExport{
+		clientset:     fake.NewSimpleClientset(objects...),
+		apiExtensions: apiextensionsfake.NewSimpleClientset().ApiextensionsV1(),
+		podExec: func(namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			return errors.New("no exec in tests")
+		},
+		clusters:            []*unstructured.Unstructured{hippo},
+		cluster:             hippo,
+		clusterName:         "hippo",
+		sharedDir:           "hippo",
+		namespace:           "postgres-operator",
+		monitoringNamespace: "postgres-operator",
+		numLogs:             2,
+		redact:              redact,
+		archive:             tw,
+		cmd:                 cmd,
+		entries:             &entries,
+		budget:              &archiveBudget{},
+		manifest:            &archiveManifest{},
+	}
+
+	return export, func() map[string]string {
+		for _, entry := range entries {
+			assert.NilError(t, export.budget.write(tw, export.manifest, entry, cmd))
+			entry.close()
+		}
+		assert.NilError(t, tw.Close())
+
+		files := map[string]string{}
+		tr := tar.NewReader(&archive)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.NilError(t, err)
+
+			b, err := io.ReadAll(tr)
+			assert.NilError(t, err)
+			files[hdr.Name] = string(b)
+		}
+		return files
+	}
+}
+
+func TestEnabledSections(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		enabled, err := enabledSections(nil, nil)
+		assert.NilError(t, err)
+		assert.Equal(t, len(enabled), len(supportCollectors))
+		for _, c := range supportCollectors {
+			assert.Assert(t, enabled[c.name], c.name)
+		}
+	})
+
+	t.Run("Include", func(t *testing.T) {
+		enabled, err := enabledSections([]string{"nodes", "events"}, nil)
+		assert.NilError(t, err)
+		assert.Assert(t, enabled["nodes"])
+		assert.Assert(t, enabled["events"])
+		assert.Assert(t, !enabled["pglogs"])
+	})
+
+	t.Run("Exclude", func(t *testing.T) {
+		enabled, err := enabledSections(nil, []string{"processes"})
+		assert.NilError(t, err)
+		assert.Assert(t, enabled["nodes"])
+		assert.Assert(t, !enabled["processes"])
+	})
+
+	t.Run("IncludeAndExclude", func(t *testing.T) {
+		enabled, err := enabledSections([]string{"nodes", "events"}, []string{"events"})
+		assert.NilError(t, err)
+		assert.Assert(t, enabled["nodes"])
+		assert.Assert(t, !enabled["events"])
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := enabledSections([]string{"nodes", "bogus"}, nil)
+		assert.ErrorContains(t, err, `unknown section "bogus"`)
+		assert.ErrorContains(t, err, "nodes, ")
+	})
+}
+
+func TestRunCollectors(t *testing.T) {
+	export, _ := newTestExport(t)
+
+	var mu sync.Mutex
+	var ran []string
+	collector := func(name string, err error, warnings ...string) supportCollector {
+		return supportCollector{name: name, collect: func(_ context.Context, export *supportExport) error {
+			mu.Lock()
+			ran = append(ran, name)
+			mu.Unlock()
+			for _, w := range warnings {
+				export.warn(errors.New(w))
+			}
+			return err
+		}}
+	}
+
+	results := runCollectors(context.Background(), export, []supportCollector{
+		collector("one", nil),
+		collector("two", nil, "forbidden"),
+		collector("three", nil),
+		collector("four", errors.New("boom")),
+		collector("five", nil),
+	}, map[string]bool{"one": true, "two": true, "four": true, "five": true})
+
+	sort.Strings(ran)
+	assert.DeepEqual(t, ran, []string{"five", "four", "one", "two"})
+
+	for i := range results {
+		results[i].Duration = ""
+	}
+	assert.DeepEqual(t, results, []sectionResult{
+		{Name: "one", Cluster: "hippo", Status: sectionComplete},
+		{Name: "two", Cluster: "hippo", Status: sectionPartial, Errors: []string{"forbidden"}},
+		{Name: "three", Cluster: "hippo", Status: sectionExcluded},
+		{Name: "four", Cluster: "hippo", Status: sectionFailed, Errors: []string{"boom"}},
+		{Name: "five", Cluster: "hippo", Status: sectionComplete},
+	})
+	assert.Assert(t, export.section == nil, "each collector gets its own copy")
+
+	t.Run("SharedAndClusters", func(t *testing.T) {
+		export, _ := newTestExport(t)
+		rhino := &unstructured.Unstructured{}
+		rhino.SetName("rhino")
+		export.clusters = append(export.clusters, rhino)
+		export.cluster, export.clusterName, export.sharedDir = nil, "", sharedExportDir
+
+		var mu sync.Mutex
+		ran := map[string]int{}
+		record := func(ctx context.Context, export *supportExport) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran[export.clusterName]++
+			if export.clusterName != "" {
+				assert.Equal(t, export.cluster.GetName(), export.clusterName)
+			}
+			return nil
+		}
+
+		results := runCollectors(context.Background(), export, []supportCollector{
+			{name: "nodes", shared: record},
+			{name: "resources", shared: record, collect: record},
+			{name: "pods", collect: record},
+		}, map[string]bool{"nodes": true, "resources": true, "pods": true})
+
+		var labels []string
+		for _, result := range results {
+			labels = append(labels, result.label())
+		}
+		assert.DeepEqual(t, labels, []string{
+			"nodes", "resources", "resources (hippo)", "resources (rhino)",
+			"pods (hippo)", "pods (rhino)",
+		})
+		assert.DeepEqual(t, ran, map[string]int{"": 2, "hippo": 2, "rhino": 2})
+	})
+}
+
+func TestExportTargetArgs(t *testing.T) {
+	assert.NilError(t, exportTargetArgs([]string{"hippo", "rhino"}, false, ""))
+	assert.NilError(t, exportTargetArgs(nil, true, ""))
+	assert.NilError(t, exportTargetArgs(nil, false, "team=payments"))
+
+	assert.ErrorContains(t, exportTargetArgs(nil, false, ""),
+		"requires a PostgresCluster name, --all or --selector")
+	assert.ErrorContains(t, exportTargetArgs(nil, true, "team=payments"),
+		"only one of --all or --selector may be used")
+	assert.ErrorContains(t, exportTargetArgs([]string{"hippo"}, true, ""),
+		"PostgresCluster names cannot be used with --all or --selector")
+
+	// The short form of --pg-logs-count used to be "-l".
+	assert.ErrorContains(t, exportTargetArgs([]string{"hippo"}, false, "5"),
+		`invalid selector "5": -l is short for --selector; use --pg-logs-count`)
+}
+
+func TestRunCollectorsArchiveOrder(t *testing.T) {
+	for _, parallelism := range []int{1, 3} {
+		t.Run(fmt.Sprint(parallelism), func(t *testing.T) {
+			var archive bytes.Buffer
+			export, _ := newTestExport(t)
+			export.archive = &tarArchive{tw: tar.NewWriter(&archive)}
+			export.workers = make(chan struct{}, parallelism)
+
+			// Later collectors and items finish first.
+			collector := func(name string, delay time.Duration) supportCollector {
+				return supportCollector{name: name, collect: func(_ context.Context, export *supportExport) error {
+					time.Sleep(delay)
+					if err := export.write(sourceAPI, name+"/first", nil); err != nil {
+						return err
+					}
+					err := export.parallel(3, func(export *supportExport, i int) error {
+						time.Sleep(time.Duration(3-i) * time.Millisecond)
+						return export.write(sourceAPI, fmt.Sprintf("%s/item-%d", name, i), nil)
+					})
+					if err != nil {
+						return err
+					}
+					return export.write(sourceAPI, name+"/last", nil)
+				}}
+			}
+
+			results := runCollectors(context.Background(), export, []supportCollector{
+				collector("one", 20*time.Millisecond),
+				collector("two", 10*time.Millisecond),
+				collector("three", 0),
+			}, map[string]bool{"one": true, "two": true, "three": true})
+			assert.Equal(t, exportStatus(results), sectionComplete)
+			assert.NilError(t, export.archive.Close())
+
+			var names []string
+			tr := tar.NewReader(&archive)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				assert.NilError(t, err)
+				names = append(names, hdr.Name)
+			}
+
+			var expected []string
+			for _, name := range []string{"one", "two", "three"} {
+				expected = append(expected, name+"/first",
+					name+"/item-0", name+"/item-1", name+"/item-2", name+"/last")
+			}
+			assert.DeepEqual(t, names, expected)
+		})
+	}
+}
+
+func TestExportParallel(t *testing.T) {
+	export, _ := newTestExport(t)
+	export.workers = make(chan struct{}, 2)
+
+	// The caller holds a worker, as it would when run by runCollectors.
+	export.workers <- struct{}{}
+
+	var mu sync.Mutex
+	var running, most int
+	err := export.parallel(10, func(export *supportExport, i int) error {
+		mu.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		if i == 7 || i == 4 {
+			return fmt.Errorf("item %d", i)
+		}
+		return nil
+	})
+
+	assert.ErrorContains(t, err, "item 4")
+	assert.Assert(t, most <= 2, "ran %d at once", most)
+	assert.Equal(t, len(export.workers), 1, "caller gets its worker back")
+}
+
+func TestArchiveBudget(t *testing.T) {
+	export, _ := newTestExport(t)
+
+	var archive bytes.Buffer
+	tw := &tarArchive{tw: tar.NewWriter(&archive)}
+	budget := &archiveBudget{limit: 12}
+
+	logs, err := newSpool(10)
+	assert.NilError(t, err)
+	_, err = io.WriteString(logs, "one\ntwo\nthree\n")
+	assert.NilError(t, err)
+
+	for _, entry := range []archiveEntry{
+		{name: "small", content: []byte("12345")},
+		{name: "log", spool: logs},
+		{name: "large", content: []byte("abcdefghij")},
+		{name: "empty", content: []byte("more")},
+	} {
+		assert.NilError(t, budget.write(tw, nil, entry, export.cmd))
+		entry.close()
+	}
+	assert.NilError(t, tw.Close())
+
+	type file struct {
+		Content string
+		Records map[string]string
+	}
+	var files []file
+	tr := tar.NewReader(&archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+
+		b, err := io.ReadAll(tr)
+		assert.NilError(t, err)
+		files = append(files, file{string(b), hdr.PAXRecords})
+	}
+
+	assert.DeepEqual(t, files, []file{
+		{Content: "12345"},
+		{Content: "three\n", Records: map[string]string{
+			"PGO.truncated": "max-log-size", "PGO.originalsize": "14",
+		}},
+		{Content: "a", Records: map[string]string{
+			"PGO.truncated": "max-archive-size", "PGO.originalsize": "10",
+		}},
+		{Content: "", Records: map[string]string{
+			"PGO.truncated": "max-archive-size", "PGO.originalsize": "4",
+		}},
+	})
+
+	assert.DeepEqual(t, budget.truncated, []truncation{
+		{Path: "log", Size: 6, OriginalSize: 14, Reason: "max-log-size"},
+		{Path: "large", Size: 1, OriginalSize: 10, Reason: "max-archive-size"},
+		{Path: "empty", Size: 0, OriginalSize: 4, Reason: "max-archive-size"},
+	})
+}
+
+func TestExportStream(t *testing.T) {
+	export, files := newTestExport(t)
+
+	assert.NilError(t, export.stream(sourceLogs, "hippo/log", 0, func(w io.Writer) error {
+		_, err := io.WriteString(w, "password=hunter2\n")
+		return err
+	}))
+	assert.ErrorContains(t, export.stream(sourceLogs, "hippo/failed", 0, func(w io.Writer) error {
+		_, _ = io.WriteString(w, "partial")
+		return errors.New("boom")
+	}), "boom")
+
+	archive := files()
+	assert.Equal(t, archive["hippo/log"], "password=<REDACTED>\n")
+	_, found := archive["hippo/failed"]
+	assert.Assert(t, !found, "nothing is added on error")
+}
+
+func TestWriteInfo(t *testing.T) {
+	var log, info bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&exportLog{lockedWriter: lockedWriter{mu: &sync.Mutex{}, w: &log}, info: &info})
+
+	writeInfo(cmd, "Collecting events...")
+	assert.Equal(t, info.String(), "Collecting events...\n")
+	assert.Assert(t, strings.HasSuffix(log.String(), " - INFO - Collecting events...\n"))
+}
+
+func TestSizeFlag(t *testing.T) {
+	size, err := sizeFlag("max-log-size", "1Mi")
+	assert.NilError(t, err)
+	assert.Equal(t, size, int64(1<<20))
+
+	size, err = sizeFlag("max-log-size", "0")
+	assert.NilError(t, err)
+	assert.Equal(t, size, int64(0))
+
+	_, err = sizeFlag("max-log-size", "-1")
+	assert.ErrorContains(t, err, `invalid max-log-size "-1"`)
+
+	_, err = sizeFlag("max-archive-size", "lots")
+	assert.ErrorContains(t, err, `invalid max-archive-size "lots"`)
+}
+
+func TestExportResult(t *testing.T) {
+	complete := sectionResult{Name: "a", Status: sectionComplete}
+	partial := sectionResult{Name: "b", Status: sectionPartial, Errors: []string{"x"}}
+	failed := sectionResult{Name: "c", Status: sectionFailed, Errors: []string{"y", "z"}}
+	excluded := sectionResult{Name: "d", Status: sectionExcluded}
+
+	t.Run("Complete", func(t *testing.T) {
+		results := []sectionResult{complete, excluded}
+		assert.Equal(t, exportStatus(results), sectionComplete)
+		assert.NilError(t, exportResultError(results))
+		assert.Equal(t, exportFailureReport(results), "")
+	})
+
+	t.Run("Partial", func(t *testing.T) {
+		results := []sectionResult{complete, partial, failed, excluded}
+		assert.Equal(t, exportStatus(results), sectionPartial)
+
+		err := exportResultError(results)
+		assert.ErrorContains(t, err, "support export is partial")
+		assert.ErrorContains(t, err, "not fully collected: b, c")
+
+		var exit *ExitError
+		assert.Assert(t, errors.As(err, &exit))
+		assert.Equal(t, exit.Code, exitPartial)
+
+		report := exportFailureReport(results)
+		assert.Assert(t, strings.Contains(report, "| b: partial (1 errors)\n"))
+		assert.Assert(t, strings.Contains(report, "| c: failed (2 errors)\n"))
+		assert.Assert(t, !strings.Contains(report, "| a:"))
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		results := []sectionResult{failed, excluded}
+		assert.Equal(t, exportStatus(results), sectionFailed)
+
+		err := exportResultError(results)
+		assert.ErrorContains(t, err, "support export is failed")
+
+		var exit *ExitError
+		assert.Assert(t, !errors.As(err, &exit), "exits with 1")
+	})
+}
+
+func TestGatherSummary(t *testing.T) {
+	export, files := newTestExport(t)
+
+	assert.NilError(t, gatherSummary(export, []sectionResult{
+		{Name: "nodes", Status: sectionComplete, Duration: "1ms"},
+		{Name: "events", Status: sectionFailed, Errors: []string{"boom"}},
+	}))
+
+	archive := files()
+
+	var summary struct {
+		Status   string
+		Sections []sectionResult
+	}
+	assert.NilError(t, json.Unmarshal([]byte(archive["hippo/summary.json"]), &summary))
+	assert.Equal(t, summary.Status, sectionPartial)
+	assert.Equal(t, len(summary.Sections), 2)
+	assert.Equal(t, summary.Sections[1].Errors[0], "boom")
+
+	var errs []struct{ Section, Error string }
+	assert.NilError(t, json.Unmarshal([]byte(archive["hippo/errors.json"]), &errs))
+	assert.DeepEqual(t, errs, []struct{ Section, Error string }{
+		{Section: "events", Error: "boom"},
+	})
+}
+
+func TestGatherNodes(t *testing.T) {
+	export, files := newTestExport(t, &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: "Ready", Status: "True"}},
+			NodeInfo:   corev1.NodeSystemInfo{KubeletVersion: "v1.24.3"},
+		},
+	})
+
+	assert.NilError(t, gatherNodes(context.Background(), export))
+
+	archive := files()
+	assert.Assert(t, strings.Contains(archive["hippo/nodes/node-1.yaml"], "name: node-1"))
+	assert.Assert(t, strings.Contains(archive["hippo/nodes/list"], "KERNEL-VERSION"))
+	assert.Assert(t, strings.Contains(archive["hippo/nodes/list"], "v1.24.3"))
+}
+
+func TestGatherEvents(t *testing.T) {
+	export, files := newTestExport(t, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "one", Namespace: "postgres-operator"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "hippo-0"},
+		Reason:         "Created",
+		Type:           "Normal",
+		Message:        "Created container database",
+	}, &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "two", Namespace: "elsewhere"},
+		Message:    "Not in the namespace",
+	})
+
+	assert.NilError(t, gatherEvents(context.Background(), export))
+
+	events := files()["hippo/events"]
+	assert.Assert(t, strings.Contains(events, "Pod/hippo-0"))
+	assert.Assert(t, strings.Contains(events, "Created container database"))
+	assert.Assert(t, !strings.Contains(events, "Not in the namespace"))
+}
+
+func TestGatherEventsWindow(t *testing.T) {
+	at := func(hour int) metav1.Time {
+		return metav1.NewTime(time.Date(2023, 1, 1, hour, 0, 0, 0, time.UTC))
+	}
+	export, files := newTestExport(t, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "before", Namespace: "postgres-operator"},
+		FirstTimestamp: at(7), LastTimestamp: at(8),
+		Message: "Before the window",
+	}, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "during", Namespace: "postgres-operator"},
+		FirstTimestamp: at(8), LastTimestamp: at(10),
+		Message: "Last seen in the window",
+	}, &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "series", Namespace: "postgres-operator"},
+		EventTime:  metav1.NewMicroTime(at(6).Time),
+		Series:     &corev1.EventSeries{Count: 3, LastObservedTime: metav1.NewMicroTime(at(11).Time)},
+		Message:    "Repeated into the window",
+	}, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "after", Namespace: "postgres-operator"},
+		FirstTimestamp: at(13), LastTimestamp: at(14),
+		Message: "After the window",
+	}, &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "unknown", Namespace: "postgres-operator"},
+		Message:    "Without any time",
+	})
+	export.window = timeWindow{since: at(9).Time, until: at(12).Time}
+
+	assert.NilError(t, gatherEvents(context.Background(), export))
+
+	events := files()["hippo/events"]
+	assert.Assert(t, !strings.Contains(events, "Before the window"))
+	assert.Assert(t, strings.Contains(events, "Last seen in the window"))
+	assert.Assert(t, strings.Contains(events, "Repeated into the window"))
+	assert.Assert(t, !strings.Contains(events, "After the window"))
+	assert.Assert(t, strings.Contains(events, "Without any time"))
+}
+
+func TestPodLogOptions(t *testing.T) {
+	export, _ := newTestExport(t)
+
+	options := export.podLogOptions("database", false)
+	assert.DeepEqual(t, options, &corev1.PodLogOptions{Container: "database"})
+
+	options = export.podLogOptions("database", true)
+	assert.DeepEqual(t, options, &corev1.PodLogOptions{Container: "database", Previous: true})
+
+	since := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	export.window = timeWindow{since: since, until: since.Add(time.Hour)}
+
+	options = export.podLogOptions("database", false)
+	assert.Equal(t, options.Container, "database")
+	assert.Equal(t, options.SinceTime.Time, since)
+	assert.Assert(t, options.Timestamps, "needed to stop at the end of the window")
+}
+
+// instancePodObject returns the Pod of one instance of the hippo cluster.
+func instancePodObject(instance string, primary bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: instance + "-0", Namespace: "postgres-operator",
+			Labels: map[string]string{
+				util.LabelCluster:     "hippo",
+				util.LabelData:        util.DataPostgres,
+				util.LabelInstance:    instance,
+				util.LabelInstanceSet: "instance1",
+				util.LabelRole:        "replica",
+			},
+		},
+	}
+	if primary {
+		pod.Labels[util.LabelRole] = util.RolePatroniLeader
+	}
+	return pod
+}
+
+func TestGatherPostgresqlLogs(t *testing.T) {
+	export, files := newTestExport(t,
+		instancePodObject("hippo-instance1-abcd", true),
+		instancePodObject("hippo-instance1-efgh", false))
+	export.window = timeWindow{since: time.Unix(1672570000, 0)}
+
+	export.podExec = func(namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		script := command[len(command)-1]
+		switch {
+		case strings.HasPrefix(script, "stat "):
+			_, _ = io.WriteString(stdout, ""+
+				"1672560000 pgdata/pg14/log/postgresql-Sat.log\n"+
+				"1672567200 pgdata/pg14/log/postgresql-Sun.log\n"+
+				"1672574400 pgdata/pg14/log/postgresql-Mon.log\n")
+		case strings.HasPrefix(script, "cat "):
+			_, _ = io.WriteString(stdout,
+				"contents of "+pod+" "+strings.TrimPrefix(script, "cat ")+"\n")
+		}
+		return nil
+	}
+
+	assert.NilError(t, gatherPostgresqlLogs(context.Background(), export))
+
+	archive := files()
+	assert.Equal(t, len(archive), 2)
+	assert.Equal(t, archive["hippo/instances/hippo-instance1-abcd/pgdata/pg14/log/postgresql-Mon.log"],
+		"contents of hippo-instance1-abcd-0 pgdata/pg14/log/postgresql-Mon.log\n")
+	assert.Equal(t, archive["hippo/instances/hippo-instance1-efgh/pgdata/pg14/log/postgresql-Mon.log"],
+		"contents of hippo-instance1-efgh-0 pgdata/pg14/log/postgresql-Mon.log\n")
+
+	t.Run("InstanceFails", func(t *testing.T) {
+		export, files := newTestExport(t,
+			instancePodObject("hippo-instance1-abcd", true),
+			instancePodObject("hippo-instance1-efgh", false))
+		export.section = &exportSection{}
+
+		export.podExec = func(namespace, pod, container string,
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			if pod == "hippo-instance1-abcd-0" {
+				return errors.New("exec failed")
+			}
+			script := command[len(command)-1]
+			if strings.HasPrefix(script, "stat ") {
+				_, _ = io.WriteString(stdout, "1672574400 pgdata/pg14/log/postgresql-Mon.log\n")
+			}
+			return nil
+		}
+
+		assert.NilError(t, gatherPostgresqlLogs(context.Background(), export))
+		assert.DeepEqual(t, export.section.result.Errors, []string{
+			"instance hippo-instance1-abcd: exec failed",
+		})
+		assert.Equal(t, len(files()), 1)
+	})
+}
+
+func TestGatherInstances(t *testing.T) {
+	replica := instancePodObject("hippo-instance2-efgh", false)
+	replica.Labels[util.LabelInstanceSet] = "instance2"
+	replica.Spec.NodeName = "node-b"
+	export, files := newTestExport(t,
+		instancePodObject("hippo-instance1-abcd", true), replica)
+
+	assert.NilError(t, gatherInstances(context.Background(), export))
+	assert.Equal(t, files()["hippo/instances/roles"], ""+
+		"INSTANCE               INSTANCE SET   POD                      ROLE      NODE     PHASE\n"+
+		"hippo-instance1-abcd   instance1      hippo-instance1-abcd-0   primary            \n"+
+		"hippo-instance2-efgh   instance2      hippo-instance2-efgh-0   replica   node-b   \n")
+}
+
+func TestGatherOperator(t *testing.T) {
+	controlPlane := map[string]string{util.LabelControlPlane: "postgres-operator"}
+	export, files := newTestExport(t, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pgo", Namespace: "postgres-operator", Labels: controlPlane,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: controlPlane},
+			Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				ServiceAccountName: "pgo",
+			}},
+		},
+	}, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "postgres-operator"},
+	}, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pgo-abc", Namespace: "postgres-operator", Labels: controlPlane,
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "operator"}}},
+	}, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "postgres-operator"},
+		Subjects: []rbacv1.Subject{{
+			Kind: "ServiceAccount", Name: "pgo", Namespace: "postgres-operator",
+		}},
+		RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "postgres-operator"},
+	}, &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "someone-else"},
+		Subjects: []rbacv1.Subject{{
+			Kind: "ServiceAccount", Name: "pgo", Namespace: "elsewhere",
+		}},
+		RoleRef: rbacv1.RoleRef{Kind: "ClusterRole", Name: "admin"},
+	}, &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "postgres-operator"},
+	}, &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "pgo", Namespace: "watched"},
+		Subjects: []rbacv1.Subject{{
+			Kind: "ServiceAccount", Name: "pgo", Namespace: "postgres-operator",
+		}},
+		RoleRef: rbacv1.RoleRef{Kind: "Role", Name: "pgo"},
+	}, &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "pgo", Namespace: "watched"},
+	}, &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "pgo-webhook"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{
+					Namespace: "postgres-operator", Name: "pgo",
+				},
+			},
+		}},
+	}, &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "another-webhook"},
+	})
+	export.apiExtensions = apiextensionsfake.NewSimpleClientset(
+		&apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "postgresclusters.postgres-operator.crunchydata.com",
+				Labels: map[string]string{"app.kubernetes.io/version": "5.3.0"},
+			},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				Group: "postgres-operator.crunchydata.com",
+				Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+					{Name: "v1beta1", Served: true, Storage: true},
+				},
+			},
+			Status: apiextensionsv1.CustomResourceDefinitionStatus{
+				StoredVersions: []string{"v1beta1"},
+			},
+		},
+		&apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+			Spec:       apiextensionsv1.CustomResourceDefinitionSpec{Group: "example.com"},
+		},
+	).ApiextensionsV1()
+
+	assert.NilError(t, gatherOperator(context.Background(), export))
+
+	archive := files()
+	names := make([]string, 0, len(archive))
+	for name := range archive {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assert.DeepEqual(t, names, []string{
+		"operator/crds",
+		"operator/deployments/postgres-operator/pgo.yaml",
+		"operator/postgres-operator/logs/pgo-abc/operator",
+		"operator/rbac/clusterrolebindings/postgres-operator.yaml",
+		"operator/rbac/clusterroles/postgres-operator.yaml",
+		"operator/rbac/rolebindings/watched/pgo.yaml",
+		"operator/rbac/roles/watched/pgo.yaml",
+		"operator/webhooks/validating/pgo-webhook.yaml",
+	})
+
+	crds := archive["operator/crds"]
+	assert.Assert(t, strings.Contains(crds, "postgresclusters.postgres-operator.crunchydata.com   5.3.0"), "\n%s", crds)
+	assert.Assert(t, !strings.Contains(crds, "widgets"))
+
+	t.Run("Namespace", func(t *testing.T) {
+		export, files := newTestExport(t, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "pgo", Namespace: "postgres-operator", Labels: controlPlane,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: controlPlane},
+			},
+		})
+		export.operatorNamespace = "elsewhere"
+
+		assert.NilError(t, gatherOperator(context.Background(), export))
+
+		_, found := files()["operator/deployments/postgres-operator/pgo.yaml"]
+		assert.Assert(t, !found, "only the operator namespace is searched")
+	})
+}
+
+func TestGatherPatroniInfo(t *testing.T) {
+	export, files := newTestExport(t,
+		instancePodObject("hippo-instance1-abcd", true),
+		instancePodObject("hippo-instance1-efgh", false))
+
+	var mu sync.Mutex
+	var calls []string
+	export.podExec = func(namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		mu.Lock()
+		calls = append(calls, pod+"/"+container)
+		mu.Unlock()
+		_, _ = stdout.Write([]byte("output of " + command[len(command)-1] + "\n"))
+		return nil
+	}
+
+	assert.NilError(t, gatherPatroniInfo(context.Background(), export))
+	sort.Strings(calls)
+	assert.DeepEqual(t, calls, []string{
+		"hippo-instance1-abcd-0/database", "hippo-instance1-abcd-0/database",
+		"hippo-instance1-efgh-0/database", "hippo-instance1-efgh-0/database",
+	})
+
+	archive := files()
+	for _, instance := range []string{"hippo-instance1-abcd", "hippo-instance1-efgh"} {
+		assert.Equal(t, archive["hippo/instances/"+instance+"/patroni-info"], ""+
+			"patronictl list\noutput of patronictl list\n"+
+			"patronictl history\noutput of patronictl history\n")
+	}
+}
+
+func TestGatherPatroniInfoContinues(t *testing.T) {
+	export, files := newTestExport(t, instancePodObject("hippo-instance1-abcd", true))
+	export.section = &exportSection{}
+
+	export.podExec = func(namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		if strings.HasSuffix(command[len(command)-1], "list") {
+			return errors.New("exec failed")
+		}
+		_, _ = stdout.Write([]byte("history output\n"))
+		return nil
+	}
+
+	assert.NilError(t, gatherPatroniInfo(context.Background(), export))
+	assert.DeepEqual(t, export.section.result.Errors, []string{
+		"instance hippo-instance1-abcd: patronictl list: exec failed",
+	})
+	assert.Equal(t, files()["hippo/instances/hippo-instance1-abcd/patroni-info"], ""+
+		"patronictl list\nError returned: exec failed\n"+
+		"patronictl history\nhistory output\n")
+}
+
+func TestGatherDiagnostics(t *testing.T) {
+	export, files := newTestExport(t,
+		instancePodObject("hippo-instance1-abcd", true),
+		instancePodObject("hippo-instance1-efgh", false))
+	export.section = &exportSection{}
+	export.execTimeout = time.Minute
+
+	var mu sync.Mutex
+	var timeouts int
+	export.podExec = func(namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.Equal(t, container, util.ContainerDatabase)
+		if command[0] == "timeout" && command[2] == "60" {
+			mu.Lock()
+			timeouts++
+			mu.Unlock()
+		}
+		if strings.Contains(command[len(command)-1], "pgbackrest check") {
+			_, _ = stderr.Write([]byte("ERROR: [082]: WAL segment was not archived\n"))
+			return errors.New("command terminated with exit code 82")
+		}
+		if command[3] == "psql" {
+			_, _ = stdout.Write([]byte("password=secret\n"))
+			return nil
+		}
+		_, _ = stdout.Write([]byte("output of " + command[len(command)-1] + "\n"))
+		return nil
+	}
+
+	assert.NilError(t, gatherDiagnostics(context.Background(), export))
+	assert.DeepEqual(t, export.section.result.Errors, []string{
+		"instance hippo-instance1-abcd: pgbackrest-check: command terminated with exit code 82",
+	})
+
+	archive := files()
+	var primaryOnly int
+	for _, d := range diagnostics {
+		_, ok := archive["hippo/instances/hippo-instance1-abcd/diagnostics/"+d.name]
+		assert.Assert(t, ok, "missing %s on the primary", d.name)
+
+		_, ok = archive["hippo/instances/hippo-instance1-efgh/diagnostics/"+d.name]
+		assert.Equal(t, ok, !d.primary, "%s on the replica", d.name)
+		if d.primary {
+			primaryOnly++
+		}
+	}
+	assert.Equal(t, timeouts, 2*len(diagnostics)-primaryOnly)
+
+	assert.Equal(t, archive["hippo/instances/hippo-instance1-abcd/diagnostics/pg-settings"],
+		"password=<REDACTED>\n")
+	assert.Equal(t, archive["hippo/instances/hippo-instance1-abcd/diagnostics/patroni-config"],
+		"output of patronictl show-config\n")
+	assert.Equal(t, archive["hippo/instances/hippo-instance1-abcd/diagnostics/pgbackrest-check"], ""+
+		"ERROR: [082]: WAL segment was not archived\n"+
+		"Error returned: command terminated with exit code 82\n")
+}
+
+func TestGatherPodLogs(t *testing.T) {
+	export, files := newTestExport(t, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "hippo-instance-0", Namespace: "postgres-operator",
+			Labels: map[string]string{util.LabelCluster: "hippo"},
+		},
+		Spec: corev1.PodSpec{
+			Containers:     []corev1.Container{{Name: "database"}, {Name: "replication-cert-copy"}},
+			InitContainers: []corev1.Container{{Name: "postgres-startup"}},
+		},
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "database", RestartCount: 3},
+				{Name: "replication-cert-copy"},
+			},
+		},
+	})
+
+	assert.NilError(t, gatherClusterPodLogs(context.Background(), export))
+
+	archive := files()
+	assert.Assert(t, len(archive["hippo/logs/hippo-instance-0/database"]) > 0)
+	assert.Assert(t, len(archive["hippo/logs/hippo-instance-0/database.previous"]) > 0,
+		"previous logs of a container that restarted")
+	assert.Assert(t, len(archive["hippo/logs/hippo-instance-0/replication-cert-copy"]) > 0)
+	assert.Assert(t, len(archive["hippo/logs/hippo-instance-0/postgres-startup"]) > 0)
+	assert.Equal(t, len(archive), 4)
+}
+
+func TestGatherDescriptions(t *testing.T) {
+	labels := map[string]string{util.LabelCluster: "hippo"}
+	replicas := int32(1)
+	export, files := newTestExport(t, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "hippo-instance-0", Namespace: "postgres-operator", UID: "pod-uid",
+			Labels: labels,
+			OwnerReferences: []metav1.OwnerReference{{
+				Kind: "StatefulSet", Name: "hippo-instance", UID: "sts-uid",
+				Controller: &[]bool{true}[0],
+			}},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name: "database", Image: "postgres:14",
+				Env: []corev1.EnvVar{
+					{Name: "PGHOST", Value: "/tmp"},
+					{Name: "PGPASSWORD", Value: "hunter2"},
+				},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "postgres-data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: "hippo-pgdata",
+					},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "database", RestartCount: 2,
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+					Reason: "CrashLoopBackOff",
+				}},
+				LastTerminationState: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"},
+				},
+			}},
+		},
+	}, &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "hippo-instance", Namespace: "postgres-operator", UID: "sts-uid",
+			Labels: labels,
+		},
+		Spec: appsv1.StatefulSetSpec{Replicas: &replicas},
+	}, &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "hippo-pgdata", Namespace: "postgres-operator", Labels: labels,
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase:       corev1.ClaimBound,
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		},
+	}, &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "one", Namespace: "postgres-operator"},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "Pod", Name: "hippo-instance-0", UID: "pod-uid",
+		},
+		Type: "Warning", Reason: "BackOff", Message: "Back-off restarting failed container",
+		Source: corev1.EventSource{Component: "kubelet"},
+	}, &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "two", Namespace: "postgres-operator"},
+		InvolvedObject: corev1.ObjectReference{
+			Kind: "Pod", Name: "hippo-instance-0", UID: "older-pod-uid",
+		},
+		Message: "About an earlier Pod with the same name",
+	})
+
+	assert.NilError(t, gatherDescriptions(context.Background(), export))
+
+	archive := files()
+	pod := archive["hippo/describe/pods/hippo-instance-0"]
+	assert.Assert(t, strings.Contains(pod, "Controlled By:    StatefulSet/hippo-instance\n"), "\n%s", pod)
+	assert.Assert(t, strings.Contains(pod, "Reason:       CrashLoopBackOff"), "\n%s", pod)
+	assert.Assert(t, strings.Contains(pod, "Restart Count:  2"), "\n%s", pod)
+	assert.Assert(t, strings.Contains(pod, "PGHOST:      /tmp"), "\n%s", pod)
+	assert.Assert(t, strings.Contains(pod, "PGPASSWORD:  <REDACTED>"), "\n%s", pod)
+	assert.Assert(t, !strings.Contains(pod, "hunter2"))
+	assert.Assert(t, strings.Contains(pod, "ClaimName:   hippo-pgdata"), "\n%s", pod)
+	assert.Assert(t, strings.Contains(pod, "Warning  BackOff"), "\n%s", pod)
+	assert.Assert(t, strings.Contains(pod, "kubelet  Back-off restarting failed container"), "\n%s", pod)
+	assert.Assert(t, !strings.Contains(pod, "earlier Pod"), "\n%s", pod)
+
+	sts := archive["hippo/describe/statefulsets/hippo-instance"]
+	assert.Assert(t, strings.Contains(sts, "Replicas:         1 desired | 0 total"), "\n%s", sts)
+	assert.Assert(t, strings.Contains(sts, "Pods Status:      1 Running / 0 Waiting"), "\n%s", sts)
+	assert.Assert(t, strings.Contains(sts, "Events:         <none>"), "\n%s", sts)
+
+	pvc := archive["hippo/describe/persistentvolumeclaims/hippo-pgdata"]
+	assert.Assert(t, strings.Contains(pvc, "Status:        Bound"), "\n%s", pvc)
+	assert.Assert(t, strings.Contains(pvc, "Access Modes:  RWO"), "\n%s", pvc)
+	assert.Assert(t, strings.Contains(pvc, "Used By:       hippo-instance-0"), "\n%s", pvc)
+
+	assert.DeepEqual(t, export.redact.findings, []redaction{{
+		Path:   "hippo/describe/pods/hippo-instance-0",
+		Field:  "database.env.PGPASSWORD",
+		Reason: "sensitive environment variable",
+	}})
+}
+
+func TestSelectClusters(t *testing.T) {
+	ctx := context.Background()
+	gvr := schema.GroupVersionResource{
+		Group: "postgres-operator.crunchydata.com", Version: "v1beta1", Resource: "postgresclusters",
+	}
+	cluster := func(name string, labels map[string]string) runtime.Object {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("postgres-operator.crunchydata.com/v1beta1")
+		u.SetKind("PostgresCluster")
+		u.SetNamespace("postgres-operator")
+		u.SetName(name)
+		u.SetLabels(labels)
+		return u
+	}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "PostgresClusterList"},
+		cluster("rhino", map[string]string{"team": "payments"}),
+		cluster("hippo", map[string]string{"team": "payments"}),
+		cluster("zebra", nil),
+	).Resource(gvr).Namespace("postgres-operator")
+
+	names := func(clusters []*unstructured.Unstructured) []string {
+		var names []string
+		for _, c := range clusters {
+			names = append(names, c.GetName())
+		}
+		return names
+	}
+
+	t.Run("Names", func(t *testing.T) {
+		clusters, err := selectClusters(ctx, client, "postgres-operator",
+			[]string{"zebra", "hippo", "zebra"}, false, "")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, names(clusters), []string{"zebra", "hippo"})
+
+		_, err = selectClusters(ctx, client, "postgres-operator", []string{"lion"}, false, "")
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("All", func(t *testing.T) {
+		clusters, err := selectClusters(ctx, client, "postgres-operator", nil, true, "")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, names(clusters), []string{"hippo", "rhino", "zebra"})
+	})
+
+	t.Run("Selector", func(t *testing.T) {
+		clusters, err := selectClusters(ctx, client, "postgres-operator", nil, false, "team=payments")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, names(clusters), []string{"hippo", "rhino"})
+
+		_, err = selectClusters(ctx, client, "postgres-operator", nil, false, "team=billing")
+		assert.ErrorContains(t, err, "no PostgresClusters found in namespace postgres-operator")
+	})
+}
+
+func TestGatherMonitoring(t *testing.T) {
+	monitoring := map[string]string{"app.kubernetes.io/name": "postgres-operator-monitoring"}
+	running := corev1.PodStatus{Phase: corev1.PodRunning}
+	export, files := newTestExport(t, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "crunchy-prometheus", Namespace: "postgres-operator", Labels: monitoring,
+		},
+	}, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "crunchy-grafana", Namespace: "postgres-operator"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: monitoring},
+		}},
+	}, &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "postgres-operator"},
+	}, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "alertmanager-config", Namespace: "postgres-operator", Labels: monitoring,
+		},
+		Data: map[string]string{"alertmanager.yml": "route: {}\n"},
+	}, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "crunchy-prometheus-abc", Namespace: "postgres-operator", Labels: monitoring,
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "prometheus", Ports: []corev1.ContainerPort{{ContainerPort: 9091}},
+		}}},
+		Status: running,
+	}, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "crunchy-alertmanager-abc", Namespace: "postgres-operator", Labels: monitoring,
+		},
+		Spec:   corev1.PodSpec{Containers: []corev1.Container{{Name: "alertmanager"}}},
+		Status: running,
+	}, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "crunchy-grafana-abc", Namespace: "postgres-operator", Labels: monitoring,
+		},
+		Spec:   corev1.PodSpec{Containers: []corev1.Container{{Name: "grafana"}}},
+		Status: running,
+	})
+	export.section = &exportSection{}
+
+	var mu sync.Mutex
+	var urls []string
+	export.podExec = func(namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		mu.Lock()
+		urls = append(urls, container+" "+command[len(command)-1])
+		mu.Unlock()
+
+		assert.DeepEqual(t, command[:4], []string{"wget", "-q", "-O", "-"})
+		if container == "alertmanager" {
+			_, _ = stderr.Write([]byte("wget: can't connect to remote host\n"))
+			return errors.New("command terminated with exit code 1")
+		}
+		_, _ = stdout.Write([]byte(`{"status":"success","data":{"alerts":[]}}`))
+		return nil
+	}
+
+	assert.NilError(t, gatherMonitoring(context.Background(), export))
+	sort.Strings(urls)
+	assert.DeepEqual(t, urls, []string{
+		"alertmanager http://localhost:9093/api/v2/alerts?active=true&silenced=false&inhibited=false",
+		"prometheus http://localhost:9091/api/v1/alerts",
+	})
+	assert.DeepEqual(t, export.section.result.Errors, []string{
+		"pod crunchy-alertmanager-abc: alertmanager alerts: " +
+			"command terminated with exit code 1: wget: can't connect to remote host",
+	})
+
+	archive := files()
+	assert.Assert(t, strings.Contains(archive["monitoring/deployments/crunchy-prometheus.yaml"],
+		"name: crunchy-prometheus"))
+	assert.Assert(t, strings.Contains(archive["monitoring/configmaps/alertmanager-config.yaml"],
+		"alertmanager.yml"))
+	assert.Equal(t, archive["monitoring/alerts/crunchy-prometheus-abc/prometheus.json"], `{
+  "status": "success",
+  "data": {
+    "alerts": []
+  }
+}
+`)
+	_, ok := archive["monitoring/deployments/crunchy-grafana.yaml"]
+	assert.Assert(t, ok, "expected Deployments labeled only on their Pods")
+	_, ok = archive["monitoring/deployments/unrelated.yaml"]
+	assert.Assert(t, !ok)
+	_, ok = archive["monitoring/logs/crunchy-grafana-abc/grafana"]
+	assert.Assert(t, ok, "expected the logs of every monitoring Pod")
+}
+
+func TestGatherExporterMetrics(t *testing.T) {
+	withExporter := instancePodObject("hippo-instance1-abcd", true)
+	withExporter.Spec.Containers = []corev1.Container{{Name: util.ContainerDatabase}, {
+		Name: util.ContainerExporter, Ports: []corev1.ContainerPort{{Name: "exporter", ContainerPort: 9188}},
+	}}
+	failing := instancePodObject("hippo-instance1-efgh", false)
+	failing.Spec.Containers = []corev1.Container{{Name: util.ContainerExporter}}
+	without := instancePodObject("hippo-instance1-ijkl", false)
+	without.Spec.Containers = []corev1.Container{{Name: util.ContainerDatabase}}
+
+	export, files := newTestExport(t, withExporter, failing, without)
+	export.section = &exportSection{}
+	export.execTimeout = time.Minute
+	assert.NilError(t, unstructured.SetNestedField(export.cluster.Object,
+		"exporter-tls", "spec", "monitoring", "pgmonitor", "exporter", "customTLSSecret", "name"))
+
+	export.podExec = func(namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.Equal(t, container, util.ContainerExporter)
+		assert.Equal(t, command[0], "timeout")
+		if pod == "hippo-instance1-efgh-0" {
+			assert.Assert(t, strings.Contains(command[len(command)-1], "https://localhost:9187/metrics"))
+			_, _ = stderr.Write([]byte("curl: (7) Failed to connect\n"))
+			return errors.New("command terminated with exit code 7")
+		}
+		assert.Assert(t, strings.Contains(command[len(command)-1], "https://localhost:9188/metrics"))
+		_, _ = stdout.Write([]byte("ccp_is_in_recovery_status 2\n"))
+		return nil
+	}
+
+	assert.NilError(t, gatherExporterMetrics(context.Background(), export))
+	assert.DeepEqual(t, export.section.result.Errors, []string{
+		"instance hippo-instance1-efgh: metrics: command terminated with exit code 7: curl: (7) Failed to connect",
+	})
+
+	archive := files()
+	assert.Equal(t, archive["hippo/instances/hippo-instance1-abcd/metrics"], "ccp_is_in_recovery_status 2\n")
+	_, ok := archive["hippo/instances/hippo-instance1-ijkl/metrics"]
+	assert.Assert(t, !ok)
+}
@@ -0,0 +1,278 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formats of a support export archive
+const (
+	formatTarGz  = "tar.gz"
+	formatTarZst = "tar.zst"
+	formatZip    = "zip"
+	formatDir    = "dir"
+)
+
+// archiveFormats lists every format in the order shown in help.
+var archiveFormats = []string{formatTarGz, formatTarZst, formatZip, formatDir}
+
+// archiveFormat validates the '--format' flag.
+func archiveFormat(format string) error {
+	for _, f := range archiveFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format %q: types supported: %s", format, strings.Join(archiveFormats, ","))
+}
+
+// archiveExtension returns the extension of an archive file in format.
+func archiveExtension(format string) string {
+	if format == formatDir {
+		return ""
+	}
+	return "." + format
+}
+
+// archiveFilename validates the '--filename' flag. The name is used as given
+// and cannot be a path.
+func archiveFilename(name string) error {
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid filename %q: must be a name and not a path", name)
+	}
+	return nil
+}
+
+// archiveWriter writes the files of a support export archive in one format.
+type archiveWriter interface {
+	// writeFile copies size bytes from r to the file called name. The records
+	// describe the file; formats that cannot hold them leave them out.
+	writeFile(name string, r io.Reader, size int64, records map[string]string) error
+
+	// Close writes anything that remains. It does not close the underlying
+	// writer.
+	Close() error
+}
+
+// newArchiveWriter returns a writer of format to w. A dir archive is written
+// to the directory at path instead.
+func newArchiveWriter(ctx context.Context, format string, w io.Writer, path string) (archiveWriter, error) {
+	switch format {
+	case formatTarGz:
+		gw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+		if err != nil {
+			return nil, err
+		}
+		return &tarArchive{tw: tar.NewWriter(gw), compressor: gw}, nil
+
+	case formatTarZst:
+		zstd, err := exec.LookPath("zstd")
+		if err != nil {
+			return nil, fmt.Errorf("--format=%s requires the zstd command: %w", format, err)
+		}
+		zw, err := startCommandWriter(ctx, "zstd", zstd, []string{"--quiet", "-19", "--threads=0", "--stdout"}, w)
+		if err != nil {
+			return nil, err
+		}
+		return &tarArchive{tw: tar.NewWriter(zw), compressor: zw}, nil
+
+	case formatZip:
+		return &zipArchive{zw: zip.NewWriter(w)}, nil
+
+	case formatDir:
+		// #nosec G301 -- The directory is for the user running the export.
+		if err := os.Mkdir(path, 0o700); err != nil {
+			return nil, err
+		}
+		return &dirArchive{root: path}, nil
+	}
+	return nil, archiveFormat(format)
+}
+
+// tarArchive writes a tar archive, possibly through a compressor.
+type tarArchive struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+}
+
+func (a *tarArchive) writeFile(name string, r io.Reader, size int64, records map[string]string) error {
+	hdr := &tar.Header{
+		Name:       name,
+		Mode:       0600,
+		ModTime:    time.Now(),
+		Size:       size,
+		PAXRecords: records,
+	}
+
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(a.tw, r, size); err != nil {
+		return err
+	}
+
+	// After we write content to the file, call Flush to ensure the files block is fully padded.
+	// This shouldn't be necessary based on the tar docs: https://pkg.go.dev/archive/tar#Writer.Flush
+	return a.tw.Flush()
+}
+
+func (a *tarArchive) Close() error {
+	err := a.tw.Close()
+	if a.compressor != nil {
+		if cerr := a.compressor.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// zipArchive writes a zip archive. Records are kept in the comment of each
+// file.
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (a *zipArchive) writeFile(name string, r io.Reader, size int64, records map[string]string) error {
+	hdr := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	}
+	hdr.SetMode(0600)
+
+	var comment []string
+	for key, value := range records {
+		comment = append(comment, key+"="+value)
+	}
+	sort.Strings(comment)
+	hdr.Comment = strings.Join(comment, " ")
+
+	w, err := a.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+	_, err = io.CopyN(w, r, size)
+	return err
+}
+
+func (a *zipArchive) Close() error { return a.zw.Close() }
+
+// dirArchive writes every file of the archive to a directory. Records are
+// left out; truncated files are still listed in the manifest.
+type dirArchive struct {
+	root string
+	size int64
+}
+
+func (a *dirArchive) writeFile(name string, r io.Reader, size int64, _ map[string]string) error {
+	path, err := archivePath(a.root, name)
+	if err != nil {
+		return err
+	}
+
+	// #nosec G301 -- The directory is for the user running the export.
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	// #nosec G304 -- The path is within the directory supplied by the user.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.CopyN(file, r, size)
+	a.size += n
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (a *dirArchive) Close() error { return nil }
+
+// archivePath returns where the file called name is within root. It is an
+// error when name would be outside of root.
+func archivePath(root, name string) (string, error) {
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(root, path); err != nil ||
+		rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("unsafe file name %q", name)
+	}
+	return path, nil
+}
+
+// maxEntryNameLength is the longest name of one directory or file in the
+// archive, which is the limit of most filesystems.
+const maxEntryNameLength = 255
+
+// sanitizeEntryName returns a relative path that is safe to extract from
+// name, which may come from the names of objects and files. Characters other
+// than letters, digits, '.', '_' and '-' become '_'. Empty, "." and ".."
+// elements are removed so that the path stays within the archive.
+func sanitizeEntryName(name string) string {
+	var elements []string
+	for _, element := range strings.Split(name, "/") {
+		if element == "" || element == "." || element == ".." {
+			continue
+		}
+
+		var b strings.Builder
+		for _, r := range element {
+			switch {
+			case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9',
+				r == '.', r == '_', r == '-':
+				b.WriteRune(r)
+			default:
+				b.WriteRune('_')
+			}
+		}
+
+		element = b.String()
+		if len(element) > maxEntryNameLength {
+			element = element[:maxEntryNameLength]
+		}
+		elements = append(elements, element)
+	}
+
+	if len(elements) == 0 {
+		return "_"
+	}
+	return strings.Join(elements, "/")
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gotest.tools/v3/assert"
+)
+
+func TestSanitizeEntryName(t *testing.T) {
+	for _, tc := range []struct{ name, expected string }{
+		{"hippo/pods/hippo-instance1-abcd-0.yaml", "hippo/pods/hippo-instance1-abcd-0.yaml"},
+		{"operator/clusterroles/system:controller:x.yaml", "operator/clusterroles/system_controller_x.yaml"},
+		{"/etc/passwd", "etc/passwd"},
+		{"hippo/../../etc/passwd", "hippo/etc/passwd"},
+		{"hippo//./logs\\cli", "hippo/logs_cli"},
+		{"hippo/héllo wörld", "hippo/h_llo_w_rld"},
+		{"..", "_"},
+		{"", "_"},
+		{"a/" + strings.Repeat("x", 300), "a/" + strings.Repeat("x", 255)},
+	} {
+		assert.Equal(t, sanitizeEntryName(tc.name), tc.expected, "name %q", tc.name)
+	}
+}
+
+func TestArchivePath(t *testing.T) {
+	path, err := archivePath("/tmp/export", "hippo/events")
+	assert.NilError(t, err)
+	assert.Equal(t, path, filepath.FromSlash("/tmp/export/hippo/events"))
+
+	for _, name := range []string{"../escape", "hippo/../../escape", ".", ""} {
+		_, err := archivePath("/tmp/export", name)
+		assert.ErrorContains(t, err, "unsafe file name", "name %q", name)
+	}
+}
+
+func TestArchiveFlags(t *testing.T) {
+	for _, format := range archiveFormats {
+		assert.NilError(t, archiveFormat(format))
+	}
+	assert.ErrorContains(t, archiveFormat("rar"), `invalid format "rar": types supported: tar.gz,tar.zst,zip,dir`)
+
+	assert.Equal(t, archiveExtension(formatTarZst), ".tar.zst")
+	assert.Equal(t, archiveExtension(formatDir), "")
+
+	assert.NilError(t, archiveFilename("hippo-incident.tar.gz"))
+	for _, name := range []string{"..", "a/b", `a\b`} {
+		assert.ErrorContains(t, archiveFilename(name), "must be a name and not a path")
+	}
+
+	assert.NilError(t, archiveTargetFlags("-", formatTarZst, "", "age", ""))
+	assert.NilError(t, archiveTargetFlags(".", formatDir, "incident", "", ""))
+	assert.ErrorContains(t, archiveTargetFlags("-", formatDir, "", "", ""), "cannot be written to stdout")
+	assert.ErrorContains(t, archiveTargetFlags("-", formatZip, "x.zip", "", ""), "--filename cannot be used")
+	assert.ErrorContains(t, archiveTargetFlags("-", formatZip, "", "", "s3://b"), "--upload cannot be used")
+	assert.ErrorContains(t, archiveTargetFlags(".", formatDir, "", "gpg", ""), "--encrypt cannot be used")
+	assert.ErrorContains(t, archiveTargetFlags(".", formatDir, "", "", "s3://b"), "--upload cannot be used")
+}
+
+func TestArchiveFormatsRoundTrip(t *testing.T) {
+	files := [][2]string{
+		{"hippo/events", "LAST SEEN   TYPE\n"},
+		{"hippo/pods/../../hippo-instance1-abcd-0.yaml", "metadata: {}\n"},
+		{"operator/clusterroles/system:x.yaml", "kind: ClusterRole\n"},
+	}
+
+	for _, format := range archiveFormats {
+		t.Run(format, func(t *testing.T) {
+			if format == formatTarZst {
+				if _, err := exec.LookPath("zstd"); err != nil {
+					t.Skip("requires the zstd command")
+				}
+			}
+
+			path := filepath.Join(t.TempDir(), "export"+archiveExtension(format))
+			var out bytes.Buffer
+			archive, err := newArchiveWriter(context.Background(), format, &out, path)
+			assert.NilError(t, err)
+
+			cmd := &cobra.Command{}
+			manifest := &archiveManifest{Created: time.Now()}
+			for _, file := range files {
+				assert.NilError(t, manifest.write(archive, strings.NewReader(file[1]),
+					int64(len(file[1])), file[0], map[string]string{"PGO.truncated": "max-log-size"},
+					sourceAPI, time.Now(), cmd))
+			}
+			assert.NilError(t, writeManifest(archive, manifest, cmd))
+			assert.NilError(t, archive.Close())
+
+			if format != formatDir {
+				assert.NilError(t, os.WriteFile(path, out.Bytes(), 0o600))
+			}
+
+			read, err := openSupportArchive(context.Background(), path)
+			assert.NilError(t, err)
+			// A directory is read in lexical order rather than the order written.
+			names := append([]string{}, read.names...)
+			sort.Strings(names)
+			assert.DeepEqual(t, names, []string{
+				"hippo/events",
+				"hippo/pods/hippo-instance1-abcd-0.yaml",
+				"manifest.json",
+				"operator/clusterroles/system_x.yaml",
+			})
+
+			mismatched, missing := read.verify()
+			assert.Equal(t, len(mismatched), 0)
+			assert.Equal(t, len(missing), 0)
+			assert.Equal(t, string(read.files["hippo/events"]), "LAST SEEN   TYPE\n")
+		})
+	}
+
+	t.Run("ZipRecords", func(t *testing.T) {
+		var out bytes.Buffer
+		archive, err := newArchiveWriter(context.Background(), formatZip, &out, "")
+		assert.NilError(t, err)
+		assert.NilError(t, archive.writeFile("hippo/logs/cli", strings.NewReader("abc"), 3,
+			map[string]string{"PGO.truncated": "max-log-size", "PGO.originalsize": "10"}))
+		assert.NilError(t, archive.Close())
+
+		zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		assert.NilError(t, err)
+		assert.Equal(t, zr.File[0].Comment, "PGO.originalsize=10 PGO.truncated=max-log-size")
+	})
+
+	t.Run("Encrypted", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "export.tar.gz.age")
+		assert.NilError(t, os.WriteFile(path, []byte("age-encryption.org/v1\n-> X25519"), 0o600))
+
+		_, err := openSupportArchive(context.Background(), path)
+		assert.ErrorContains(t, err, "decrypt it first")
+	})
+}
@@ -0,0 +1,719 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+)
+
+// newSupportInspectCommand returns the inspect subcommand of the support
+// command. It summarizes a support export archive without a Kubernetes cluster.
+func newSupportInspectCommand() *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "inspect ARCHIVE",
+		Short: "Summarize a support export archive",
+		Long: `Summarize a support export archive without connecting to Kubernetes.
+
+For every PostgresCluster in the archive, the summary shows highlights of its
+spec and status, the Pods that are not running or not ready, the most recent
+warning events and the state of Patroni. It also checks every file of the
+archive against the checksums listed in its 'manifest.json' file.
+
+The archive can be in any format written by 'pgo support export': tar.gz,
+tar.zst, zip, or a directory. Encrypted archives must be decrypted first.
+
+#### RBAC Requirements
+    None. This command reads a local file.`,
+	}
+
+	var maxEvents int
+	cmd.Flags().IntVar(&maxEvents, "max-events", 20,
+		"Maximum number of warning events to show for each PostgresCluster")
+
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.Example = internal.FormatExample(`
+# Summarize a support export archive
+kubectl pgo support inspect crunchy_k8s_support_export_2023-01-01-120000.tar.gz
+
+# Summarize an archive exported with --format=dir
+kubectl pgo support inspect crunchy_k8s_support_export_2023-01-01-120000
+
+# Show every warning event
+kubectl pgo support inspect crunchy_k8s_support_export_2023-01-01-120000.tar.gz --max-events 0
+	`)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		archive, err := openSupportArchive(context.Background(), args[0])
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", args[0], err)
+		}
+
+		return archive.inspect(cmd.OutOrStdout(), maxEvents)
+	}
+
+	return cmd
+}
+
+// supportArchive is what inspect reads from a support export archive. It holds
+// the checksum and size of every file but the content of only the few files
+// that it summarizes.
+type supportArchive struct {
+	names    []string
+	sizes    map[string]int64
+	sums     map[string]string
+	files    map[string][]byte
+	manifest *archiveManifest
+}
+
+// inspectedFile returns true for the files of an archive that inspect reads.
+func inspectedFile(name string) bool {
+	parts := strings.Split(name, "/")
+	switch {
+	case name == manifestPath:
+		return true
+	case len(parts) == 2:
+		return parts[1] == "summary.json" || parts[1] == "postgrescluster.yaml" ||
+			parts[1] == "events" || parts[1] == "patroni-info"
+	case len(parts) == 3:
+		return (parts[1] == "pods" && strings.HasSuffix(parts[2], ".yaml")) ||
+			(parts[1] == "instances" && parts[2] == "roles")
+	case len(parts) == 4:
+		return parts[1] == "instances" && parts[3] == "patroni-info"
+	}
+	return false
+}
+
+// openSupportArchive reads the support export archive at path in any of the
+// formats written by support export.
+func openSupportArchive(ctx context.Context, path string) (*supportArchive, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return readSupportArchiveDir(path)
+	}
+
+	// #nosec G304 -- We intentionally read the file supplied by the user.
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, len(ageMagic))
+	n, _ := io.ReadFull(file, magic)
+	magic = magic[:n]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		zr, err := zip.NewReader(file, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		return readSupportArchiveZip(zr)
+
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return readSupportArchiveZstd(ctx, file)
+
+	case bytes.HasPrefix(magic, []byte(ageMagic)):
+		return nil, fmt.Errorf("the archive is encrypted with age; decrypt it first")
+	}
+	return readSupportArchive(file)
+}
+
+// ageMagic begins every file encrypted by age.
+const ageMagic = "age-encryption.org/"
+
+// readSupportArchive reads a gzipped tar archive written by support export.
+func readSupportArchive(r io.Reader) (*supportArchive, error) {
+	gr, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+	return readSupportArchiveTar(gr)
+}
+
+// readSupportArchiveZstd reads a tar archive compressed by zstd using the
+// zstd command.
+func readSupportArchiveZstd(ctx context.Context, r io.Reader) (*supportArchive, error) {
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		return nil, fmt.Errorf("reading a tar.zst archive requires the zstd command: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	// #nosec G204 -- The command is zstd and not user input.
+	cmd := exec.CommandContext(ctx, zstd, "--decompress", "--quiet", "--stdout")
+	cmd.Stdin, cmd.Stderr = r, &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := readSupportArchiveTar(stdout)
+	if err != nil {
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+	if werr := cmd.Wait(); err == nil && werr != nil {
+		err = fmt.Errorf("zstd failed: %w: %s", werr, strings.TrimSpace(stderr.String()))
+	}
+	return archive, err
+}
+
+// readSupportArchiveTar reads an uncompressed tar archive.
+func readSupportArchiveTar(r io.Reader) (*supportArchive, error) {
+	archive := newSupportArchive()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := archive.add(hdr.Name, tr); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.readManifest(); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// readSupportArchiveZip reads a zip archive.
+func readSupportArchiveZip(zr *zip.Reader) (*supportArchive, error) {
+	archive := newSupportArchive()
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = archive.add(f.Name, rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.readManifest(); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// readSupportArchiveDir reads an archive written as a directory. Files are
+// read in lexical order.
+func readSupportArchiveDir(root string) (*supportArchive, error) {
+	archive := newSupportArchive()
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		// #nosec G304 -- We intentionally read the directory supplied by the user.
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return archive.add(filepath.ToSlash(rel), file)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := archive.readManifest(); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+func newSupportArchive() *supportArchive {
+	return &supportArchive{
+		sizes: map[string]int64{},
+		sums:  map[string]string{},
+		files: map[string][]byte{},
+	}
+}
+
+// add reads one file of the archive. It keeps the content of only those
+// files that inspect reads.
+func (archive *supportArchive) add(name string, r io.Reader) error {
+	var content bytes.Buffer
+	sum := sha256.New()
+	w := io.Writer(sum)
+	if inspectedFile(name) {
+		w = io.MultiWriter(sum, &content)
+	}
+	size, err := io.Copy(w, r)
+	if err != nil {
+		return err
+	}
+
+	archive.names = append(archive.names, name)
+	archive.sizes[name] = size
+	archive.sums[name] = hex.EncodeToString(sum.Sum(nil))
+	if inspectedFile(name) {
+		archive.files[name] = content.Bytes()
+	}
+	return nil
+}
+
+// readManifest parses the manifest of the archive, if any.
+func (archive *supportArchive) readManifest() error {
+	if b, ok := archive.files[manifestPath]; ok {
+		archive.manifest = &archiveManifest{}
+		if err := json.Unmarshal(b, archive.manifest); err != nil {
+			return fmt.Errorf("invalid %s: %w", manifestPath, err)
+		}
+	}
+	return nil
+}
+
+// clusters returns the name of every PostgresCluster in the archive.
+func (archive *supportArchive) clusters() []string {
+	if archive.manifest != nil && len(archive.manifest.Clusters) > 0 {
+		return archive.manifest.Clusters
+	}
+
+	var names []string
+	for name := range archive.files {
+		if dir, file := path.Split(name); file == "postgrescluster.yaml" {
+			names = append(names, strings.TrimSuffix(dir, "/"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sharedFile returns the content of a file that is common to every
+// PostgresCluster, such as the events of their namespace. It looks in the
+// directory of cluster before the shared directory.
+func (archive *supportArchive) sharedFile(cluster, name string) ([]byte, bool) {
+	if b, ok := archive.files[cluster+"/"+name]; ok {
+		return b, true
+	}
+	if archive.manifest != nil && archive.manifest.Shared != "" {
+		b, ok := archive.files[archive.manifest.Shared+"/"+name]
+		return b, ok
+	}
+	return nil, false
+}
+
+// verify returns the files whose size or checksum differ from the manifest
+// and the files listed in the manifest that are missing from the archive.
+func (archive *supportArchive) verify() (mismatched, missing []string) {
+	for _, entry := range archive.manifest.Entries {
+		sum, ok := archive.sums[entry.Path]
+		switch {
+		case !ok:
+			missing = append(missing, entry.Path)
+		case sum != entry.SHA256 || archive.sizes[entry.Path] != entry.Size:
+			mismatched = append(mismatched, entry.Path)
+		}
+	}
+	return mismatched, missing
+}
+
+// inspect writes a summary of the archive to w. It shows at most maxEvents
+// warning events for each PostgresCluster; zero means no limit.
+func (archive *supportArchive) inspect(w io.Writer, maxEvents int) error {
+	if err := archive.inspectArchive(w); err != nil {
+		return err
+	}
+
+	clusters := archive.clusters()
+	if len(clusters) == 0 {
+		fmt.Fprintln(w, "\nNo PostgresCluster found in the archive")
+	}
+	for _, cluster := range clusters {
+		if err := archive.inspectCluster(w, cluster, maxEvents); err != nil {
+			return fmt.Errorf("%s: %w", cluster, err)
+		}
+	}
+	return nil
+}
+
+// inspectArchive writes the size of the archive, whether it matches its
+// manifest and the outcome of the export.
+func (archive *supportArchive) inspectArchive(w io.Writer) error {
+	var total int64
+	for _, size := range archive.sizes {
+		total += size
+	}
+
+	fmt.Fprintln(w, "Archive")
+	tw := printers.GetNewTabWriter(w)
+	fmt.Fprintf(tw, "  Files:\t%d (%.2f MiB)\n", len(archive.names), float64(total)/mebibyte)
+
+	if archive.manifest == nil {
+		fmt.Fprintf(tw, "  Manifest:\tnot found, checksums not verified\n")
+	} else {
+		fmt.Fprintf(tw, "  Created:\t%s\n", archive.manifest.Created.Format(time.RFC3339))
+		mismatched, missing := archive.verify()
+		switch {
+		case len(mismatched) == 0 && len(missing) == 0:
+			fmt.Fprintf(tw, "  Manifest:\t%d files, every checksum matches\n",
+				len(archive.manifest.Entries))
+		default:
+			fmt.Fprintf(tw, "  Manifest:\t%d files, %d do not match, %d missing\n",
+				len(archive.manifest.Entries), len(mismatched), len(missing))
+			for _, name := range mismatched {
+				fmt.Fprintf(tw, "    Does not match:\t%s\n", name)
+			}
+			for _, name := range missing {
+				fmt.Fprintf(tw, "    Missing:\t%s\n", name)
+			}
+		}
+	}
+
+	summaries := map[string]bool{}
+	for _, cluster := range archive.clusters() {
+		b, ok := archive.sharedFile(cluster, "summary.json")
+		if !ok || summaries[string(b)] {
+			continue
+		}
+		summaries[string(b)] = true
+
+		var summary struct {
+			Status   string          `json:"status"`
+			Sections []sectionResult `json:"sections"`
+		}
+		if err := json.Unmarshal(b, &summary); err != nil {
+			return fmt.Errorf("invalid summary.json: %w", err)
+		}
+
+		fmt.Fprintf(tw, "  Export Status:\t%s\n", summary.Status)
+		for _, section := range summary.Sections {
+			if section.Status == sectionPartial || section.Status == sectionFailed {
+				fmt.Fprintf(tw, "    %s:\t%s (%d errors)\n",
+					section.label(), section.Status, len(section.Errors))
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+// inspectCluster writes the highlights of one PostgresCluster.
+func (archive *supportArchive) inspectCluster(w io.Writer, cluster string, maxEvents int) error {
+	j, err := yaml.YAMLToJSON(archive.files[cluster+"/postgrescluster.yaml"])
+	if err != nil {
+		return err
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(j); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nPostgresCluster %s/%s\n", obj.GetNamespace(), obj.GetName())
+	tw := printers.GetNewTabWriter(w)
+	for _, highlight := range clusterHighlights(obj) {
+		fmt.Fprintf(tw, "  %s:\t%s\n", highlight[0], highlight[1])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if err := archive.inspectPods(w, cluster); err != nil {
+		return err
+	}
+	archive.inspectEvents(w, cluster, maxEvents)
+	archive.inspectPatroni(w, cluster)
+	return nil
+}
+
+// clusterHighlights returns the parts of the spec and status of a
+// PostgresCluster that matter most when triaging it, as label and value pairs.
+func clusterHighlights(obj *unstructured.Unstructured) [][2]string {
+	var highlights [][2]string
+	add := func(label, format string, args ...any) {
+		highlights = append(highlights, [2]string{label, fmt.Sprintf(format, args...)})
+	}
+	value := func(fields ...string) any {
+		v, _, _ := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+		return v
+	}
+
+	add("Postgres Version", "%v", value("spec", "postgresVersion"))
+	if image := value("spec", "image"); image != nil {
+		add("Image", "%v", image)
+	}
+
+	ready := map[string]string{}
+	statuses, _, _ := unstructured.NestedSlice(obj.Object, "status", "instances")
+	for _, s := range statuses {
+		if s, ok := s.(map[string]any); ok {
+			ready[fmt.Sprint(s["name"])] = fmt.Sprintf("%v/%v ready",
+				orDefault(s["readyReplicas"], 0), orDefault(s["replicas"], 0))
+		}
+	}
+
+	sets, _, _ := unstructured.NestedSlice(obj.Object, "spec", "instances")
+	for _, set := range sets {
+		set, ok := set.(map[string]any)
+		if !ok {
+			continue
+		}
+		name := fmt.Sprint(set["name"])
+		storage, _, _ := unstructured.NestedFieldNoCopy(set,
+			"dataVolumeClaimSpec", "resources", "requests", "storage")
+		status := ready[name]
+		if status == "" {
+			status = "no status"
+		}
+		add("Instance Set", "%s: %v replicas, %v storage, %s",
+			name, orDefault(set["replicas"], 1), orDefault(storage, "<none>"), status)
+	}
+
+	repos, _, _ := unstructured.NestedSlice(obj.Object, "spec", "backups", "pgbackrest", "repos")
+	var names []string
+	for _, repo := range repos {
+		repo, ok := repo.(map[string]any)
+		if !ok {
+			continue
+		}
+		kind := "unknown"
+		for _, k := range []string{"volume", "s3", "gcs", "azure"} {
+			if _, ok := repo[k]; ok {
+				kind = k
+			}
+		}
+		names = append(names, fmt.Sprintf("%v (%s)", repo["name"], kind))
+	}
+	if len(names) > 0 {
+		add("Backup Repos", "%s", strings.Join(names, ", "))
+	}
+
+	if value("spec", "proxy", "pgBouncer") != nil {
+		add("PgBouncer", "%v replicas", orDefault(value("spec", "proxy", "pgBouncer", "replicas"), 1))
+	}
+	if value("spec", "monitoring", "pgmonitor", "exporter") != nil {
+		add("Monitoring", "pgMonitor exporter")
+	}
+	if enabled, _ := value("spec", "standby", "enabled").(bool); enabled {
+		add("Standby", "enabled, repo %v, host %v",
+			orDefault(value("spec", "standby", "repoName"), "<none>"),
+			orDefault(value("spec", "standby", "host"), "<none>"))
+	}
+	if shutdown, _ := value("spec", "shutdown").(bool); shutdown {
+		add("Shutdown", "true")
+	}
+	return highlights
+}
+
+// orDefault returns v or, when v is nil, def.
+func orDefault(v, def any) any {
+	if v == nil {
+		return def
+	}
+	return v
+}
+
+// tableColumn returns the cell of line in the column of an aligned table
+// named heading. The column ends where the next column, named next, starts.
+func tableColumn(header, line, heading, next string) string {
+	start := strings.Index(header, heading)
+	end := strings.Index(header, next)
+	if start < 0 || end <= start || len(line) <= start {
+		return ""
+	}
+	if len(line) < end {
+		end = len(line)
+	}
+	return strings.TrimSpace(line[start:end])
+}
+
+// inspectPods writes a table of the PostgresCluster Pods that are not running
+// or not ready.
+func (archive *supportArchive) inspectPods(w io.Writer, cluster string) error {
+	var pods []corev1.Pod
+	for name, b := range archive.files {
+		if dir, _ := path.Split(name); dir == cluster+"/pods/" {
+			var pod corev1.Pod
+			if err := yaml.Unmarshal(b, &pod); err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	fmt.Fprintln(w, "\nFailing Pods")
+	tw := printers.GetNewTabWriter(w)
+	var failing int
+	for i := range pods {
+		reason, ok := podProblem(&pods[i])
+		if !ok {
+			continue
+		}
+		if failing == 0 {
+			fmt.Fprintf(tw, "  NAME\tPHASE\tREADY\tRESTARTS\tREASON\n")
+		}
+		failing++
+
+		var ready, restarts int32
+		for _, status := range pods[i].Status.ContainerStatuses {
+			if status.Ready {
+				ready++
+			}
+			restarts += status.RestartCount
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%d/%d\t%d\t%s\n", pods[i].Name, pods[i].Status.Phase,
+			ready, len(pods[i].Spec.Containers), restarts, reason)
+	}
+	if failing == 0 {
+		fmt.Fprintf(tw, "  None of %d Pods\n", len(pods))
+	}
+	return tw.Flush()
+}
+
+// podProblem returns why a Pod is not running or not ready, if it is not.
+func podProblem(pod *corev1.Pod) (string, bool) {
+	if pod.Status.Phase == corev1.PodSucceeded {
+		return "", false
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{},
+		pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		switch {
+		case status.State.Waiting != nil && status.State.Waiting.Reason != "":
+			return status.Name + ": " + status.State.Waiting.Reason, true
+		case status.State.Terminated != nil && status.State.Terminated.ExitCode != 0:
+			return status.Name + ": " + status.State.Terminated.Reason, true
+		}
+	}
+
+	if pod.Status.Phase != corev1.PodRunning {
+		reason := pod.Status.Reason
+		if reason == "" {
+			reason = string(pod.Status.Phase)
+		}
+		return reason, true
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if !status.Ready {
+			return status.Name + ": not ready", true
+		}
+	}
+	return "", false
+}
+
+// inspectEvents writes the last maxEvents warning events of the events table
+// of a PostgresCluster.
+func (archive *supportArchive) inspectEvents(w io.Writer, cluster string, maxEvents int) {
+	events, _ := archive.sharedFile(cluster, "events")
+	lines := strings.Split(strings.TrimRight(string(events), "\n"), "\n")
+
+	var warnings []string
+	for _, line := range lines[1:] {
+		if tableColumn(lines[0], line, "TYPE", "REASON") == "Warning" {
+			warnings = append(warnings, line)
+		}
+	}
+
+	shown := warnings
+	if maxEvents > 0 && len(shown) > maxEvents {
+		shown = shown[len(shown)-maxEvents:]
+	}
+
+	fmt.Fprintf(w, "\nWarning Events (%d of %d)\n", len(shown), len(warnings))
+	if len(shown) == 0 {
+		fmt.Fprintln(w, "  None")
+		return
+	}
+	fmt.Fprintln(w, "  "+lines[0])
+	for _, line := range shown {
+		fmt.Fprintln(w, "  "+line)
+	}
+}
+
+// inspectPatroni writes the role of every instance and the state of the
+// Patroni cluster as seen by the primary.
+func (archive *supportArchive) inspectPatroni(w io.Writer, cluster string) {
+	fmt.Fprintln(w, "\nPatroni")
+
+	info, ok := archive.files[cluster+"/patroni-info"]
+	if roles, found := archive.files[cluster+"/instances/roles"]; found {
+		ok = false
+		lines := strings.Split(strings.TrimRight(string(roles), "\n"), "\n")
+		for i, line := range lines {
+			fmt.Fprintln(w, "  "+line)
+
+			if i > 0 && tableColumn(lines[0], line, "ROLE", "NODE") == "primary" {
+				instance := tableColumn(lines[0], line, "INSTANCE", "INSTANCE SET")
+				info, ok = archive.files[cluster+"/instances/"+instance+"/patroni-info"]
+			}
+		}
+		fmt.Fprintln(w)
+	}
+
+	if !ok {
+		fmt.Fprintln(w, "  No Patroni info of the primary instance")
+		return
+	}
+
+	// Show only the output of 'patronictl list'.
+	listing, _, _ := strings.Cut(string(info), "patronictl history\n")
+	for _, line := range strings.Split(strings.TrimRight(listing, "\n"), "\n") {
+		fmt.Fprintln(w, "  "+line)
+	}
+}
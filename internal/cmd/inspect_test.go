@@ -0,0 +1,244 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gotest.tools/v3/assert"
+)
+
+// testSupportArchive returns a gzipped tar archive of files, in order, with a
+// manifest of them. The manifest lists the files in listed rather than their
+// actual content, when present.
+func testSupportArchive(t *testing.T, files [][2]string, listed map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := &tarArchive{tw: tar.NewWriter(gw)}
+	cmd := &cobra.Command{}
+
+	collected := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	manifest := &archiveManifest{Created: collected}
+	for _, file := range files {
+		content := file[1]
+		if s, ok := listed[file[0]]; ok {
+			content = s
+		}
+		assert.NilError(t, manifest.write(&tarArchive{tw: tar.NewWriter(&bytes.Buffer{})},
+			strings.NewReader(content), int64(len(content)), file[0], nil, sourceAPI, collected, cmd))
+		assert.NilError(t, writeArchiveReader(tw,
+			strings.NewReader(file[1]), int64(len(file[1])), file[0], nil, cmd))
+	}
+	assert.NilError(t, writeManifest(tw, manifest, cmd))
+	assert.NilError(t, tw.Close())
+	assert.NilError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveManifest(t *testing.T) {
+	export, _ := newTestExport(t)
+
+	var archive bytes.Buffer
+	tw := &tarArchive{tw: tar.NewWriter(&archive)}
+	manifest := &archiveManifest{}
+	budget := &archiveBudget{limit: 3}
+
+	collected := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.NilError(t, budget.write(tw, manifest, archiveEntry{
+		name: "hippo/pods/list", source: sourceAPI, collected: collected, content: []byte("abcdef"),
+	}, export.cmd))
+
+	assert.DeepEqual(t, manifest.Entries, []manifestEntry{{
+		Path: "hippo/pods/list", Source: sourceAPI, Collected: collected, Size: 3,
+		// sha256 of "abc", which is what was written
+		SHA256:    "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		Truncated: "max-archive-size",
+	}})
+}
+
+func TestSupportInspect(t *testing.T) {
+	files := [][2]string{
+		{"hippo/postgrescluster.yaml", `
+apiVersion: postgres-operator.crunchydata.com/v1beta1
+kind: PostgresCluster
+metadata:
+  name: hippo
+  namespace: postgres-operator
+spec:
+  postgresVersion: 15
+  instances:
+  - name: instance1
+    replicas: 2
+    dataVolumeClaimSpec:
+      resources:
+        requests:
+          storage: 1Gi
+  backups:
+    pgbackrest:
+      repos:
+      - name: repo1
+        volume: {}
+      - name: repo2
+        s3: {}
+  proxy:
+    pgBouncer: {}
+status:
+  instances:
+  - name: instance1
+    replicas: 2
+    readyReplicas: 1
+`},
+		{"hippo/pods/hippo-instance1-abcd-0.yaml", `
+metadata:
+  name: hippo-instance1-abcd-0
+spec:
+  containers:
+  - name: database
+status:
+  phase: Running
+  containerStatuses:
+  - name: database
+    ready: true
+`},
+		{"hippo/pods/hippo-instance1-efgh-0.yaml", `
+metadata:
+  name: hippo-instance1-efgh-0
+spec:
+  containers:
+  - name: database
+status:
+  phase: Running
+  containerStatuses:
+  - name: database
+    ready: false
+    restartCount: 4
+    state:
+      waiting:
+        reason: CrashLoopBackOff
+`},
+		{"hippo/events", "" +
+			"Last Seen   TYPE      REASON    OBJECT                       MESSAGE\n" +
+			"5m          Normal    Started   Pod/hippo-instance1-abcd-0   Started container\n" +
+			"2m          Warning   BackOff   Pod/hippo-instance1-efgh-0   Back-off restarting\n" +
+			"1m          Warning   Failed    Pod/hippo-instance1-efgh-0   Error: failed\n"},
+		{"hippo/instances/roles", "" +
+			"INSTANCE               INSTANCE SET   POD                      ROLE      NODE     PHASE\n" +
+			"hippo-instance1-abcd   instance1      hippo-instance1-abcd-0   primary   node-a   Running\n" +
+			"hippo-instance1-efgh   instance1      hippo-instance1-efgh-0   replica   node-b   Running\n"},
+		{"hippo/instances/hippo-instance1-abcd/patroni-info", "" +
+			"patronictl list\n| Member | Role |\n" +
+			"patronictl history\n| TL |\n"},
+		{"hippo/summary.json", `{"status": "partial", "sections": [
+			{"name": "pods", "status": "complete"},
+			{"name": "pglogs", "status": "partial", "errors": ["one", "two"]}
+		]}`},
+	}
+
+	t.Run("Summary", func(t *testing.T) {
+		archive, err := readSupportArchive(bytes.NewReader(testSupportArchive(t, files, nil)))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, archive.clusters(), []string{"hippo"})
+
+		var out strings.Builder
+		assert.NilError(t, archive.inspect(&out, 1))
+
+		for _, expected := range []string{
+			"Manifest:        7 files, every checksum matches\n",
+			"Export Status:   partial\n",
+			"  pglogs:        partial (2 errors)\n",
+			"PostgresCluster postgres-operator/hippo\n",
+			"Postgres Version:   15\n",
+			"Instance Set:       instance1: 2 replicas, 1Gi storage, 1/2 ready\n",
+			"Backup Repos:       repo1 (volume), repo2 (s3)\n",
+			"PgBouncer:          1 replicas\n",
+			"hippo-instance1-efgh-0   Running   0/1     4          database: CrashLoopBackOff\n",
+			"Warning Events (1 of 2)\n",
+			"1m          Warning   Failed",
+			"  | Member | Role |\n",
+		} {
+			assert.Assert(t, strings.Contains(out.String(), expected),
+				"missing %q in:\n%s", expected, out.String())
+		}
+		assert.Assert(t, !strings.Contains(out.String(), "hippo-instance1-abcd-0   Running"))
+		assert.Assert(t, !strings.Contains(out.String(), "| TL |"))
+	})
+
+	t.Run("Mismatch", func(t *testing.T) {
+		archive, err := readSupportArchive(bytes.NewReader(testSupportArchive(t, files,
+			map[string]string{"hippo/events": "something else"})))
+		assert.NilError(t, err)
+
+		mismatched, missing := archive.verify()
+		assert.DeepEqual(t, mismatched, []string{"hippo/events"})
+		assert.Equal(t, len(missing), 0)
+	})
+
+	t.Run("SharedDirectory", func(t *testing.T) {
+		var moved [][2]string
+		for _, file := range files {
+			switch file[0] {
+			case "hippo/events", "hippo/summary.json":
+				file[0] = "shared/" + strings.TrimPrefix(file[0], "hippo/")
+			}
+			moved = append(moved, file)
+		}
+		moved = append(moved, [2]string{"rhino/postgrescluster.yaml", `
+apiVersion: postgres-operator.crunchydata.com/v1beta1
+kind: PostgresCluster
+metadata:
+  name: rhino
+  namespace: postgres-operator
+`})
+
+		archive, err := readSupportArchive(bytes.NewReader(testSupportArchive(t, moved, nil)))
+		assert.NilError(t, err)
+		archive.manifest.Clusters = []string{"hippo", "rhino"}
+		archive.manifest.Shared = "shared"
+		assert.DeepEqual(t, archive.clusters(), []string{"hippo", "rhino"})
+
+		var out strings.Builder
+		assert.NilError(t, archive.inspect(&out, 0))
+		assert.Equal(t, strings.Count(out.String(), "Export Status:   partial\n"), 1, out.String())
+		assert.Equal(t, strings.Count(out.String(), "Warning Events (2 of 2)\n"), 2, out.String())
+		assert.Assert(t, strings.Contains(out.String(), "PostgresCluster postgres-operator/rhino\n"))
+	})
+
+	t.Run("NoManifest", func(t *testing.T) {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := &tarArchive{tw: tar.NewWriter(gw)}
+		assert.NilError(t, writeArchiveReader(tw, strings.NewReader(files[0][1]),
+			int64(len(files[0][1])), files[0][0], nil, &cobra.Command{}))
+		assert.NilError(t, tw.Close())
+		assert.NilError(t, gw.Close())
+
+		archive, err := readSupportArchive(&buf)
+		assert.NilError(t, err)
+
+		var out strings.Builder
+		assert.NilError(t, archive.inspect(&out, 0))
+		assert.Assert(t, strings.Contains(out.String(), "not found, checksums not verified"))
+		assert.Assert(t, strings.Contains(out.String(), "None of 0 Pods"))
+		assert.Assert(t, strings.Contains(out.String(), "No Patroni info of the primary instance"))
+	})
+}
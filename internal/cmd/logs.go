@@ -0,0 +1,382 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+// newLogsCommand returns the logs subcommand of the PGO plugin. It prints the
+// logs of every container of a PostgresCluster, or the Postgres log files of
+// its instances, as one stream.
+func newLogsCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs CLUSTER_NAME",
+		Short: "Print the logs of a PostgresCluster",
+		Long: `Print the logs of every container in the Pods of a PostgresCluster, including
+init containers. Each line starts with the Pod and container it came from, and
+the lines of every container are merged as they arrive.
+
+With --postgres-logs, the Postgres log files in the data directory of each
+instance are printed instead. The --since flag selects every file written since
+then, and --tail counts the last lines of those files together. Only the newest
+file is followed.
+
+Pods created after the command starts are not followed.
+
+#### RBAC Requirements
+    Resources  Verbs
+    ---------  -----
+    pods       [list]
+    pods/log   [get]
+    pods/exec  [create]
+
+###### Note: The pods/exec permission is only required with --postgres-logs.`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Print the logs of every container of the 'hippo' postgrescluster
+pgo logs hippo
+
+# Follow the logs of the database container of the primary instance
+pgo logs hippo --follow --role=primary --container=database
+
+# Print the last hour of the logs of the pgBackRest containers
+pgo logs hippo --container=pgbackrest --since=1h
+
+# Follow the Postgres log file of every replica, starting with its last 20 lines
+pgo logs hippo --postgres-logs --role=replica --tail=20 -f
+`)
+
+	logs := clusterLogs{Config: config}
+
+	cmd.Flags().BoolVarP(&logs.Follow, "follow", "f", false,
+		"continue to print new lines as they are written")
+	cmd.Flags().StringVar(&logs.Role, "role", "",
+		"only the instance Pods with this role. types supported: primary,replica")
+	cmd.Flags().StringVarP(&logs.Container, "container", "c", "",
+		"only the containers with this name, such as database or pgbackrest")
+	cmd.Flags().DurationVar(&logs.Since, "since", 0,
+		"only lines newer than a relative duration like 5s, 2m, or 3h")
+	cmd.Flags().Int64Var(&logs.Tail, "tail", -1,
+		"number of recent lines to print of each stream; -1 prints every line")
+	cmd.Flags().BoolVar(&logs.PostgresLogs, "postgres-logs", false,
+		"print the Postgres log files of each instance rather than container logs")
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		logs.PostgresCluster = args[0]
+		return logs.Run(context.Background())
+	}
+
+	return cmd
+}
+
+type clusterLogs struct {
+	*internal.Config
+
+	Container    string
+	Follow       bool
+	PostgresLogs bool
+	Role         string
+	Since        time.Duration
+	Tail         int64
+
+	PostgresCluster string
+}
+
+// logStream is one source of lines and the prefix of each of them.
+type logStream struct {
+	prefix string
+	copy   func(ctx context.Context, w io.Writer) error
+}
+
+// selector returns the labels of the Pods to read.
+func (config clusterLogs) selector() (string, error) {
+	switch config.Role {
+	case "primary":
+		return util.PrimaryInstanceLabels(config.PostgresCluster), nil
+	case "replica":
+		return util.ReplicaInstanceLabels(config.PostgresCluster), nil
+	case "":
+		if config.PostgresLogs {
+			return util.InstanceLabels(config.PostgresCluster), nil
+		}
+		return util.LabelCluster + "=" + config.PostgresCluster, nil
+	}
+	return "", fmt.Errorf("invalid role %q: types supported: primary,replica", config.Role)
+}
+
+func (config clusterLogs) Run(ctx context.Context) error {
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewForConfig(rest)
+	if err != nil {
+		return err
+	}
+	podExec, err := util.NewPodExecutor(rest)
+	if err != nil {
+		return err
+	}
+
+	return config.run(ctx, client, podExec)
+}
+
+func (config clusterLogs) run(ctx context.Context, client kubernetes.Interface,
+	podExec func(namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string) error,
+) error {
+	if config.PostgresLogs && config.Container != "" {
+		return fmt.Errorf("--container cannot be used with --postgres-logs")
+	}
+	if config.Since < 0 {
+		return fmt.Errorf("invalid since %s: must be positive", config.Since)
+	}
+
+	selector, err := config.selector()
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no Pods found for %s", config.PostgresCluster)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	var streams []logStream
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if config.PostgresLogs {
+			streams = append(streams, config.postgresLogStream(pod, podExec))
+			continue
+		}
+		for _, container := range containerNames(pod) {
+			if config.Container == "" || config.Container == container {
+				streams = append(streams, config.containerLogStream(client, pod, container))
+			}
+		}
+	}
+	if len(streams) == 0 {
+		return fmt.Errorf("no containers named %q found in the Pods of %s",
+			config.Container, config.PostgresCluster)
+	}
+
+	return mergeLogStreams(ctx, config.Out, config.ErrOut, streams)
+}
+
+// containerLogStream returns a stream of the logs of container in pod.
+func (config clusterLogs) containerLogStream(
+	client kubernetes.Interface, pod *corev1.Pod, container string,
+) logStream {
+	options := config.podLogOptions(container)
+
+	return logStream{
+		prefix: fmt.Sprintf("[pod/%s/%s] ", pod.Name, container),
+		copy: func(ctx context.Context, w io.Writer) error {
+			stream, err := client.CoreV1().Pods(pod.Namespace).
+				GetLogs(pod.Name, options).Stream(ctx)
+			if err != nil {
+				return err
+			}
+			defer stream.Close()
+
+			_, err = io.Copy(w, stream)
+			return err
+		},
+	}
+}
+
+// podLogOptions returns the options for reading the logs of container.
+func (config clusterLogs) podLogOptions(container string) *corev1.PodLogOptions {
+	options := &corev1.PodLogOptions{Container: container, Follow: config.Follow}
+	if config.Since > 0 {
+		seconds := int64(math.Ceil(config.Since.Seconds()))
+		options.SinceSeconds = &seconds
+	}
+	if config.Tail >= 0 {
+		lines := config.Tail
+		options.TailLines = &lines
+	}
+	return options
+}
+
+// postgresLogStream returns a stream of the Postgres log files of the
+// instance in pod, selected the same as a support export.
+func (config clusterLogs) postgresLogStream(pod *corev1.Pod,
+	podExec func(namespace, pod, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string) error,
+) logStream {
+	exec := Executor(func(stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return podExec(pod.Namespace, pod.Name, util.ContainerDatabase,
+			stdin, stdout, stderr, command...)
+	})
+
+	var window timeWindow
+	if config.Since > 0 {
+		window.since = time.Now().Add(-config.Since)
+	}
+
+	stream := logStream{prefix: fmt.Sprintf("[pod/%s/postgres] ", pod.Name)}
+	stream.copy = func(_ context.Context, w io.Writer) error {
+		stdout, stderr, err := exec.listPGLogFiles()
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, stderr)
+		}
+		files, err := parsePGLogFiles(stdout)
+		if err != nil {
+			return err
+		}
+
+		// The files are newest first; print them oldest first.
+		paths := selectPGLogFiles(files, 1, window)
+		if len(paths) == 0 {
+			return fmt.Errorf("no Postgres log files found")
+		}
+		for i, j := 0, len(paths)-1; i < j; i, j = i+1, j-1 {
+			paths[i], paths[j] = paths[j], paths[i]
+		}
+
+		stderr, err = exec.tailFiles(w, config.Tail, config.Follow, paths...)
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", path.Base(paths[len(paths)-1]), err, stderr)
+		}
+		return nil
+	}
+	return stream
+}
+
+// containerNames returns the names of the containers and init containers of
+// pod.
+func containerNames(pod *corev1.Pod) []string {
+	names := make([]string, 0, len(pod.Spec.Containers)+len(pod.Spec.InitContainers))
+	for _, container := range pod.Spec.Containers {
+		names = append(names, container.Name)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		names = append(names, container.Name)
+	}
+	return names
+}
+
+// mergeLogStreams copies every stream to out concurrently, one whole line at a
+// time. Streams that fail are reported on errOut; it is an error only when
+// every stream fails.
+func mergeLogStreams(ctx context.Context, out, errOut io.Writer, streams []logStream) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed int
+
+	for i := range streams {
+		wg.Add(1)
+		go func(stream logStream) {
+			defer wg.Done()
+
+			w := &prefixWriter{mu: &mu, out: out, prefix: []byte(stream.prefix)}
+			err := stream.copy(ctx, w)
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+
+			if err != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				failed++
+				fmt.Fprintf(errOut, "%sError: %v\n", stream.prefix, err)
+			}
+		}(streams[i])
+	}
+	wg.Wait()
+
+	if failed == len(streams) {
+		return fmt.Errorf("no logs could be read")
+	}
+	return nil
+}
+
+// prefixWriter writes each complete line to out after prefix. Lines of
+// writers that share mu are not interleaved.
+type prefixWriter struct {
+	mu      *sync.Mutex
+	out     io.Writer
+	prefix  []byte
+	partial []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if err := w.writeLine(w.partial[:i+1]); err != nil {
+			return 0, err
+		}
+		w.partial = w.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close writes the last line when it does not end with a newline.
+func (w *prefixWriter) Close() error {
+	if len(w.partial) == 0 {
+		return nil
+	}
+	line := append(w.partial, '\n')
+	w.partial = nil
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err := w.out.Write(append(append([]byte{}, w.prefix...), line...))
+	return err
+}
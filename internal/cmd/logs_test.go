@@ -0,0 +1,216 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+// newTestConfig returns a Config for the namespace that writes to stdout and
+// stderr.
+func newTestConfig(namespace string, stdout, stderr io.Writer) *internal.Config {
+	flags := genericclioptions.NewConfigFlags(false)
+	flags.Namespace = &namespace
+	return &internal.Config{
+		ConfigFlags: flags,
+		IOStreams:   genericclioptions.IOStreams{Out: stdout, ErrOut: stderr},
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var mu sync.Mutex
+	var out bytes.Buffer
+	w := &prefixWriter{mu: &mu, out: &out, prefix: []byte("[a] ")}
+
+	for _, s := range []string{"one\ntw", "o\n", "", "three\nfour"} {
+		n, err := w.Write([]byte(s))
+		assert.NilError(t, err)
+		assert.Equal(t, n, len(s))
+	}
+	assert.Equal(t, out.String(), "[a] one\n[a] two\n[a] three\n")
+
+	assert.NilError(t, w.Close())
+	assert.Equal(t, out.String(), "[a] one\n[a] two\n[a] three\n[a] four\n")
+}
+
+func TestMergeLogStreams(t *testing.T) {
+	lines := func(n int) func(context.Context, io.Writer) error {
+		return func(_ context.Context, w io.Writer) error {
+			for i := 0; i < n; i++ {
+				// Write each line in two parts so that other streams can interleave.
+				fmt.Fprintf(w, "line %d ", i)
+				fmt.Fprintf(w, "end\n")
+			}
+			return nil
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	assert.NilError(t, mergeLogStreams(context.Background(), &stdout, &stderr, []logStream{
+		{prefix: "[a] ", copy: lines(100)},
+		{prefix: "[b] ", copy: lines(100)},
+		{prefix: "[c] ", copy: func(context.Context, io.Writer) error { return errors.New("boom") }},
+	}))
+
+	output := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Equal(t, len(output), 200)
+	for _, line := range output {
+		assert.Assert(t, strings.HasPrefix(line, "[a] line ") || strings.HasPrefix(line, "[b] line "), line)
+		assert.Assert(t, strings.HasSuffix(line, " end"), line)
+	}
+	assert.Equal(t, stderr.String(), "[c] Error: boom\n")
+
+	err := mergeLogStreams(context.Background(), &stdout, &stderr, []logStream{
+		{prefix: "[c] ", copy: func(context.Context, io.Writer) error { return errors.New("boom") }},
+	})
+	assert.ErrorContains(t, err, "no logs could be read")
+}
+
+func TestClusterLogs(t *testing.T) {
+	instance := func(name, role string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "postgres-operator", Labels: map[string]string{
+				util.LabelCluster: "hippo", util.LabelData: util.DataPostgres, util.LabelRole: role,
+			}},
+			Spec: corev1.PodSpec{
+				Containers:     []corev1.Container{{Name: "database"}, {Name: "pgbackrest"}},
+				InitContainers: []corev1.Container{{Name: "postgres-startup"}},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		instance("hippo-instance1-abcd-0", util.RolePatroniLeader),
+		instance("hippo-instance1-efgh-0", util.RolePatroniReplica),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "hippo-repo-host-0", Namespace: "postgres-operator",
+				Labels: map[string]string{util.LabelCluster: "hippo"}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "pgbackrest"}}},
+		},
+	)
+
+	noExec := func(string, string, string, io.Reader, io.Writer, io.Writer, ...string) error {
+		return errors.New("unexpected exec")
+	}
+	run := func(t *testing.T, logs clusterLogs) ([]string, error) {
+		var stdout, stderr bytes.Buffer
+		logs.Config = newTestConfig("postgres-operator", &stdout, &stderr)
+		logs.PostgresCluster = "hippo"
+
+		err := logs.run(context.Background(), client, noExec)
+		assert.Equal(t, stderr.String(), "")
+
+		// Streams are concurrent, so sort them to compare.
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		sort.Strings(lines)
+		return lines, err
+	}
+
+	t.Run("Everything", func(t *testing.T) {
+		lines, err := run(t, clusterLogs{Tail: -1})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, lines, []string{
+			"[pod/hippo-instance1-abcd-0/database] fake logs",
+			"[pod/hippo-instance1-abcd-0/pgbackrest] fake logs",
+			"[pod/hippo-instance1-abcd-0/postgres-startup] fake logs",
+			"[pod/hippo-instance1-efgh-0/database] fake logs",
+			"[pod/hippo-instance1-efgh-0/pgbackrest] fake logs",
+			"[pod/hippo-instance1-efgh-0/postgres-startup] fake logs",
+			"[pod/hippo-repo-host-0/pgbackrest] fake logs",
+		})
+	})
+
+	t.Run("RoleAndContainer", func(t *testing.T) {
+		lines, err := run(t, clusterLogs{Role: "replica", Container: "database", Tail: -1})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, lines, []string{"[pod/hippo-instance1-efgh-0/database] fake logs"})
+
+		_, err = run(t, clusterLogs{Role: "primary", Container: "exporter", Tail: -1})
+		assert.ErrorContains(t, err, `no containers named "exporter"`)
+
+		_, err = run(t, clusterLogs{Role: "leader"})
+		assert.ErrorContains(t, err, `invalid role "leader": types supported: primary,replica`)
+	})
+
+	t.Run("Options", func(t *testing.T) {
+		seconds, lines := int64(90), int64(10)
+		logs := clusterLogs{Follow: true, Since: 90 * time.Second, Tail: 10}
+		assert.DeepEqual(t, logs.podLogOptions("database"), &corev1.PodLogOptions{
+			Container: "database", Follow: true, SinceSeconds: &seconds, TailLines: &lines,
+		})
+
+		logs = clusterLogs{Tail: -1}
+		assert.DeepEqual(t, logs.podLogOptions("pgbackrest"), &corev1.PodLogOptions{
+			Container: "pgbackrest",
+		})
+
+		_, err := run(t, clusterLogs{PostgresLogs: true, Container: "database"})
+		assert.ErrorContains(t, err, "--container cannot be used with --postgres-logs")
+	})
+}
+
+func TestClusterPostgresLogs(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "hippo-instance1-abcd-0", Namespace: "postgres-operator",
+	}}
+
+	var commands [][]string
+	exec := func(namespace, name, container string,
+		stdin io.Reader, stdout, stderr io.Writer, command ...string,
+	) error {
+		assert.Equal(t, name, pod.Name)
+		assert.Equal(t, container, util.ContainerDatabase)
+		commands = append(commands, command)
+
+		if strings.Contains(command[3], "stat -c") {
+			now := time.Now().Unix()
+			fmt.Fprintf(stdout, "%d pgdata/pg14/log/postgresql-Mon.log\n", now-7200)
+			fmt.Fprintf(stdout, "%d pgdata/pg14/log/postgresql-Tue.log\n", now)
+			return nil
+		}
+		_, err := stdout.Write([]byte("LOG:  checkpoint complete\n"))
+		return err
+	}
+
+	var out bytes.Buffer
+	logs := clusterLogs{Tail: 5, Follow: true}
+	assert.NilError(t, logs.postgresLogStream(pod, exec).copy(context.Background(), &out))
+	assert.Equal(t, out.String(), "LOG:  checkpoint complete\n")
+	assert.DeepEqual(t, commands[1][4:], []string{"-", "5", "true", "pgdata/pg14/log/postgresql-Tue.log"})
+
+	// Files written since then are read oldest first.
+	commands = nil
+	logs = clusterLogs{Tail: -1, Since: 3 * time.Hour}
+	assert.NilError(t, logs.postgresLogStream(pod, exec).copy(context.Background(), &out))
+	assert.DeepEqual(t, commands[1][4:], []string{"-", "all", "false",
+		"pgdata/pg14/log/postgresql-Mon.log", "pgdata/pg14/log/postgresql-Tue.log"})
+}
@@ -0,0 +1,109 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// manifestPath is where the manifest is written at the root of the archive.
+const manifestPath = "manifest.json"
+
+// Sources of the files in a support export archive
+const (
+	// sourceAPI files hold objects read from the Kubernetes API.
+	sourceAPI = "api"
+
+	// sourceExec files hold the output of commands run in containers.
+	sourceExec = "exec"
+
+	// sourceLogs files hold container logs read from the Kubernetes API.
+	sourceLogs = "logs"
+
+	// sourceExport files describe the export itself, such as its summary.
+	sourceExport = "export"
+)
+
+// manifestEntry describes one file of the archive.
+type manifestEntry struct {
+	Path      string    `json:"path"`
+	Source    string    `json:"source"`
+	Collected time.Time `json:"collected"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Truncated string    `json:"truncated,omitempty"`
+}
+
+// archiveManifest lists every file of the archive, in the order they were
+// written. It is used only by the goroutine that writes the archive.
+type archiveManifest struct {
+	Created time.Time `json:"created"`
+
+	// Clusters are the names of the PostgresClusters in the archive, each in
+	// a directory of the same name. Shared is the directory of the files
+	// common to all of them.
+	Clusters []string `json:"clusters"`
+	Shared   string   `json:"shared"`
+
+	Entries []manifestEntry `json:"entries"`
+}
+
+// write copies size bytes from r to archive like writeArchiveReader and lists
+// the file in the manifest, by its sanitized name, along with the checksum of
+// what was written. A nil manifest only writes the file.
+func (m *archiveManifest) write(archive archiveWriter, r io.Reader, size int64, name string,
+	records map[string]string, source string, collected time.Time, cmd *cobra.Command,
+) error {
+	name = sanitizeEntryName(name)
+	if m == nil {
+		return writeArchiveReader(archive, r, size, name, records, cmd)
+	}
+
+	sum := sha256.New()
+	if err := writeArchiveReader(archive, io.TeeReader(r, sum), size, name, records, cmd); err != nil {
+		return err
+	}
+
+	m.Entries = append(m.Entries, manifestEntry{
+		Path:      name,
+		Source:    source,
+		Collected: collected.UTC(),
+		Size:      size,
+		SHA256:    hex.EncodeToString(sum.Sum(nil)),
+		Truncated: records["PGO.truncated"],
+	})
+	return nil
+}
+
+// writeManifest writes the manifest to the root of the archive. It must be
+// the last file written so that it lists every other file.
+func writeManifest(archive archiveWriter, m *archiveManifest, cmd *cobra.Command) error {
+	if m.Entries == nil {
+		m.Entries = []manifestEntry{}
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeArchiveReader(archive, bytes.NewReader(b), int64(len(b)), manifestPath, nil, cmd)
+}
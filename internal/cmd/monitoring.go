@@ -0,0 +1,392 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/apis/postgres-operator.crunchydata.com/v1beta1"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+// exporterPath is where the exporter is in the spec of a PostgresCluster.
+var exporterPath = []string{"spec", "monitoring", "pgmonitor", "exporter"}
+
+// newMonitoringCommand returns the monitoring subcommand of the PGO plugin.
+// Its subcommands manage the exporter sidecar of a PostgresCluster.
+func newMonitoringCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "monitoring",
+		Short: "Manage the metrics exporter of a PostgresCluster",
+		Long: `Manage the pgMonitor exporter in "spec.monitoring.pgmonitor.exporter" of a
+PostgresCluster. The exporter runs next to Postgres in every instance Pod.`,
+	}
+
+	cmd.AddCommand(
+		newMonitoringCheckCommand(config),
+		newMonitoringDisableCommand(config),
+		newMonitoringEnableCommand(config),
+	)
+
+	// No arguments for 'monitoring', but there are arguments for the subcommands.
+	cmd.Args = cobra.NoArgs
+
+	return cmd
+}
+
+func newMonitoringEnableCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "enable CLUSTER_NAME",
+		Short: "Add the metrics exporter to a PostgresCluster",
+		Long: `Add the metrics exporter to every instance of a PostgresCluster. PGO rolls out
+the change one instance at a time. Run it again to change the image or
+queries; flags that are not given are removed.
+
+The queries ConfigMap replaces the queries of pgMonitor, so it must have a
+"queries.yml" key or files that the exporter is configured to read.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]
+    configmaps                                          [get]
+
+###### Note: The configmaps permission is only required with --queries-configmap.`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Add the exporter to the 'hippo' postgrescluster
+pgo monitoring enable hippo
+
+# Add the exporter with a custom image and the queries in a ConfigMap
+pgo monitoring enable hippo --image=registry.example.com/exporter:latest --queries-configmap=hippo-queries
+`)
+
+	monitoring := clusterMonitoring{Config: config}
+
+	cmd.Flags().StringVar(&monitoring.Image, "image", "",
+		"image of the exporter rather than the default of PGO")
+	cmd.Flags().StringVar(&monitoring.QueriesConfigMap, "queries-configmap", "",
+		"name of a ConfigMap with custom queries for the exporter")
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		monitoring.PostgresCluster = args[0]
+		return monitoring.Enable(context.Background())
+	}
+
+	return cmd
+}
+
+func newMonitoringDisableCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disable CLUSTER_NAME",
+		Short: "Remove the metrics exporter from a PostgresCluster",
+		Long: `Remove the metrics exporter from every instance of a PostgresCluster. Only an
+exporter added by this plugin can be removed by it.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Remove the exporter from the 'hippo' postgrescluster
+pgo monitoring disable hippo
+`)
+
+	monitoring := clusterMonitoring{Config: config}
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		monitoring.PostgresCluster = args[0]
+		return monitoring.apply(context.Background(), "disabled", monitoring.disableIntent)
+	}
+
+	return cmd
+}
+
+func newMonitoringCheckCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check CLUSTER_NAME",
+		Short: "Check the metrics exporter of every instance",
+		Long: `Check that the exporter container is ready in every instance Pod of a
+PostgresCluster and that it serves pgMonitor metrics. The command fails when
+any instance does not.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get]
+    pods                                                [list]
+    pods/exec                                           [create]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Check the exporter of every instance of the 'hippo' postgrescluster
+pgo monitoring check hippo
+`)
+
+	monitoring := clusterMonitoring{Config: config}
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		monitoring.PostgresCluster = args[0]
+		return monitoring.Check(context.Background())
+	}
+
+	return cmd
+}
+
+type clusterMonitoring struct {
+	*internal.Config
+
+	Image            string
+	QueriesConfigMap string
+
+	PostgresCluster string
+}
+
+func (config clusterMonitoring) Enable(ctx context.Context) error {
+	if config.QueriesConfigMap != "" {
+		rest, err := config.ToRESTConfig()
+		if err != nil {
+			return err
+		}
+		client, err := corev1client.NewForConfig(rest)
+		if err != nil {
+			return err
+		}
+		namespace, err := config.Namespace()
+		if err != nil {
+			return err
+		}
+
+		// The exporter does not start without its queries.
+		if _, err := client.ConfigMaps(namespace).Get(ctx,
+			config.QueriesConfigMap, metav1.GetOptions{}); err != nil {
+			return err
+		}
+	}
+
+	return config.apply(ctx, "enabled", config.enableIntent)
+}
+
+// apply changes the PostgresCluster with modify and prints action when the
+// change is accepted.
+func (config clusterMonitoring) apply(
+	ctx context.Context, action string,
+	modify func(cluster, intent *unstructured.Unstructured) error,
+) error {
+	return applyClusterIntent(ctx, config.Config, config.PostgresCluster,
+		"monitoring "+action, modify)
+}
+
+// enableIntent sets the exporter with its image and queries in intent.
+func (config clusterMonitoring) enableIntent(_, intent *unstructured.Unstructured) error {
+	exporter := map[string]interface{}{}
+	if config.Image != "" {
+		exporter["image"] = config.Image
+	}
+	if config.QueriesConfigMap != "" {
+		exporter["configuration"] = []interface{}{
+			map[string]interface{}{
+				"configMap": map[string]interface{}{"name": config.QueriesConfigMap},
+			},
+		}
+	}
+	return unstructured.SetNestedMap(intent.Object, exporter, exporterPath...)
+}
+
+// disableIntent removes the exporter from intent. An exporter that was not
+// added through this field manager cannot be removed by it.
+func (config clusterMonitoring) disableIntent(cluster, intent *unstructured.Unstructured) error {
+	if _, found, _ := unstructured.NestedMap(cluster.Object, exporterPath...); !found {
+		return fmt.Errorf("monitoring is not enabled in %s", config.PostgresCluster)
+	}
+	if _, found, _ := unstructured.NestedMap(intent.Object, exporterPath...); !found {
+		return errors.New("monitoring is managed by another field manager; remove it with the tool that added it")
+	}
+
+	unstructured.RemoveNestedField(intent.Object, exporterPath...)
+	internal.RemoveEmptySections(intent, exporterPath[:len(exporterPath)-1]...)
+	return nil
+}
+
+func (config clusterMonitoring) Check(ctx context.Context) error {
+	_, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	core, err := corev1client.NewForConfig(rest)
+	if err != nil {
+		return err
+	}
+	podExec, err := util.NewPodExecutor(rest)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	cluster, err := client.Namespace(namespace).Get(ctx,
+		config.PostgresCluster, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if _, found, _ := unstructured.NestedMap(cluster.Object, exporterPath...); !found {
+		return fmt.Errorf("monitoring is not enabled in %s; see 'pgo monitoring enable'",
+			config.PostgresCluster)
+	}
+
+	pods, err := core.Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: util.InstanceLabels(config.PostgresCluster),
+	})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no instance Pods found for %s", config.PostgresCluster)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	scheme := exporterScheme(cluster)
+	checks := make([]exporterCheck, len(pods.Items))
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		checks[i] = checkExporter(pod, scheme, func(
+			stdin io.Reader, stdout, stderr io.Writer, command ...string,
+		) error {
+			return podExec(pod.Namespace, pod.Name, util.ContainerExporter,
+				stdin, stdout, stderr, command...)
+		})
+	}
+
+	return writeExporterChecks(config.Out, checks)
+}
+
+// exporterCheck is the state of the exporter in one instance Pod.
+type exporterCheck struct {
+	Pod       string
+	Container string
+	Metrics   string
+	OK        bool
+}
+
+// checkExporter returns the state of the exporter container in pod and
+// whether it serves pgMonitor metrics through exec.
+func checkExporter(pod *corev1.Pod, scheme string, exec Executor) exporterCheck {
+	check := exporterCheck{Pod: pod.Name, Container: "missing", Metrics: "<none>"}
+
+	port, ok := exporterPort(pod)
+	if !ok {
+		// PGO has not rolled out the exporter to this instance yet.
+		return check
+	}
+
+	check.Container = "not ready"
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != util.ContainerExporter {
+			continue
+		}
+		switch {
+		case status.Ready:
+			check.Container = "ready"
+		case status.State.Waiting != nil && status.State.Waiting.Reason != "":
+			check.Container = status.State.Waiting.Reason
+		}
+	}
+	if check.Container != "ready" {
+		return check
+	}
+
+	stdout, stderr, err := exec.httpGet(scheme, port, "/metrics")
+	if err != nil {
+		check.Metrics = fmt.Sprintf("%v: %s", err, strings.TrimSpace(stderr))
+		return check
+	}
+
+	// The pgMonitor metrics start with "ccp_". The exporter serves its own
+	// metrics even when it cannot connect to Postgres.
+	count := 0
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.HasPrefix(line, "ccp_") {
+			count++
+		}
+	}
+	if count == 0 {
+		check.Metrics = fmt.Sprintf("no ccp_ metrics; see 'pgo logs %s --container=exporter'",
+			pod.Labels[util.LabelCluster])
+		return check
+	}
+
+	check.Metrics = fmt.Sprintf("%d ccp_ metrics", count)
+	check.OK = true
+	return check
+}
+
+// writeExporterChecks prints checks as a table. It returns an error when any
+// check failed.
+func writeExporterChecks(out io.Writer, checks []exporterCheck) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "POD\tEXPORTER\tMETRICS")
+
+	failed := 0
+	for _, check := range checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Pod, check.Container, check.Metrics)
+		if !check.OK {
+			failed++
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("the exporter is not serving metrics in %d of %d instances",
+			failed, len(checks))
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crunchydata/postgres-operator-client/internal/testing/cmp"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+func TestClusterMonitoringModifyIntent(t *testing.T) {
+	unmarshal := func(t *testing.T, doc string) *unstructured.Unstructured {
+		t.Helper()
+		object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		assert.NilError(t, yaml.Unmarshal([]byte(strings.TrimSpace(doc)), &object.Object))
+		return object
+	}
+
+	t.Run("Enable", func(t *testing.T) {
+		intent := unmarshal(t, `{}`)
+		assert.NilError(t, clusterMonitoring{}.enableIntent(nil, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `
+spec:
+  monitoring:
+    pgmonitor:
+      exporter: {}
+		`))
+
+		monitoring := clusterMonitoring{Image: "example.com/exporter:1", QueriesConfigMap: "hippo-queries"}
+		assert.NilError(t, monitoring.enableIntent(nil, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `
+spec:
+  monitoring:
+    pgmonitor:
+      exporter:
+        configuration:
+        - configMap:
+            name: hippo-queries
+        image: example.com/exporter:1
+		`))
+
+		// Flags that are not given are removed.
+		assert.NilError(t, clusterMonitoring{}.enableIntent(nil, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `
+spec:
+  monitoring:
+    pgmonitor:
+      exporter: {}
+		`))
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		cluster := unmarshal(t, `{ spec: { monitoring: { pgmonitor: { exporter: { image: x } } } } }`)
+		intent := unmarshal(t, `{ spec: { postgresVersion: 14, monitoring: { pgmonitor: { exporter: {} } } } }`)
+
+		monitoring := clusterMonitoring{PostgresCluster: "hippo"}
+		assert.NilError(t, monitoring.disableIntent(cluster, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `
+spec:
+  postgresVersion: 14
+		`))
+
+		assert.ErrorContains(t, monitoring.disableIntent(cluster, intent),
+			"monitoring is managed by another field manager")
+		assert.ErrorContains(t, monitoring.disableIntent(intent, intent),
+			"monitoring is not enabled in hippo")
+	})
+}
+
+func TestCheckExporter(t *testing.T) {
+	instance := func(ready bool, waiting string, ports ...corev1.ContainerPort) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "hippo-instance1-abcd-0", Labels: map[string]string{util.LabelCluster: "hippo"},
+			},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{
+				{Name: util.ContainerDatabase},
+				{Name: util.ContainerExporter, Ports: ports},
+			}},
+		}
+		status := corev1.ContainerStatus{Name: util.ContainerExporter, Ready: ready}
+		if waiting != "" {
+			status.State.Waiting = &corev1.ContainerStateWaiting{Reason: waiting}
+		}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{status}
+		return pod
+	}
+
+	metrics := func(body string, err error) Executor {
+		return func(_ io.Reader, stdout, stderr io.Writer, command ...string) error {
+			assert.Assert(t, strings.Contains(command[3], "https://localhost:9999/metrics"))
+			_, _ = io.WriteString(stdout, body)
+			_, _ = io.WriteString(stderr, "connection refused")
+			return err
+		}
+	}
+	port := corev1.ContainerPort{Name: "exporter", ContainerPort: 9999}
+
+	check := checkExporter(instance(true, "", port), "https", metrics(
+		"# HELP ccp_up\nccp_up 1\nccp_database_size_bytes{dbname=\"hippo\"} 8000\ngo_goroutines 9\n", nil))
+	assert.DeepEqual(t, check, exporterCheck{
+		Pod: "hippo-instance1-abcd-0", Container: "ready", Metrics: "2 ccp_ metrics", OK: true,
+	})
+
+	check = checkExporter(instance(true, "", port), "https", metrics("go_goroutines 9\n", nil))
+	assert.Equal(t, check.Metrics, "no ccp_ metrics; see 'pgo logs hippo --container=exporter'")
+	assert.Assert(t, !check.OK)
+
+	check = checkExporter(instance(true, "", port), "https", metrics("", errors.New("exit 7")))
+	assert.Equal(t, check.Metrics, "exit 7: connection refused")
+	assert.Assert(t, !check.OK)
+
+	noExec := metrics("", errors.New("unexpected exec"))
+	check = checkExporter(instance(false, "CrashLoopBackOff"), "http", noExec)
+	assert.Equal(t, check.Container, "CrashLoopBackOff")
+	assert.Equal(t, check.Metrics, "<none>")
+
+	check = checkExporter(instance(false, ""), "http", noExec)
+	assert.Equal(t, check.Container, "not ready")
+
+	pod := instance(true, "")
+	pod.Spec.Containers = pod.Spec.Containers[:1]
+	check = checkExporter(pod, "http", noExec)
+	assert.Equal(t, check.Container, "missing")
+}
+
+func TestWriteExporterChecks(t *testing.T) {
+	var out bytes.Buffer
+	assert.NilError(t, writeExporterChecks(&out, []exporterCheck{
+		{Pod: "hippo-instance1-abcd-0", Container: "ready", Metrics: "80 ccp_ metrics", OK: true},
+	}))
+	assert.Equal(t, out.String(), strings.TrimLeft(`
+POD                     EXPORTER  METRICS
+hippo-instance1-abcd-0  ready     80 ccp_ metrics
+`, "\n"))
+
+	out.Reset()
+	assert.ErrorContains(t, writeExporterChecks(&out, []exporterCheck{
+		{Pod: "hippo-instance1-abcd-0", Container: "ready", Metrics: "80 ccp_ metrics", OK: true},
+		{Pod: "hippo-instance1-efgh-0", Container: "missing", Metrics: "<none>"},
+	}), "the exporter is not serving metrics in 1 of 2 instances")
+	assert.Assert(t, strings.Contains(out.String(), "hippo-instance1-efgh-0  missing   <none>"), out.String())
+}
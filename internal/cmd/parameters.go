@@ -0,0 +1,579 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/apis/postgres-operator.crunchydata.com/v1beta1"
+)
+
+// parametersPath is where the Postgres parameters of a PostgresCluster are
+// in its spec. Patroni applies them to every instance.
+var parametersPath = []string{
+	"spec", "patroni", "dynamicConfiguration", "postgresql", "parameters",
+}
+
+// newConfigCommand returns the config subcommand of the PGO plugin. Its
+// subcommands view and change the Postgres parameters of a PostgresCluster.
+func newConfigCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "View and change Postgres parameters of a PostgresCluster",
+		Long: `View and change the Postgres parameters in
+"spec.patroni.dynamicConfiguration.postgresql.parameters" of a PostgresCluster.
+Patroni applies them to every instance.`,
+	}
+
+	cmd.AddCommand(
+		newConfigGetCommand(config),
+		newConfigSetCommand(config),
+		newConfigUnsetCommand(config),
+	)
+
+	// No arguments for 'config', but there are arguments for the subcommands.
+	cmd.Args = cobra.NoArgs
+
+	return cmd
+}
+
+func newConfigGetCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get CLUSTER_NAME [PARAMETER...]",
+		Short: "Show Postgres parameters and pending restarts",
+		Long: `Show the Postgres parameters in the spec of a PostgresCluster along with their
+current values on the primary instance. Other parameters can be named, too.
+
+Every instance is listed with whether Patroni reports that it has a pending
+restart, that is, a changed parameter that takes effect only after a restart.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get]
+    pods                                                [list]
+    pods/exec                                           [create]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Show the parameters in the spec of the 'hippo' postgrescluster
+pgo config get hippo
+
+# Show the 'work_mem' and 'max_connections' parameters
+pgo config get hippo work_mem max_connections
+`)
+
+	parameters := postgresParameters{Config: config}
+
+	// The PostgresCluster name followed by any parameter names.
+	cmd.Args = cobra.MinimumNArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		parameters.PostgresCluster = args[0]
+		for _, name := range args[1:] {
+			parameters.Names = append(parameters.Names, strings.ToLower(name))
+		}
+		return parameters.Get(context.Background())
+	}
+
+	return cmd
+}
+
+func newConfigSetCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set CLUSTER_NAME PARAMETER=VALUE...",
+		Short: "Change Postgres parameters",
+		Long: `Change Postgres parameters in the spec of a PostgresCluster. Each value is
+checked against "pg_settings" on the primary instance: its type, unit and
+limits, and whether it can be changed at all. Parameters with a dot, such as
+those of extensions, are not checked.
+
+Parameters with the "postmaster" context take effect only after a restart.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]
+    pods                                                [list]
+    pods/exec                                           [create]
+
+###### Note: The pods permissions are not required with --validate=false.`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Change the 'work_mem' and 'shared_buffers' parameters of the 'hippo' postgrescluster
+pgo config set hippo work_mem=16MB shared_buffers=512MB
+
+# Change a parameter without checking it against the primary instance
+pgo config set hippo log_min_duration_statement=250ms --validate=false
+`)
+
+	parameters := postgresParameters{Config: config}
+
+	cmd.Flags().BoolVar(&parameters.Validate, "validate", true,
+		"check the values against pg_settings on the primary instance")
+
+	// The PostgresCluster name followed by at least one parameter.
+	cmd.Args = cobra.MinimumNArgs(2)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		parameters.PostgresCluster = args[0]
+		parameters.Values = map[string]string{}
+		for _, arg := range args[1:] {
+			name, value, found := strings.Cut(arg, "=")
+			if !found || strings.TrimSpace(name) == "" {
+				return fmt.Errorf("invalid parameter %q: expected PARAMETER=VALUE", arg)
+			}
+			name = strings.ToLower(strings.TrimSpace(name))
+			parameters.Names = append(parameters.Names, name)
+			parameters.Values[name] = value
+		}
+		return parameters.Set(context.Background())
+	}
+
+	return cmd
+}
+
+func newConfigUnsetCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unset CLUSTER_NAME PARAMETER...",
+		Short: "Remove Postgres parameters",
+		Long: `Remove Postgres parameters from the spec of a PostgresCluster so that their
+defaults are used. Only parameters set by this plugin can be removed by it.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Remove the 'work_mem' parameter from the 'hippo' postgrescluster
+pgo config unset hippo work_mem
+`)
+
+	parameters := postgresParameters{Config: config}
+
+	// The PostgresCluster name followed by at least one parameter name.
+	cmd.Args = cobra.MinimumNArgs(2)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		parameters.PostgresCluster = args[0]
+		for _, name := range args[1:] {
+			parameters.Names = append(parameters.Names, strings.ToLower(name))
+		}
+		return parameters.Unset(context.Background())
+	}
+
+	return cmd
+}
+
+type postgresParameters struct {
+	*internal.Config
+
+	// Names are the parameters in the order given; Values are their new
+	// values when setting them.
+	Names    []string
+	Values   map[string]string
+	Validate bool
+
+	PostgresCluster string
+}
+
+func (config postgresParameters) Get(ctx context.Context) error {
+	_, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	cluster, err := client.Namespace(namespace).Get(ctx,
+		config.PostgresCluster, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	exec, _, err := primaryExecutor(ctx, config.Config, namespace, config.PostgresCluster)
+	if err != nil {
+		return err
+	}
+
+	settings, err := fetchPGSettings(exec)
+	if err != nil {
+		return err
+	}
+
+	stdout, stderr, err := exec.patronictl("list --format json")
+	if err != nil {
+		return fmt.Errorf("patronictl list: %w: %s", err, stderr)
+	}
+	members, err := parsePatroniMembers(stdout)
+	if err != nil {
+		return err
+	}
+
+	spec, _, _ := unstructured.NestedMap(cluster.Object, parametersPath...)
+	return writePostgresParameters(config.Out, config.Names, spec, settings, members)
+}
+
+func (config postgresParameters) Set(ctx context.Context) error {
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	var settings map[string]pgSetting
+	if config.Validate {
+		exec, _, err := primaryExecutor(ctx, config.Config, namespace, config.PostgresCluster)
+		if err != nil {
+			return err
+		}
+
+		settings, err = fetchPGSettings(exec)
+		if err != nil {
+			return err
+		}
+
+		var errs []string
+		for _, name := range config.Names {
+			if err := validateParameter(settings, name, config.Values[name]); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if len(errs) > 0 {
+			return errors.New(strings.Join(errs, "; "))
+		}
+	}
+
+	if err := config.apply(ctx, "updated", config.setIntent); err != nil {
+		return err
+	}
+
+	for _, name := range config.Names {
+		if setting, ok := settings[name]; ok && setting.Context == "postmaster" {
+			fmt.Fprintf(config.Out, "%s takes effect after a restart; see 'pgo config get %s'\n",
+				name, config.PostgresCluster)
+		}
+	}
+	return nil
+}
+
+func (config postgresParameters) Unset(ctx context.Context) error {
+	return config.apply(ctx, "removed", config.unsetIntent)
+}
+
+// apply changes the PostgresCluster with modify and prints action when the
+// change is accepted.
+func (config postgresParameters) apply(
+	ctx context.Context, action string,
+	modify func(cluster, intent *unstructured.Unstructured) error,
+) error {
+	return applyClusterIntent(ctx, config.Config, config.PostgresCluster,
+		"parameters "+action+": "+strings.Join(config.Names, ", "), modify)
+}
+
+// setIntent sets the parameters in intent.
+func (config postgresParameters) setIntent(_, intent *unstructured.Unstructured) error {
+	parameters, _, _ := unstructured.NestedMap(intent.Object, parametersPath...)
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	for _, name := range config.Names {
+		parameters[name] = config.Values[name]
+	}
+	return unstructured.SetNestedMap(intent.Object, parameters, parametersPath...)
+}
+
+// unsetIntent removes the parameters from intent. Parameters that were not set
+// through this field manager cannot be removed by it.
+func (config postgresParameters) unsetIntent(cluster, intent *unstructured.Unstructured) error {
+	current, _, _ := unstructured.NestedMap(cluster.Object, parametersPath...)
+	parameters, _, _ := unstructured.NestedMap(intent.Object, parametersPath...)
+
+	for _, name := range config.Names {
+		if _, ok := current[name]; !ok {
+			return fmt.Errorf("parameter %q is not set in %s", name, config.PostgresCluster)
+		}
+		if _, ok := parameters[name]; !ok {
+			return fmt.Errorf("parameter %q is managed by another field manager; remove it with the tool that set it", name)
+		}
+		delete(parameters, name)
+	}
+
+	if err := unstructured.SetNestedMap(intent.Object, parameters, parametersPath...); err != nil {
+		return err
+	}
+	internal.RemoveEmptySections(intent, parametersPath...)
+	return nil
+}
+
+// pgSetting is one row of "pg_settings" as returned by
+// [Executor.pgSettingDefinitions].
+type pgSetting struct {
+	Name           string   `json:"name"`
+	Setting        string   `json:"setting"`
+	Current        string   `json:"current"`
+	Unit           string   `json:"unit"`
+	Vartype        string   `json:"vartype"`
+	Context        string   `json:"context"`
+	MinVal         *string  `json:"min_val"`
+	MaxVal         *string  `json:"max_val"`
+	Enumvals       []string `json:"enumvals"`
+	PendingRestart bool     `json:"pending_restart"`
+}
+
+// fetchPGSettings returns every Postgres setting by name.
+func fetchPGSettings(exec Executor) (map[string]pgSetting, error) {
+	stdout, stderr, err := exec.pgSettingDefinitions()
+	if err != nil {
+		return nil, fmt.Errorf("pg_settings: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return parsePGSettings(stdout)
+}
+
+// parsePGSettings parses the output of [Executor.pgSettingDefinitions].
+func parsePGSettings(stdout string) (map[string]pgSetting, error) {
+	var list []pgSetting
+	if err := json.Unmarshal([]byte(stdout), &list); err != nil {
+		return nil, fmt.Errorf("unexpected pg_settings output: %w", err)
+	}
+
+	settings := make(map[string]pgSetting, len(list))
+	for _, setting := range list {
+		settings[setting.Name] = setting
+	}
+	return settings, nil
+}
+
+// Units of Postgres memory and time parameters in the order of the docs.
+// - https://www.postgresql.org/docs/current/config-setting.html
+var (
+	memoryUnits = []settingUnit{
+		{"B", 1}, {"kB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+	}
+	timeUnits = []settingUnit{
+		{"us", 1}, {"ms", 1e3}, {"s", 1e6}, {"min", 60e6}, {"h", 3600e6}, {"d", 86400e6},
+	}
+)
+
+// settingUnit is a unit of a Postgres parameter and its size in the smallest
+// unit of its kind.
+type settingUnit struct {
+	name string
+	size float64
+}
+
+// settingNumber matches a number followed by an optional unit.
+var settingNumber = regexp.MustCompile(`^\s*([-+]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][-+]?[0-9]+)?)\s*([a-zA-Z]*)\s*$`)
+
+// parseSettingUnit returns the size of unit, such as "8kB", and the units of
+// its kind. It returns nil units when unit is not memory or time.
+func parseSettingUnit(unit string) (float64, []settingUnit) {
+	digits := strings.TrimRightFunc(unit, func(r rune) bool { return r < '0' || r > '9' })
+	multiple := 1.0
+	if digits != "" {
+		multiple, _ = strconv.ParseFloat(digits, 64)
+	}
+
+	for _, units := range [][]settingUnit{memoryUnits, timeUnits} {
+		for _, u := range units {
+			if u.name == unit[len(digits):] {
+				return multiple * u.size, units
+			}
+		}
+	}
+	return 1, nil
+}
+
+// parseSettingNumber returns value in the unit of a Postgres parameter.
+func parseSettingNumber(value, unit string) (float64, error) {
+	match := settingNumber.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid value %q: expected a number", value)
+	}
+
+	n, err := strconv.ParseFloat(match[1], 64)
+	if err != nil || match[2] == "" {
+		return n, err
+	}
+
+	size, units := parseSettingUnit(unit)
+	var names []string
+	for _, u := range units {
+		if u.name == match[2] {
+			return n * u.size / size, nil
+		}
+		names = append(names, u.name)
+	}
+	if len(names) == 0 {
+		return 0, fmt.Errorf("invalid value %q: expected a number without a unit", value)
+	}
+	return 0, fmt.Errorf("invalid unit %q: units supported: %s", match[2], strings.Join(names, ","))
+}
+
+// validateParameter returns an error when value is not valid for the
+// parameter called name in settings. Parameters with a dot are not checked.
+func validateParameter(settings map[string]pgSetting, name, value string) error {
+	setting, ok := settings[name]
+	if !ok {
+		if strings.Contains(name, ".") {
+			return nil
+		}
+		return fmt.Errorf("%s: unrecognized parameter", name)
+	}
+
+	if setting.Context == "internal" {
+		return fmt.Errorf("%s: cannot be changed", name)
+	}
+
+	switch setting.Vartype {
+	case "bool":
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "on", "off", "true", "false", "yes", "no", "1", "0", "t", "f", "y", "n":
+			return nil
+		}
+		return fmt.Errorf("%s: invalid value %q: expected on or off", name, value)
+
+	case "enum":
+		for _, e := range setting.Enumvals {
+			if strings.EqualFold(e, strings.TrimSpace(value)) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: invalid value %q: values supported: %s",
+			name, value, strings.Join(setting.Enumvals, ","))
+
+	case "integer", "real":
+		n, err := parseSettingNumber(value, setting.Unit)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		unit := ""
+		if setting.Unit != "" {
+			unit = " (" + setting.Unit + ")"
+		}
+		if setting.MinVal != nil {
+			if min, err := strconv.ParseFloat(*setting.MinVal, 64); err == nil && n < min {
+				return fmt.Errorf("%s: %q is less than the minimum %s%s", name, value, *setting.MinVal, unit)
+			}
+		}
+		if setting.MaxVal != nil {
+			if max, err := strconv.ParseFloat(*setting.MaxVal, 64); err == nil && n > max {
+				return fmt.Errorf("%s: %q is more than the maximum %s%s", name, value, *setting.MaxVal, unit)
+			}
+		}
+	}
+
+	return nil
+}
+
+// patroniMember is one member in the output of "patronictl list".
+type patroniMember struct {
+	Cluster        string      `json:"Cluster"`
+	Member         string      `json:"Member"`
+	Role           string      `json:"Role"`
+	State          string      `json:"State"`
+	PendingRestart interface{} `json:"Pending restart"`
+}
+
+// pending returns whether Patroni reports a pending restart of member.
+func (member patroniMember) pending() bool {
+	return member.PendingRestart != nil && member.PendingRestart != "" && member.PendingRestart != false
+}
+
+// leader returns whether member is the leader, including the leader of a
+// standby cluster.
+func (member patroniMember) leader() bool {
+	return strings.HasSuffix(member.Role, "Leader")
+}
+
+// running returns whether Postgres of member is up. Replicas of newer
+// Patroni report "streaming" rather than "running".
+func (member patroniMember) running() bool {
+	switch member.State {
+	case "running", "streaming", "in archive recovery":
+		return true
+	}
+	return false
+}
+
+// parsePatroniMembers parses the output of "patronictl list --format json".
+func parsePatroniMembers(stdout string) ([]patroniMember, error) {
+	var members []patroniMember
+	if err := json.Unmarshal([]byte(stdout), &members); err != nil {
+		return nil, fmt.Errorf("unexpected patronictl output: %w", err)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Member < members[j].Member })
+	return members, nil
+}
+
+// writePostgresParameters prints the parameters called names, or those in
+// spec, then the pending restarts of members.
+func writePostgresParameters(out io.Writer, names []string,
+	spec map[string]interface{}, settings map[string]pgSetting, members []patroniMember,
+) error {
+	if len(names) == 0 {
+		for name := range spec {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	orNone := func(s string) string {
+		if s == "" {
+			return "<none>"
+		}
+		return s
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PARAMETER\tSPEC\tCURRENT\tCONTEXT\tPENDING RESTART")
+	for _, name := range names {
+		var value string
+		if v, ok := spec[name]; ok {
+			value = fmt.Sprint(v)
+		}
+		setting := settings[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\n", name, orNone(value),
+			orNone(setting.Current), orNone(setting.Context), setting.PendingRestart)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "INSTANCE\tROLE\tSTATE\tPENDING RESTART")
+	for _, member := range members {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", member.Member, member.Role, member.State, member.pending())
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crunchydata/postgres-operator-client/internal/testing/cmp"
+)
+
+func TestPostgresParametersModifyIntent(t *testing.T) {
+	unmarshal := func(t *testing.T, doc string) *unstructured.Unstructured {
+		t.Helper()
+		object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		assert.NilError(t, yaml.Unmarshal([]byte(strings.TrimSpace(doc)), &object.Object))
+		return object
+	}
+
+	cluster := unmarshal(t, `
+spec:
+  patroni:
+    dynamicConfiguration:
+      postgresql:
+        parameters:
+          max_connections: "200"
+          work_mem: 8MB
+	`)
+
+	t.Run("Set", func(t *testing.T) {
+		parameters := postgresParameters{
+			Names:  []string{"work_mem", "shared_buffers"},
+			Values: map[string]string{"work_mem": "16MB", "shared_buffers": "1GB"},
+		}
+
+		intent := unmarshal(t, `{}`)
+		assert.NilError(t, parameters.setIntent(cluster, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `
+spec:
+  patroni:
+    dynamicConfiguration:
+      postgresql:
+        parameters:
+          shared_buffers: 1GB
+          work_mem: 16MB
+		`))
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		intent := unmarshal(t, `
+spec:
+  patroni:
+    dynamicConfiguration:
+      postgresql:
+        parameters:
+          work_mem: 8MB
+		`)
+
+		parameters := postgresParameters{Names: []string{"work_mem"}}
+		assert.NilError(t, parameters.unsetIntent(cluster, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `{}`))
+
+		parameters = postgresParameters{Names: []string{"max_connections"}}
+		assert.ErrorContains(t, parameters.unsetIntent(cluster, intent),
+			`parameter "max_connections" is managed by another field manager`)
+
+		parameters = postgresParameters{Names: []string{"wal_level"}, PostgresCluster: "hippo"}
+		assert.ErrorContains(t, parameters.unsetIntent(cluster, intent),
+			`parameter "wal_level" is not set in hippo`)
+	})
+}
+
+func TestParseSettingNumber(t *testing.T) {
+	for _, tt := range []struct {
+		value, unit string
+		expected    float64
+		err         string
+	}{
+		{value: "100", unit: "", expected: 100},
+		{value: " 2.5 ", unit: "", expected: 2.5},
+		{value: "16MB", unit: "kB", expected: 16 * 1024},
+		{value: "1GB", unit: "8kB", expected: 1024 * 1024 / 8},
+		{value: "128", unit: "8kB", expected: 128},
+		{value: "2min", unit: "ms", expected: 120000},
+		{value: "1h", unit: "s", expected: 3600},
+		{value: "500us", unit: "ms", expected: 0.5},
+		{value: "lots", unit: "kB", err: `invalid value "lots": expected a number`},
+		{value: "10parsecs", unit: "ms", err: `invalid unit "parsecs": units supported: us,ms,s,min,h,d`},
+		{value: "10MB", unit: "ms", err: `invalid unit "MB": units supported: us,ms,s,min,h,d`},
+		{value: "10MB", unit: "", err: `invalid value "10MB": expected a number without a unit`},
+	} {
+		n, err := parseSettingNumber(tt.value, tt.unit)
+		if tt.err != "" {
+			assert.ErrorContains(t, err, tt.err)
+			continue
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, n, tt.expected, "%q in %q", tt.value, tt.unit)
+	}
+}
+
+func TestValidateParameter(t *testing.T) {
+	settings, err := parsePGSettings(`[
+		{"name": "work_mem", "setting": "4096", "unit": "kB", "vartype": "integer",
+		 "context": "user", "min_val": "64", "max_val": "2147483647", "enumvals": null},
+		{"name": "max_connections", "setting": "100", "unit": null, "vartype": "integer",
+		 "context": "postmaster", "min_val": "1", "max_val": "262143", "enumvals": null},
+		{"name": "jit", "setting": "on", "unit": null, "vartype": "bool",
+		 "context": "user", "min_val": null, "max_val": null, "enumvals": null},
+		{"name": "wal_level", "setting": "logical", "unit": null, "vartype": "enum",
+		 "context": "postmaster", "min_val": null, "max_val": null,
+		 "enumvals": ["minimal", "replica", "logical"]},
+		{"name": "block_size", "setting": "8192", "unit": null, "vartype": "integer",
+		 "context": "internal", "min_val": "8192", "max_val": "8192", "enumvals": null},
+		{"name": "search_path", "setting": "public", "unit": null, "vartype": "string",
+		 "context": "user", "min_val": null, "max_val": null, "enumvals": null}
+	]`)
+	assert.NilError(t, err)
+
+	for _, tt := range []struct {
+		name, value, err string
+	}{
+		{name: "work_mem", value: "16MB"},
+		{name: "work_mem", value: "32kB", err: `work_mem: "32kB" is less than the minimum 64 (kB)`},
+		{name: "max_connections", value: "500"},
+		{name: "max_connections", value: "0", err: `max_connections: "0" is less than the minimum 1`},
+		{name: "max_connections", value: "300000", err: `max_connections: "300000" is more than the maximum 262143`},
+		{name: "jit", value: "OFF"},
+		{name: "jit", value: "maybe", err: `jit: invalid value "maybe": expected on or off`},
+		{name: "wal_level", value: "Replica"},
+		{name: "wal_level", value: "archive", err: `values supported: minimal,replica,logical`},
+		{name: "block_size", value: "8192", err: "block_size: cannot be changed"},
+		{name: "search_path", value: "anything, at all"},
+		{name: "pg_stat_statements.track", value: "all"},
+		{name: "no_such_thing", value: "1", err: "no_such_thing: unrecognized parameter"},
+	} {
+		err := validateParameter(settings, tt.name, tt.value)
+		if tt.err != "" {
+			assert.ErrorContains(t, err, tt.err)
+			continue
+		}
+		assert.NilError(t, err, "%s=%s", tt.name, tt.value)
+	}
+}
+
+func TestWritePostgresParameters(t *testing.T) {
+	settings, err := parsePGSettings(`[
+		{"name": "shared_buffers", "current": "128MB", "context": "postmaster", "pending_restart": true},
+		{"name": "work_mem", "current": "16MB", "context": "user", "pending_restart": false}
+	]`)
+	assert.NilError(t, err)
+
+	members, err := parsePatroniMembers(`[
+		{"Cluster": "hippo-ha", "Member": "hippo-instance1-efgh-0", "Role": "Replica", "State": "streaming"},
+		{"Cluster": "hippo-ha", "Member": "hippo-instance1-abcd-0", "Role": "Leader", "State": "running",
+		 "Pending restart": "*"}
+	]`)
+	assert.NilError(t, err)
+
+	spec := map[string]interface{}{"work_mem": "16MB", "shared_buffers": "256MB"}
+
+	var out bytes.Buffer
+	assert.NilError(t, writePostgresParameters(&out, nil, spec, settings, members))
+	assert.Equal(t, out.String(), strings.TrimLeft(`
+PARAMETER       SPEC   CURRENT  CONTEXT     PENDING RESTART
+shared_buffers  256MB  128MB    postmaster  true
+work_mem        16MB   16MB     user        false
+
+INSTANCE                ROLE     STATE      PENDING RESTART
+hippo-instance1-abcd-0  Leader   running    true
+hippo-instance1-efgh-0  Replica  streaming  false
+`, "\n"))
+
+	out.Reset()
+	assert.NilError(t, writePostgresParameters(&out, []string{"max_connections"}, spec, settings, nil))
+	assert.Assert(t, strings.HasPrefix(out.String(), "PARAMETER"))
+	assert.Assert(t, strings.Contains(out.String(), "max_connections  <none>  <none>   <none>   false"), out.String())
+}
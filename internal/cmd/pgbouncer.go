@@ -0,0 +1,539 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/apis/postgres-operator.crunchydata.com/v1beta1"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+// pgBouncerPath is where PgBouncer is in the spec of a PostgresCluster.
+var pgBouncerPath = []string{"spec", "proxy", "pgBouncer"}
+
+// pgBouncerStatsUser is the user PGO creates for PgBouncer. It is in the
+// "stats_users" of PgBouncer after 'pgo pgbouncer enable'.
+const pgBouncerStatsUser = "_crunchypgbouncer"
+
+// newPGBouncerCommand returns the pgbouncer subcommand of the PGO plugin. Its
+// subcommands manage the PgBouncer connection pooler of a PostgresCluster.
+func newPGBouncerCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pgbouncer",
+		Short: "Manage the PgBouncer connection pooler of a PostgresCluster",
+		Long: `Manage the PgBouncer connection pooler in "spec.proxy.pgBouncer" of a
+PostgresCluster.`,
+	}
+
+	cmd.AddCommand(
+		newPGBouncerConfigCommand(config),
+		newPGBouncerDisableCommand(config),
+		newPGBouncerEnableCommand(config),
+		newPGBouncerScaleCommand(config),
+		newPGBouncerShowCommand(config),
+	)
+
+	// No arguments for 'pgbouncer', but there are arguments for the subcommands.
+	cmd.Args = cobra.NoArgs
+
+	return cmd
+}
+
+func newPGBouncerEnableCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "enable CLUSTER_NAME",
+		Short: "Add PgBouncer to a PostgresCluster",
+		Long: `Add PgBouncer to a PostgresCluster. The PGO user of PgBouncer is added to its
+"stats_users" so that 'pgo pgbouncer show' can read its statistics.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Add PgBouncer to the 'hippo' postgrescluster
+pgo pgbouncer enable hippo
+
+# Add two PgBouncer Pods to the 'hippo' postgrescluster
+pgo pgbouncer enable hippo --replicas=2
+`)
+
+	bouncer := pgBouncer{Config: config}
+
+	cmd.Flags().Int32Var(&bouncer.Replicas, "replicas", 1, "number of PgBouncer Pods")
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		bouncer.PostgresCluster = args[0]
+		return bouncer.Run(context.Background(), "enabled", bouncer.enableIntent)
+	}
+
+	return cmd
+}
+
+func newPGBouncerDisableCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disable CLUSTER_NAME",
+		Short: "Remove PgBouncer from a PostgresCluster",
+		Long: `Remove PgBouncer from a PostgresCluster. Only PgBouncer added by this plugin
+can be removed by it.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Remove PgBouncer from the 'hippo' postgrescluster
+pgo pgbouncer disable hippo
+`)
+
+	bouncer := pgBouncer{Config: config}
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		bouncer.PostgresCluster = args[0]
+		return bouncer.Run(context.Background(), "disabled", bouncer.disableIntent)
+	}
+
+	return cmd
+}
+
+func newPGBouncerScaleCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scale CLUSTER_NAME --replicas=COUNT",
+		Short: "Change the number of PgBouncer Pods",
+		Long: `Change the number of PgBouncer Pods of a PostgresCluster.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Run three PgBouncer Pods for the 'hippo' postgrescluster
+pgo pgbouncer scale hippo --replicas=3
+`)
+
+	bouncer := pgBouncer{Config: config}
+
+	cmd.Flags().Int32Var(&bouncer.Replicas, "replicas", 0, "number of PgBouncer Pods")
+	cobra.CheckErr(cmd.MarkFlagRequired("replicas"))
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		bouncer.PostgresCluster = args[0]
+		return bouncer.Run(context.Background(), "scaled", bouncer.scaleIntent)
+	}
+
+	return cmd
+}
+
+func newPGBouncerConfigCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config CLUSTER_NAME [SETTING=VALUE...]",
+		Short: "Show or change PgBouncer settings",
+		Long: `Show or change the settings in the "[pgbouncer]" section of the PgBouncer
+configuration of a PostgresCluster. Without any changes, the settings in its
+spec are printed. Only settings set by this plugin can be removed by it.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]
+
+###### Note: The patch permission is not required to print the settings.`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Print the PgBouncer settings of the 'hippo' postgrescluster
+pgo pgbouncer config hippo
+
+# Change the pool mode and size
+pgo pgbouncer config hippo pool_mode=transaction default_pool_size=50
+
+# Remove a setting so that its default is used
+pgo pgbouncer config hippo --unset=default_pool_size
+`)
+
+	bouncer := pgBouncer{Config: config}
+
+	cmd.Flags().StringArrayVar(&bouncer.Unset, "unset", nil,
+		"remove a setting; can be used more than once")
+
+	// The PostgresCluster name followed by any settings.
+	cmd.Args = cobra.MinimumNArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		bouncer.PostgresCluster = args[0]
+		bouncer.Settings = map[string]string{}
+		for _, arg := range args[1:] {
+			name, value, found := strings.Cut(arg, "=")
+			if !found || strings.TrimSpace(name) == "" {
+				return fmt.Errorf("invalid setting %q: expected SETTING=VALUE", arg)
+			}
+			bouncer.Settings[strings.TrimSpace(name)] = value
+		}
+		if len(bouncer.Settings) == 0 && len(bouncer.Unset) == 0 {
+			return bouncer.Print(context.Background())
+		}
+		return bouncer.Run(context.Background(), "configured", bouncer.configIntent)
+	}
+
+	return cmd
+}
+
+func newPGBouncerShowCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show CLUSTER_NAME [pools|stats|clients]",
+		Short: "Show the pools, statistics or clients of PgBouncer",
+		Long: `Show the pools, statistics or clients of every PgBouncer Pod of a
+PostgresCluster as one table. The SHOW command runs in the PgBouncer admin
+console from the primary instance as the PGO user of PgBouncer, which must be
+in "stats_users".
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get]
+    pods                                                [list]
+    pods/exec                                           [create]
+    secrets                                             [get]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Show the pools of PgBouncer of the 'hippo' postgrescluster
+pgo pgbouncer show hippo
+
+# Show the clients connected to PgBouncer
+pgo pgbouncer show hippo clients
+`)
+
+	bouncer := pgBouncer{Config: config}
+
+	// The PostgresCluster name optionally followed by what to show.
+	cmd.Args = cobra.RangeArgs(1, 2)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		bouncer.PostgresCluster = args[0]
+
+		show := "pools"
+		if len(args) > 1 {
+			show = strings.ToLower(args[1])
+		}
+		switch show {
+		case "pools", "stats", "clients":
+		default:
+			return fmt.Errorf("invalid %q: types supported: pools,stats,clients", args[1])
+		}
+
+		return bouncer.Show(context.Background(), "SHOW "+strings.ToUpper(show))
+	}
+
+	return cmd
+}
+
+type pgBouncer struct {
+	*internal.Config
+
+	Replicas int32
+	Settings map[string]string
+	Unset    []string
+
+	PostgresCluster string
+}
+
+// Run changes the PostgresCluster with modify and prints action when the
+// change is accepted.
+func (config pgBouncer) Run(
+	ctx context.Context, action string,
+	modify func(cluster, intent *unstructured.Unstructured) error,
+) error {
+	return applyClusterIntent(ctx, config.Config, config.PostgresCluster,
+		"pgbouncer "+action, modify)
+}
+
+// requireEnabled returns an error when cluster has no PgBouncer.
+func (config pgBouncer) requireEnabled(cluster *unstructured.Unstructured) error {
+	if _, found, _ := unstructured.NestedMap(cluster.Object, pgBouncerPath...); !found {
+		return fmt.Errorf("PgBouncer is not enabled in %s; see 'pgo pgbouncer enable'",
+			config.PostgresCluster)
+	}
+	return nil
+}
+
+// enableIntent adds PgBouncer with the replicas and stats user to intent.
+func (config pgBouncer) enableIntent(cluster, intent *unstructured.Unstructured) error {
+	if config.requireEnabled(cluster) == nil {
+		return fmt.Errorf("PgBouncer is already enabled in %s", config.PostgresCluster)
+	}
+	if config.Replicas < 1 {
+		return fmt.Errorf("invalid replicas %d: must be at least 1", config.Replicas)
+	}
+
+	return unstructured.SetNestedMap(intent.Object, map[string]interface{}{
+		"replicas": int64(config.Replicas),
+		"config": map[string]interface{}{
+			"global": map[string]interface{}{"stats_users": pgBouncerStatsUser},
+		},
+	}, pgBouncerPath...)
+}
+
+// disableIntent removes PgBouncer from intent. PgBouncer that was not added
+// through this field manager cannot be removed by it.
+func (config pgBouncer) disableIntent(cluster, intent *unstructured.Unstructured) error {
+	if err := config.requireEnabled(cluster); err != nil {
+		return err
+	}
+	if _, found, _ := unstructured.NestedMap(intent.Object, pgBouncerPath...); !found {
+		return errors.New("PgBouncer is managed by another field manager; remove it with the tool that added it")
+	}
+
+	unstructured.RemoveNestedField(intent.Object, pgBouncerPath...)
+	internal.RemoveEmptySections(intent, pgBouncerPath[:len(pgBouncerPath)-1]...)
+	return nil
+}
+
+// scaleIntent sets the replicas of PgBouncer in intent.
+func (config pgBouncer) scaleIntent(cluster, intent *unstructured.Unstructured) error {
+	if err := config.requireEnabled(cluster); err != nil {
+		return err
+	}
+	if config.Replicas < 1 {
+		return fmt.Errorf("invalid replicas %d: must be at least 1", config.Replicas)
+	}
+
+	return unstructured.SetNestedField(intent.Object, int64(config.Replicas),
+		append(pgBouncerPath, "replicas")...)
+}
+
+// configIntent sets and removes the global PgBouncer settings in intent.
+func (config pgBouncer) configIntent(cluster, intent *unstructured.Unstructured) error {
+	if err := config.requireEnabled(cluster); err != nil {
+		return err
+	}
+
+	path := append(append([]string{}, pgBouncerPath...), "config", "global")
+	current, _, _ := unstructured.NestedStringMap(cluster.Object, path...)
+	settings, _, _ := unstructured.NestedStringMap(intent.Object, path...)
+	if settings == nil {
+		settings = map[string]string{}
+	}
+
+	for _, name := range config.Unset {
+		if _, ok := current[name]; !ok {
+			return fmt.Errorf("setting %q is not set in %s", name, config.PostgresCluster)
+		}
+		if _, ok := settings[name]; !ok {
+			return fmt.Errorf("setting %q is managed by another field manager; remove it with the tool that set it", name)
+		}
+		delete(settings, name)
+	}
+	for name, value := range config.Settings {
+		settings[name] = value
+	}
+
+	if err := unstructured.SetNestedStringMap(intent.Object, settings, path...); err != nil {
+		return err
+	}
+	internal.RemoveEmptySections(intent, path...)
+	return nil
+}
+
+// Print prints the global PgBouncer settings in the spec of the cluster.
+func (config pgBouncer) Print(ctx context.Context) error {
+	_, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	cluster, err := client.Namespace(namespace).Get(ctx,
+		config.PostgresCluster, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if err := config.requireEnabled(cluster); err != nil {
+		return err
+	}
+
+	settings, _, _ := unstructured.NestedStringMap(cluster.Object,
+		append(pgBouncerPath, "config", "global")...)
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(config.Out, "%s = %s\n", name, settings[name])
+	}
+	return nil
+}
+
+// Show runs show in the admin console of every PgBouncer Pod and prints the
+// results as one table.
+func (config pgBouncer) Show(ctx context.Context, show string) error {
+	_, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	core, err := corev1client.NewForConfig(rest)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	cluster, err := client.Namespace(namespace).Get(ctx,
+		config.PostgresCluster, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if err := config.requireEnabled(cluster); err != nil {
+		return err
+	}
+
+	port := int32(5432)
+	if p, found, _ := unstructured.NestedInt64(cluster.Object,
+		append(pgBouncerPath, "port")...); found {
+		port = int32(p)
+	}
+
+	// PGO keeps the password of its PgBouncer user in this Secret.
+	secret, err := core.Secrets(namespace).Get(ctx,
+		config.PostgresCluster+"-pgbouncer", metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	pods, err := core.Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: util.PGBouncerLabels(config.PostgresCluster),
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	var running []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" {
+			running = append(running, pod)
+		}
+	}
+	if len(running) == 0 {
+		return fmt.Errorf("no running PgBouncer Pods found for %s", config.PostgresCluster)
+	}
+
+	exec, _, err := primaryExecutor(ctx, config.Config, namespace, config.PostgresCluster)
+	if err != nil {
+		return err
+	}
+
+	results := make([]pgBouncerResult, 0, len(running))
+	for _, pod := range running {
+		stdout, stderr, err := exec.pgbouncerShow(pod.Status.PodIP, port,
+			secret.Data["pgbouncer-password"], show)
+		if err != nil {
+			if strings.Contains(stderr, "not allowed") {
+				return fmt.Errorf("pod/%s: %s is not allowed to %s; add it to stats_users with "+
+					"'pgo pgbouncer config %s stats_users=%s'", pod.Name, pgBouncerStatsUser,
+					show, config.PostgresCluster, pgBouncerStatsUser)
+			}
+			return fmt.Errorf("pod/%s: %w: %s", pod.Name, err, strings.TrimSpace(stderr))
+		}
+		results = append(results, pgBouncerResult{Pod: pod.Name, CSV: stdout})
+	}
+
+	return writePGBouncerResults(config.Out, results)
+}
+
+// pgBouncerResult is the CSV output of a SHOW command in one PgBouncer Pod.
+type pgBouncerResult struct {
+	Pod string
+	CSV string
+}
+
+// writePGBouncerResults prints results as one table with the Pod of each row
+// in the first column.
+func writePGBouncerResults(out io.Writer, results []pgBouncerResult) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	header := false
+
+	for _, result := range results {
+		records, err := csv.NewReader(strings.NewReader(result.CSV)).ReadAll()
+		if err != nil {
+			return fmt.Errorf("pod/%s: unexpected output: %w", result.Pod, err)
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		if !header {
+			header = true
+			fmt.Fprintf(w, "POD\t%s\n", strings.ToUpper(strings.Join(records[0], "\t")))
+		}
+		for _, record := range records[1:] {
+			for i := range record {
+				if record[i] == "" {
+					record[i] = "<none>"
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\n", result.Pod, strings.Join(record, "\t"))
+		}
+	}
+
+	return w.Flush()
+}
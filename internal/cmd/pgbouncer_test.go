@@ -0,0 +1,156 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crunchydata/postgres-operator-client/internal/testing/cmp"
+)
+
+func TestPGBouncerModifyIntent(t *testing.T) {
+	unmarshal := func(t *testing.T, doc string) *unstructured.Unstructured {
+		t.Helper()
+		object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		assert.NilError(t, yaml.Unmarshal([]byte(strings.TrimSpace(doc)), &object.Object))
+		return object
+	}
+
+	without := unmarshal(t, `{ spec: { postgresVersion: 14 } }`)
+	with := unmarshal(t, `
+spec:
+  proxy:
+    pgBouncer:
+      replicas: 2
+      config:
+        global:
+          pool_mode: transaction
+          stats_users: _crunchypgbouncer
+	`)
+
+	t.Run("Enable", func(t *testing.T) {
+		intent := unmarshal(t, `{}`)
+		bouncer := pgBouncer{Replicas: 2, PostgresCluster: "hippo"}
+		assert.NilError(t, bouncer.enableIntent(without, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `
+spec:
+  proxy:
+    pgBouncer:
+      config:
+        global:
+          stats_users: _crunchypgbouncer
+      replicas: 2
+		`))
+
+		assert.ErrorContains(t, bouncer.enableIntent(with, intent),
+			"PgBouncer is already enabled in hippo")
+
+		bouncer.Replicas = 0
+		assert.ErrorContains(t, bouncer.enableIntent(without, intent),
+			"invalid replicas 0: must be at least 1")
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		intent := unmarshal(t, `{ spec: { proxy: { pgBouncer: { replicas: 2 } } } }`)
+		bouncer := pgBouncer{PostgresCluster: "hippo"}
+		assert.NilError(t, bouncer.disableIntent(with, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `{}`))
+
+		assert.ErrorContains(t, bouncer.disableIntent(with, intent),
+			"PgBouncer is managed by another field manager")
+		assert.ErrorContains(t, bouncer.disableIntent(without, intent),
+			"PgBouncer is not enabled in hippo")
+	})
+
+	t.Run("Scale", func(t *testing.T) {
+		intent := unmarshal(t, `{}`)
+		bouncer := pgBouncer{Replicas: 3, PostgresCluster: "hippo"}
+		assert.NilError(t, bouncer.scaleIntent(with, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `
+spec:
+  proxy:
+    pgBouncer:
+      replicas: 3
+		`))
+
+		assert.ErrorContains(t, bouncer.scaleIntent(without, intent),
+			"PgBouncer is not enabled in hippo")
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		intent := unmarshal(t, `
+spec:
+  proxy:
+    pgBouncer:
+      config:
+        global:
+          stats_users: _crunchypgbouncer
+		`)
+		bouncer := pgBouncer{
+			Settings: map[string]string{"default_pool_size": "50"},
+			Unset:    []string{"stats_users"},
+		}
+		assert.NilError(t, bouncer.configIntent(with, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `
+spec:
+  proxy:
+    pgBouncer:
+      config:
+        global:
+          default_pool_size: "50"
+		`))
+
+		bouncer = pgBouncer{Unset: []string{"pool_mode"}}
+		assert.ErrorContains(t, bouncer.configIntent(with, intent),
+			`setting "pool_mode" is managed by another field manager`)
+
+		bouncer = pgBouncer{Unset: []string{"max_client_conn"}, PostgresCluster: "hippo"}
+		assert.ErrorContains(t, bouncer.configIntent(with, intent),
+			`setting "max_client_conn" is not set in hippo`)
+
+		// Removing the last setting removes the empty sections, too.
+		intent = unmarshal(t, `{ spec: { proxy: { pgBouncer: { config: { global: { pool_mode: session } } } } } }`)
+		bouncer = pgBouncer{Unset: []string{"pool_mode"}}
+		assert.NilError(t, bouncer.configIntent(with, intent))
+		assert.Assert(t, cmp.MarshalMatches(intent.Object, `{}`))
+	})
+}
+
+func TestWritePGBouncerResults(t *testing.T) {
+	var out bytes.Buffer
+	assert.NilError(t, writePGBouncerResults(&out, []pgBouncerResult{
+		{Pod: "hippo-pgbouncer-abc", CSV: "database,user,cl_active,pool_mode\n" +
+			"hippo,hippo,3,session\npgbouncer,pgbouncer,1,statement\n"},
+		{Pod: "hippo-pgbouncer-def", CSV: "database,user,cl_active,pool_mode\n" +
+			"hippo,\"quoted, user\",0,\n"},
+	}))
+
+	assert.Equal(t, out.String(), strings.TrimLeft(`
+POD                  DATABASE   USER          CL_ACTIVE  POOL_MODE
+hippo-pgbouncer-abc  hippo      hippo         3          session
+hippo-pgbouncer-abc  pgbouncer  pgbouncer     1          statement
+hippo-pgbouncer-def  hippo      quoted, user  0          <none>
+`, "\n"))
+
+	assert.ErrorContains(t, writePGBouncerResults(&out, []pgBouncerResult{
+		{Pod: "hippo-pgbouncer-abc", CSV: "a,b\n\"unterminated\n"},
+	}), "pod/hippo-pgbouncer-abc: unexpected output")
+}
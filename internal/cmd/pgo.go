@@ -98,16 +98,35 @@ func NewPGOCommand(stdin io.Reader, stdout, stderr io.Writer) *cobra.Command {
 	root.SetOut(stdout)
 
 	root.AddCommand(newBackupCommand(config))
+	root.AddCommand(newConfigCommand(config))
 	root.AddCommand(newCreateCommand(config))
 	root.AddCommand(newDeleteCommand(config))
+	root.AddCommand(newExecCommand(config))
+	root.AddCommand(newLogsCommand(config))
+	root.AddCommand(newMonitoringCommand(config))
+	root.AddCommand(newPGBouncerCommand(config))
+	root.AddCommand(newRestartCommand(config))
 	root.AddCommand(newRestoreCommand(config))
 	root.AddCommand(newShowCommand(config))
+	root.AddCommand(newStandbyCommand(config))
 	root.AddCommand(newSupportCommand(config))
+	root.AddCommand(newTLSCommand(config))
+	root.AddCommand(newUserCommand(config))
 	root.AddCommand(newVersionCommand(config))
 
 	return root
 }
 
+// ExitError is returned by commands that should exit with a particular code.
+// Other errors exit with 1.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+func (e *ExitError) Unwrap() error { return e.Err }
+
 // formatHeader removes markdown header syntax for CLI help output
 func formatHeader(s string) string {
 	re := regexp.MustCompile(`#### (.*)\n`)
@@ -0,0 +1,261 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+// newExecCommand returns the exec subcommand of the PGO plugin. It runs a
+// command in a container of one instance of a PostgresCluster.
+func newExecCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "exec CLUSTER_NAME -- COMMAND [args...]",
+		Short: "Run a command in an instance of a PostgresCluster",
+		Long: `Run a command in a container of the primary instance of a PostgresCluster, or of
+another instance chosen by name or role. The database container is used unless
+another is given. When more than one Pod matches, the first by name is used.
+
+The command exits with the exit code of the remote command.
+
+#### RBAC Requirements
+    Resources  Verbs
+    ---------  -----
+    pods       [list]
+    pods/exec  [create]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Open psql on the primary instance of the 'hippo' postgrescluster
+pgo exec hippo -it -- psql
+
+# Run a query on a replica instance
+pgo exec hippo --role=replica -- psql -c 'SELECT pg_is_in_recovery()'
+
+# List the files of the pgBackRest container of one instance
+pgo exec hippo --instance=hippo-instance1-abcd --container=pgbackrest -- ls /pgbackrest
+`)
+
+	exec := podExecCommand{Config: config}
+
+	cmd.Flags().StringVar(&exec.Instance, "instance", "",
+		"name of the instance, such as hippo-instance1-abcd")
+	cmd.Flags().StringVar(&exec.Role, "role", "",
+		"role of the instance. types supported: primary,replica")
+	cmd.Flags().StringVarP(&exec.Container, "container", "c", util.ContainerDatabase,
+		"name of the container")
+	cmd.Flags().BoolVarP(&exec.Stdin, "stdin", "i", false,
+		"pass stdin to the command")
+	cmd.Flags().BoolVarP(&exec.TTY, "tty", "t", false,
+		"attach a terminal to the command; requires --stdin and a terminal")
+
+	// The PostgresCluster name followed by the command after a dash.
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() != 1 || len(args) < 2 {
+			return errors.New("expected CLUSTER_NAME -- COMMAND [args...]")
+		}
+		return nil
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		exec.PostgresCluster, exec.Command = args[0], args[1:]
+		return exec.Run(context.Background())
+	}
+
+	return cmd
+}
+
+type podExecCommand struct {
+	*internal.Config
+
+	Container string
+	Instance  string
+	Role      string
+	Stdin     bool
+	TTY       bool
+
+	Command         []string
+	PostgresCluster string
+}
+
+// selector returns the labels of the Pods that can run the command.
+func (config podExecCommand) selector() (string, error) {
+	if config.Instance != "" && config.Role != "" {
+		return "", errors.New("only one of --instance or --role may be used")
+	}
+	if config.Instance != "" {
+		return util.InstancePodLabels(config.PostgresCluster, config.Instance), nil
+	}
+
+	switch config.Role {
+	case "", "primary":
+		return util.PrimaryInstanceLabels(config.PostgresCluster), nil
+	case "replica":
+		return util.ReplicaInstanceLabels(config.PostgresCluster), nil
+	}
+	return "", fmt.Errorf("invalid role %q: types supported: primary,replica", config.Role)
+}
+
+// findPod returns the first Pod by name that can run the command.
+func (config podExecCommand) findPod(ctx context.Context, pods corev1client.PodInterface) (*corev1.Pod, error) {
+	selector, err := config.selector()
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("no Pod found for %s with labels %q", config.PostgresCluster, selector)
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].Name < list.Items[j].Name
+	})
+	return &list.Items[0], nil
+}
+
+// terminal returns stdin when the command should run in a terminal. A remote
+// terminal needs one on this side, too, and input to pass to it; without
+// either the command runs without a terminal, like kubectl exec.
+func (config podExecCommand) terminal() *os.File {
+	if !config.TTY {
+		return nil
+	}
+	if !config.Stdin {
+		fmt.Fprintln(config.ErrOut, "Unable to use a TTY - input is not connected")
+		return nil
+	}
+	if file, ok := config.In.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		return file
+	}
+	fmt.Fprintln(config.ErrOut, "Unable to use a TTY - input is not a terminal")
+	return nil
+}
+
+func (config podExecCommand) Run(ctx context.Context) error {
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	client, err := corev1client.NewForConfig(rest)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	pod, err := config.findPod(ctx, client.Pods(namespace))
+	if err != nil {
+		return err
+	}
+
+	var stdin io.Reader
+	if config.Stdin {
+		stdin = config.In
+	}
+
+	terminal := config.terminal()
+	if terminal == nil {
+		podExec, err := util.NewPodExecutor(rest)
+		if err == nil {
+			err = podExec(pod.Namespace, pod.Name, config.Container,
+				stdin, config.Out, config.ErrOut, config.Command...)
+		}
+		return remoteExitError(err)
+	}
+
+	podExec, err := util.NewPodTerminalExecutor(rest)
+	if err != nil {
+		return err
+	}
+
+	// Pass every key to the remote terminal, including interrupts.
+	fd := int(terminal.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = term.Restore(fd, state) }()
+
+	sizes := &terminalSizes{fd: fd, interval: 250 * time.Millisecond, done: make(chan struct{})}
+	defer close(sizes.done)
+
+	return remoteExitError(podExec(pod.Namespace, pod.Name, config.Container,
+		stdin, config.Out, sizes, config.Command...))
+}
+
+// remoteExitError returns an [ExitError] with the exit code of the remote
+// command when err has one.
+func remoteExitError(err error) error {
+	var exit utilexec.ExitError
+	if errors.As(err, &exit) && exit.Exited() {
+		return &ExitError{Code: exit.ExitStatus(), Err: err}
+	}
+	return err
+}
+
+// terminalSizes sends the size of the terminal at fd whenever it changes.
+type terminalSizes struct {
+	fd       int
+	interval time.Duration
+	done     chan struct{}
+	last     remotecommand.TerminalSize
+}
+
+// Next returns the next size of the terminal or nil when it is no longer
+// needed.
+func (s *terminalSizes) Next() *remotecommand.TerminalSize {
+	for {
+		width, height, err := term.GetSize(s.fd)
+		if err != nil {
+			return nil
+		}
+
+		size := remotecommand.TerminalSize{Width: uint16(width), Height: uint16(height)}
+		if size != s.last {
+			s.last = size
+			return &size
+		}
+
+		select {
+		case <-s.done:
+			return nil
+		case <-time.After(s.interval):
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes/fake"
+	utilexec "k8s.io/client-go/util/exec"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+func TestPodExecFindPod(t *testing.T) {
+	instance := func(pod, instance, role string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: pod, Namespace: "postgres-operator", Labels: map[string]string{
+				util.LabelCluster: "hippo", util.LabelData: util.DataPostgres,
+				util.LabelInstance: instance, util.LabelRole: role,
+			},
+		}}
+	}
+	pods := fake.NewSimpleClientset(
+		instance("hippo-instance1-efgh-0", "hippo-instance1-efgh", util.RolePatroniReplica),
+		instance("hippo-instance1-abcd-0", "hippo-instance1-abcd", util.RolePatroniLeader),
+		instance("hippo-instance1-bcde-0", "hippo-instance1-bcde", util.RolePatroniReplica),
+	).CoreV1().Pods("postgres-operator")
+
+	for _, tt := range []struct {
+		exec     podExecCommand
+		pod, err string
+	}{
+		{exec: podExecCommand{}, pod: "hippo-instance1-abcd-0"},
+		{exec: podExecCommand{Role: "primary"}, pod: "hippo-instance1-abcd-0"},
+		{exec: podExecCommand{Role: "replica"}, pod: "hippo-instance1-bcde-0"},
+		{exec: podExecCommand{Instance: "hippo-instance1-efgh"}, pod: "hippo-instance1-efgh-0"},
+		{
+			exec: podExecCommand{Instance: "hippo-instance1-zzzz"},
+			err:  `no Pod found for hippo with labels "postgres-operator.crunchydata.com/cluster=hippo,`,
+		},
+		{
+			exec: podExecCommand{Instance: "hippo-instance1-abcd", Role: "replica"},
+			err:  "only one of --instance or --role may be used",
+		},
+		{
+			exec: podExecCommand{Role: "leader"},
+			err:  `invalid role "leader": types supported: primary,replica`,
+		},
+	} {
+		tt.exec.PostgresCluster = "hippo"
+		pod, err := tt.exec.findPod(context.Background(), pods)
+		if tt.err != "" {
+			assert.ErrorContains(t, err, tt.err)
+			continue
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, pod.Name, tt.pod, "%+v", tt.exec)
+	}
+}
+
+func TestPodExecTerminal(t *testing.T) {
+	for _, tt := range []struct {
+		exec    podExecCommand
+		warning string
+	}{
+		{exec: podExecCommand{}},
+		{exec: podExecCommand{Stdin: true}},
+		{exec: podExecCommand{TTY: true}, warning: "Unable to use a TTY - input is not connected\n"},
+		{exec: podExecCommand{TTY: true, Stdin: true}, warning: "Unable to use a TTY - input is not a terminal\n"},
+	} {
+		var stderr strings.Builder
+		tt.exec.Config = &internal.Config{IOStreams: genericclioptions.IOStreams{
+			In: strings.NewReader("select 1;"), ErrOut: &stderr,
+		}}
+
+		assert.Assert(t, tt.exec.terminal() == nil, "%+v", tt.exec)
+		assert.Equal(t, stderr.String(), tt.warning, "TTY=%t Stdin=%t", tt.exec.TTY, tt.exec.Stdin)
+	}
+}
+
+func TestRemoteExitError(t *testing.T) {
+	assert.NilError(t, remoteExitError(nil))
+
+	other := errors.New("connection refused")
+	assert.Equal(t, remoteExitError(other), other)
+
+	remote := utilexec.CodeExitError{Err: errors.New("command terminated with exit code 3"), Code: 3}
+	err := remoteExitError(fmt.Errorf("wrapped: %w", remote))
+
+	var exit *ExitError
+	assert.Assert(t, errors.As(err, &exit))
+	assert.Equal(t, exit.Code, 3)
+	assert.ErrorContains(t, err, "command terminated with exit code 3")
+}
@@ -0,0 +1,493 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/cli-runtime/pkg/printers"
+	"sigs.k8s.io/yaml"
+)
+
+// redactLevel controls how aggressively the support export removes values
+// that may be sensitive.
+type redactLevel string
+
+const (
+	// redactNone leaves every value as it was collected.
+	redactNone redactLevel = "none"
+
+	// redactStandard removes environment variables and ConfigMap keys with
+	// sensitive names and any value that looks like a credential.
+	redactStandard redactLevel = "standard"
+
+	// redactStrict removes every environment variable value and every
+	// ConfigMap value in addition to everything removed by redactStandard.
+	redactStrict redactLevel = "strict"
+)
+
+// redactedValue replaces anything removed from the support export.
+const redactedValue = "<REDACTED>"
+
+// sensitiveName matches environment variable names and ConfigMap keys that
+// likely hold a credential. Names such as AUTHOR or PGAUTHMETHOD only mention
+// authentication and do not match.
+var sensitiveName = regexp.MustCompile(
+	`(?i)(passw(or)?d|pwd|secret|token|credential|api[_.-]?key|private[_.-]?key|access[_.-]?key|auth[_.-]?(token|key|secret|pass)|pgpass|verifier)`)
+
+// sensitivePatterns match credentials embedded in otherwise harmless text,
+// such as connection strings and log lines. Each match is replaced by its
+// template, which keeps the surrounding context.
+var sensitivePatterns = []struct {
+	reason   string
+	match    *regexp.Regexp
+	template string
+}{{
+	reason: "private key",
+	match: regexp.MustCompile(
+		`-----BEGIN ([A-Z ]*)PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	template: "-----BEGIN ${1}PRIVATE KEY-----" + redactedValue,
+}, {
+	reason:   "connection URI password",
+	match:    regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]+:)[^@\s]+@`),
+	template: "${1}" + redactedValue + "@",
+}, {
+	// The name may be quoted, such as in JSON.
+	reason: "password parameter",
+	match: regexp.MustCompile(
+		`(?i)(\b(?:password|passwd|pwd|secret|token)["']?\s*[=:]\s*)(?:"(?:[^"\\]|\\.)*"|'[^']*'|[^\s,;&"']+)`),
+	template: "${1}" + redactedValue,
+}, {
+	reason:   "bearer token",
+	match:    regexp.MustCompile(`(?i)(\bbearer\s+)[a-zA-Z0-9._~+/-]+=*`),
+	template: "${1}" + redactedValue,
+}, {
+	reason:   "password verifier",
+	match:    regexp.MustCompile(`SCRAM-SHA-256\$[^\s'"]+|\bmd5[0-9a-f]{32}\b`),
+	template: redactedValue,
+}, {
+	reason:   "password in SQL",
+	match:    regexp.MustCompile(`(?i)(\bPASSWORD\s+)'(?:[^']|'')*'`),
+	template: "${1}'" + redactedValue + "'",
+}}
+
+// lastAppliedAnnotation holds an entire copy of an object as it was applied by
+// kubectl, including any value that is redacted elsewhere in the object.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// redaction describes one value that was removed from the support export.
+// It never includes the value itself.
+type redaction struct {
+	Path   string `json:"path"`
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// redactor removes sensitive values from objects and text before they are
+// written to the support export archive. It remembers what it removed so
+// that can be reported. It is safe for concurrent use.
+type redactor struct {
+	level redactLevel
+
+	mu       sync.Mutex
+	findings []redaction
+}
+
+// newRedactor returns a redactor for level or an error when level is not
+// recognized.
+func newRedactor(level string) (*redactor, error) {
+	switch l := redactLevel(level); l {
+	case redactNone, redactStandard, redactStrict:
+		return &redactor{level: l}, nil
+	default:
+		return nil, fmt.Errorf(
+			"invalid redact level %q: expected one of none, standard, strict", level)
+	}
+}
+
+// marshal converts obj to YAML after removing sensitive values. The path is
+// the archive entry where the result will be written.
+func (r *redactor) marshal(path string, obj interface{}) ([]byte, error) {
+	if r.level == redactNone {
+		return yaml.Marshal(obj)
+	}
+
+	// Convert obj to its generic JSON form so that typed objects and
+	// unstructured objects are handled the same way. The YAML package also
+	// goes through JSON, so the result is identical when nothing is redacted.
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(r.value(path, "", generic))
+}
+
+// text removes credentials from unstructured text such as logs and command
+// output. The path is the archive entry where the result will be written.
+func (r *redactor) text(path string, content []byte) []byte {
+	if r.level == redactNone {
+		return content
+	}
+
+	redacted, found := redactString(string(content))
+	for _, f := range found {
+		r.record(path, "line "+strconv.Itoa(f.line), f.reason)
+	}
+
+	if len(found) == 0 {
+		return content
+	}
+	return []byte(redacted)
+}
+
+// redactPendingLimit is how much text a redactWriter holds while it waits for
+// the end of a line or of a private key.
+const redactPendingLimit = 1 << 20
+
+// privateKeyBegin and privateKeyEnd match the lines around a private key.
+var (
+	privateKeyBegin = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)
+	privateKeyEnd   = regexp.MustCompile(`-----END [A-Z ]*PRIVATE KEY-----`)
+)
+
+// writer returns a writer that removes credentials from text, such as a log
+// being streamed, before passing it to w. The path is the archive entry where
+// the result will be written. Text is redacted one complete line at a time, or
+// one complete private key at a time, so the writer must be closed to pass on
+// the last line.
+func (r *redactor) writer(path string, w io.Writer) io.WriteCloser {
+	return &redactWriter{redactor: r, path: path, w: w}
+}
+
+// redactWriter is the io.WriteCloser returned by [redactor.writer].
+type redactWriter struct {
+	redactor *redactor
+	path     string
+	w        io.Writer
+
+	pending []byte
+	lines   int // the number of lines already passed on
+}
+
+// Write implements io.Writer.
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	if rw.redactor.level == redactNone {
+		return rw.w.Write(p)
+	}
+
+	rw.pending = append(rw.pending, p...)
+
+	// Pass on complete lines, but hold a private key until its end so that
+	// it is removed as a whole.
+	end := bytes.LastIndexByte(rw.pending, '\n') + 1
+	if begins := privateKeyBegin.FindAllIndex(rw.pending[:end], -1); len(begins) > 0 {
+		last := begins[len(begins)-1][0]
+		if !privateKeyEnd.Match(rw.pending[last:end]) {
+			end = bytes.LastIndexByte(rw.pending[:last], '\n') + 1
+		}
+	}
+	if len(rw.pending) > redactPendingLimit {
+		end = len(rw.pending)
+	}
+
+	if err := rw.flush(end); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close passes on any remaining text. It does not close the underlying writer.
+func (rw *redactWriter) Close() error {
+	return rw.flush(len(rw.pending))
+}
+
+// flush redacts and passes on the first end bytes of pending text.
+func (rw *redactWriter) flush(end int) error {
+	if end == 0 {
+		return nil
+	}
+
+	chunk := string(rw.pending[:end])
+	redacted, found := redactString(chunk)
+	for _, f := range found {
+		rw.redactor.record(rw.path, "line "+strconv.Itoa(rw.lines+f.line), f.reason)
+	}
+	rw.lines += strings.Count(chunk, "\n")
+	rw.pending = append(rw.pending[:0], rw.pending[end:]...)
+
+	_, err := io.WriteString(rw.w, redacted)
+	return err
+}
+
+// record remembers that a value at field in path was removed.
+func (r *redactor) record(path, field, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.findings = append(r.findings, redaction{
+		Path: path, Field: field, Reason: reason,
+	})
+}
+
+// value walks v depth first and returns it with sensitive values replaced.
+// The field argument is the location of v within the object.
+func (r *redactor) value(path, field string, v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		configMap := field == "" && typed["kind"] == "ConfigMap"
+
+		// Visit keys in order so findings are reported consistently.
+		keys := make([]string, 0, len(typed))
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			child := typed[key]
+			childField := joinField(field, key)
+
+			if configMap && (key == "data" || key == "binaryData") {
+				typed[key] = r.configMapData(path, childField, child)
+				continue
+			}
+			if key == "env" {
+				typed[key] = r.env(path, childField, child)
+				continue
+			}
+			if key == "annotations" {
+				if annotations, ok := child.(map[string]interface{}); ok {
+					if _, ok := annotations[lastAppliedAnnotation]; ok {
+						annotations[lastAppliedAnnotation] = redactedValue
+						r.record(path, joinField(childField, lastAppliedAnnotation), "last-applied configuration")
+					}
+				}
+			}
+
+			typed[key] = r.value(path, childField, child)
+		}
+		return typed
+
+	case []interface{}:
+		for i := range typed {
+			typed[i] = r.value(path, field+"["+strconv.Itoa(i)+"]", typed[i])
+		}
+		return typed
+
+	case string:
+		s, found := redactString(typed)
+		for _, f := range found {
+			r.record(path, field, f.reason)
+		}
+		return s
+	}
+
+	return v
+}
+
+// configMapData redacts the values of a ConfigMap's data or binaryData.
+func (r *redactor) configMapData(path, field string, v interface{}) interface{} {
+	data, ok := v.(map[string]interface{})
+	if !ok {
+		return r.value(path, field, v)
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := data[key]
+		switch {
+		case r.level == redactStrict:
+			data[key] = redactedValue
+			r.record(path, joinField(field, key), "configmap value")
+		case sensitiveName.MatchString(key):
+			data[key] = redactedValue
+			r.record(path, joinField(field, key), "sensitive key")
+		default:
+			data[key] = r.value(path, joinField(field, key), value)
+		}
+	}
+	return data
+}
+
+// env redacts the values of a container's environment variables.
+func (r *redactor) env(path, field string, v interface{}) interface{} {
+	vars, ok := v.([]interface{})
+	if !ok {
+		return r.value(path, field, v)
+	}
+
+	for i := range vars {
+		itemField := field + "[" + strconv.Itoa(i) + "]"
+		item, ok := vars[i].(map[string]interface{})
+		if !ok {
+			vars[i] = r.value(path, itemField, vars[i])
+			continue
+		}
+
+		name, _ := item["name"].(string)
+		if value, hasValue := item["value"].(string); hasValue {
+			if redacted, reason := r.envValue(name, value); reason != "" {
+				item["value"] = redacted
+				r.record(path, itemField+".value", reason)
+				continue
+			}
+		}
+		vars[i] = r.value(path, itemField, item)
+	}
+	return vars
+}
+
+// envValue returns the value of the environment variable name as it should be
+// written and the reason it was redacted, if it was. Credentials within the
+// value are left for the caller to redact.
+func (r *redactor) envValue(name, value string) (string, string) {
+	switch {
+	case r.level == redactNone:
+		return value, ""
+	case r.level == redactStrict:
+		return redactedValue, "environment value"
+	case sensitiveName.MatchString(name):
+		return redactedValue, "sensitive environment variable"
+	}
+	return value, ""
+}
+
+// joinField appends key to a dotted field path.
+func joinField(field, key string) string {
+	if field == "" {
+		return key
+	}
+	return field + "." + key
+}
+
+// redactString replaces every credential found in s. It returns the result
+// along with the reason for and line number of each replacement. A value that
+// is already redacted is not reported again.
+//
+// Every pattern is matched against the original s so that line numbers refer
+// to it. Where matches overlap, the one from the earlier pattern wins.
+func redactString(s string) (string, []redactMatch) {
+	type span struct {
+		start, end  int
+		replacement string
+	}
+
+	var found []redactMatch
+	var spans []span
+	var newlines []int
+	for _, pattern := range sensitivePatterns {
+	next:
+		for _, m := range pattern.match.FindAllStringSubmatchIndex(s, -1) {
+			for _, other := range spans {
+				if m[0] < other.end && other.start < m[1] {
+					continue next
+				}
+			}
+
+			replaced := string(pattern.match.ExpandString(nil, pattern.template, s, m))
+			spans = append(spans, span{start: m[0], end: m[1], replacement: replaced})
+			if replaced == s[m[0]:m[1]] {
+				continue
+			}
+
+			if newlines == nil {
+				newlines = []int{}
+				for i := 0; i < len(s); i++ {
+					if s[i] == '\n' {
+						newlines = append(newlines, i)
+					}
+				}
+			}
+			found = append(found, redactMatch{
+				reason: pattern.reason,
+				line:   1 + sort.SearchInts(newlines, m[0]),
+			})
+		}
+	}
+	if len(found) == 0 {
+		return s, nil
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var b strings.Builder
+	offset := 0
+	for _, sp := range spans {
+		b.WriteString(s[offset:sp.start])
+		b.WriteString(sp.replacement)
+		offset = sp.end
+	}
+	b.WriteString(s[offset:])
+	return b.String(), found
+}
+
+// redactMatch describes one credential found by redactString.
+type redactMatch struct {
+	reason string
+	line   int
+}
+
+// sorted returns every value that was removed ordered by path. Values found
+// in the same path keep the order in which they were found.
+func (r *redactor) sorted() []redaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	findings := append([]redaction{}, r.findings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Path < findings[j].Path
+	})
+	return findings
+}
+
+// manifest returns a JSON document listing every value that was removed.
+func (r *redactor) manifest() ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Level      redactLevel `json:"level"`
+		Redactions []redaction `json:"redactions"`
+	}{
+		Level:      r.level,
+		Redactions: r.sorted(),
+	}, "", "  ")
+}
+
+// report returns a table of every value that was removed, one per line.
+func (r *redactor) report() string {
+	var buf bytes.Buffer
+	w := printers.GetNewTabWriter(&buf)
+	fmt.Fprintf(w, "PATH\tFIELD\tREASON\n")
+	for _, finding := range r.sorted() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", finding.Path, finding.Field, finding.Reason)
+	}
+	_ = w.Flush()
+	return buf.String()
+}
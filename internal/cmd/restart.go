@@ -0,0 +1,315 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/apis/postgres-operator.crunchydata.com/v1beta1"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+// newRestartCommand returns the restart subcommand of the PGO plugin. It
+// restarts Postgres in the instances of a PostgresCluster one at a time.
+func newRestartCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restart CLUSTER_NAME",
+		Short: "Restart Postgres in the instances of a PostgresCluster",
+		Long: `Restart Postgres in the instances of a PostgresCluster one at a time using
+Patroni. Replicas are restarted first. The primary is restarted last, after a
+switchover to a replica that is running; when there is none, it is restarted
+in place.
+
+Each restart is finished when Patroni reports the instance running with no
+pending restart. Pods are not deleted.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get]
+    pods                                                [list]
+    pods/exec                                           [create]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Restart every instance of the 'hippo' postgrescluster
+pgo restart hippo
+
+# Restart only the instances with changed parameters that need a restart
+pgo restart hippo --pending-only
+
+# Restart the instances of one instance set
+pgo restart hippo --instance-set=instance1
+`)
+
+	restart := clusterRestart{Config: config, Interval: 2 * time.Second}
+
+	cmd.Flags().StringVar(&restart.InstanceSet, "instance-set", "",
+		"only the instances of this instance set")
+	cmd.Flags().BoolVar(&restart.PendingOnly, "pending-only", false,
+		"only the instances that Patroni reports with a pending restart")
+	cmd.Flags().DurationVar(&restart.Timeout, "timeout", 5*time.Minute,
+		"how long to wait for each instance to restart")
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		restart.PostgresCluster = args[0]
+		return restart.Run(context.Background())
+	}
+
+	return cmd
+}
+
+type clusterRestart struct {
+	*internal.Config
+
+	InstanceSet string
+	PendingOnly bool
+	Timeout     time.Duration
+
+	// Interval is the time between checks of the Patroni members.
+	Interval time.Duration
+
+	PostgresCluster string
+}
+
+func (config clusterRestart) Run(ctx context.Context) error {
+	mapping, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	core, err := corev1client.NewForConfig(rest)
+	if err != nil {
+		return err
+	}
+	podExec, err := util.NewPodExecutor(rest)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	// Check that the cluster exists before looking for its Pods.
+	if _, err := client.Namespace(namespace).Get(ctx,
+		config.PostgresCluster, metav1.GetOptions{}); err != nil {
+		return err
+	}
+
+	pods, err := core.Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: util.InstanceLabels(config.PostgresCluster),
+	})
+	if err != nil {
+		return err
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no instance Pods found for %s", config.PostgresCluster)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	// Patroni members are named after their Pods.
+	sets := make(map[string]string, len(pods.Items))
+	for _, pod := range pods.Items {
+		sets[pod.Name] = pod.Labels[util.LabelInstanceSet]
+	}
+
+	// Patroni keeps running while Postgres restarts, so patronictl works in
+	// any instance, even one that is restarting.
+	pod := pods.Items[0]
+	exec := func(stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+		return podExec(pod.Namespace, pod.Name, util.ContainerDatabase,
+			stdin, stdout, stderr, command...)
+	}
+
+	count, err := config.restart(ctx, exec, sets)
+	if err == nil {
+		fmt.Fprintf(config.Out, "%s/%s restarted %d instances\n",
+			mapping.Resource.Resource, config.PostgresCluster, count)
+	}
+	return err
+}
+
+// restart restarts the selected Patroni members, replicas first, and returns
+// how many it restarted. The instance set of each member is in sets.
+func (config clusterRestart) restart(
+	ctx context.Context, exec Executor, sets map[string]string,
+) (int, error) {
+	members, err := config.members(exec)
+	if err != nil {
+		return 0, err
+	}
+
+	var leader *patroniMember
+	var selected []patroniMember
+	for i, member := range members {
+		if config.InstanceSet != "" && sets[member.Member] != config.InstanceSet {
+			continue
+		}
+		if config.PendingOnly && !member.pending() {
+			continue
+		}
+		if member.leader() {
+			leader = &members[i]
+			continue
+		}
+		selected = append(selected, member)
+	}
+
+	if config.InstanceSet != "" {
+		found := false
+		for _, set := range sets {
+			found = found || set == config.InstanceSet
+		}
+		if !found {
+			return 0, fmt.Errorf("no instances found in instance set %q of %s",
+				config.InstanceSet, config.PostgresCluster)
+		}
+	}
+
+	count := 0
+	for _, member := range selected {
+		if err := config.restartMember(ctx, exec, member); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	if leader == nil {
+		return count, nil
+	}
+
+	// Switch over to a running replica, preferring those just restarted,
+	// then restart the former primary as a replica.
+	candidate := ""
+	for _, member := range append(selected, members...) {
+		if !member.leader() && member.running() {
+			candidate = member.Member
+			break
+		}
+	}
+
+	if candidate == "" {
+		fmt.Fprintf(config.Out, "pod/%s has no replica to switch over to; restarting the primary in place\n",
+			leader.Member)
+	} else {
+		fmt.Fprintf(config.Out, "switching over from pod/%s to pod/%s\n", leader.Member, candidate)
+
+		if _, stderr, err := exec.patronictl(fmt.Sprintf(
+			"switchover %s --candidate %s --force", leader.Cluster, candidate,
+		)); err != nil {
+			return count, fmt.Errorf("switchover to %s: %w: %s",
+				candidate, err, strings.TrimSpace(stderr))
+		}
+
+		if err := config.waitFor(ctx, exec, func(members []patroniMember) bool {
+			ready := 0
+			for _, member := range members {
+				if member.Member == candidate && member.leader() && member.running() {
+					ready++
+				}
+				if member.Member == leader.Member && !member.leader() && member.running() {
+					ready++
+				}
+			}
+			return ready == 2
+		}); err != nil {
+			return count, fmt.Errorf("switchover to %s: %w", candidate, err)
+		}
+	}
+
+	if err := config.restartMember(ctx, exec, *leader); err != nil {
+		return count, err
+	}
+	return count + 1, nil
+}
+
+// restartMember restarts Postgres of member and waits until it is running
+// with no pending restart.
+func (config clusterRestart) restartMember(
+	ctx context.Context, exec Executor, member patroniMember,
+) error {
+	fmt.Fprintf(config.Out, "pod/%s restarting\n", member.Member)
+
+	if _, stderr, err := exec.patronictl(fmt.Sprintf(
+		"restart %s %s --force", member.Cluster, member.Member,
+	)); err != nil {
+		return fmt.Errorf("restart %s: %w: %s", member.Member, err, strings.TrimSpace(stderr))
+	}
+
+	if err := config.waitFor(ctx, exec, func(members []patroniMember) bool {
+		for _, m := range members {
+			if m.Member == member.Member {
+				return m.running() && !m.pending()
+			}
+		}
+		return false
+	}); err != nil {
+		return fmt.Errorf("restart %s: %w", member.Member, err)
+	}
+
+	fmt.Fprintf(config.Out, "pod/%s restarted\n", member.Member)
+	return nil
+}
+
+// members returns the Patroni members of the cluster.
+func (config clusterRestart) members(exec Executor) ([]patroniMember, error) {
+	stdout, stderr, err := exec.patronictl("list --format json")
+	if err != nil {
+		return nil, fmt.Errorf("patronictl list: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return parsePatroniMembers(stdout)
+}
+
+// waitFor checks the Patroni members until done returns true or the timeout
+// passes.
+func (config clusterRestart) waitFor(
+	ctx context.Context, exec Executor, done func([]patroniMember) bool,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	var last error
+	err := wait.PollImmediateUntilWithContext(ctx, config.Interval, func(context.Context) (bool, error) {
+		members, err := config.members(exec)
+		last = err
+		return err == nil && done(members), nil
+	})
+	if err != nil && last != nil {
+		return fmt.Errorf("%w: %v", err, last)
+	}
+	return err
+}
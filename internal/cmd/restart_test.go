@@ -0,0 +1,164 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakePatroni answers patronictl commands about its members and changes them
+// as Patroni would.
+type fakePatroni struct {
+	members  []patroniMember
+	commands []string
+
+	// fail is a command that returns an error rather than changing members.
+	fail string
+}
+
+func (p *fakePatroni) exec(_ io.Reader, stdout, _ io.Writer, command ...string) error {
+	args := strings.Fields(strings.TrimPrefix(command[3], "patronictl "))
+	if args[0] == "list" {
+		return json.NewEncoder(stdout).Encode(p.members)
+	}
+
+	p.commands = append(p.commands, strings.Join(args, " "))
+	if p.commands[len(p.commands)-1] == p.fail {
+		return errors.New("boom")
+	}
+	for i := range p.members {
+		member := &p.members[i]
+		switch {
+		case args[0] == "restart" && member.Member == args[2]:
+			member.PendingRestart = nil
+		case args[0] == "switchover" && member.Member == args[3]:
+			member.Role = "Leader"
+		case args[0] == "switchover" && member.leader():
+			member.Role, member.PendingRestart = "Replica", nil
+		}
+	}
+	return nil
+}
+
+func TestClusterRestart(t *testing.T) {
+	cluster := func() *fakePatroni {
+		return &fakePatroni{members: []patroniMember{
+			{Cluster: "hippo-ha", Member: "hippo-instance1-abcd-0", Role: "Leader", State: "running", PendingRestart: "*"},
+			{Cluster: "hippo-ha", Member: "hippo-instance1-bcde-0", Role: "Replica", State: "streaming", PendingRestart: "*"},
+			{Cluster: "hippo-ha", Member: "hippo-instance2-cdef-0", Role: "Replica", State: "streaming"},
+		}}
+	}
+	sets := map[string]string{
+		"hippo-instance1-abcd-0": "instance1",
+		"hippo-instance1-bcde-0": "instance1",
+		"hippo-instance2-cdef-0": "instance2",
+	}
+	run := func(t *testing.T, restart clusterRestart, patroni *fakePatroni) (int, string, error) {
+		var out bytes.Buffer
+		restart.Config = newTestConfig("postgres-operator", &out, &out)
+		restart.PostgresCluster = "hippo"
+		restart.Interval, restart.Timeout = time.Millisecond, time.Second
+
+		count, err := restart.restart(context.Background(), patroni.exec, sets)
+		return count, out.String(), err
+	}
+
+	t.Run("Everything", func(t *testing.T) {
+		patroni := cluster()
+		count, out, err := run(t, clusterRestart{}, patroni)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 3)
+		assert.DeepEqual(t, patroni.commands, []string{
+			"restart hippo-ha hippo-instance1-bcde-0 --force",
+			"restart hippo-ha hippo-instance2-cdef-0 --force",
+			"switchover hippo-ha --candidate hippo-instance1-bcde-0 --force",
+			"restart hippo-ha hippo-instance1-abcd-0 --force",
+		})
+		assert.Equal(t, out, strings.TrimLeft(`
+pod/hippo-instance1-bcde-0 restarting
+pod/hippo-instance1-bcde-0 restarted
+pod/hippo-instance2-cdef-0 restarting
+pod/hippo-instance2-cdef-0 restarted
+switching over from pod/hippo-instance1-abcd-0 to pod/hippo-instance1-bcde-0
+pod/hippo-instance1-abcd-0 restarting
+pod/hippo-instance1-abcd-0 restarted
+`, "\n"))
+	})
+
+	t.Run("PendingOnly", func(t *testing.T) {
+		patroni := cluster()
+		patroni.members[1].PendingRestart = nil
+
+		count, _, err := run(t, clusterRestart{PendingOnly: true}, patroni)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 1)
+		assert.DeepEqual(t, patroni.commands, []string{
+			"switchover hippo-ha --candidate hippo-instance1-bcde-0 --force",
+			"restart hippo-ha hippo-instance1-abcd-0 --force",
+		})
+	})
+
+	t.Run("InstanceSet", func(t *testing.T) {
+		patroni := cluster()
+		count, _, err := run(t, clusterRestart{InstanceSet: "instance2"}, patroni)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 1)
+		assert.DeepEqual(t, patroni.commands, []string{
+			"restart hippo-ha hippo-instance2-cdef-0 --force",
+		})
+
+		_, _, err = run(t, clusterRestart{InstanceSet: "instance3"}, cluster())
+		assert.ErrorContains(t, err, `no instances found in instance set "instance3" of hippo`)
+	})
+
+	t.Run("NoReplicas", func(t *testing.T) {
+		patroni := cluster()
+		patroni.members = patroni.members[:1]
+
+		count, out, err := run(t, clusterRestart{}, patroni)
+		assert.NilError(t, err)
+		assert.Equal(t, count, 1)
+		assert.DeepEqual(t, patroni.commands, []string{
+			"restart hippo-ha hippo-instance1-abcd-0 --force",
+		})
+		assert.Assert(t, strings.Contains(out, "has no replica to switch over to"), out)
+	})
+
+	t.Run("LeaderFails", func(t *testing.T) {
+		patroni := cluster()
+		patroni.fail = "restart hippo-ha hippo-instance1-abcd-0 --force"
+
+		count, _, err := run(t, clusterRestart{}, patroni)
+		assert.ErrorContains(t, err, "boom")
+		assert.Equal(t, count, 2, "only the replicas restarted")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		patroni := cluster()
+		patroni.members[1].State = "starting"
+
+		_, _, err := run(t, clusterRestart{InstanceSet: "instance1"}, patroni)
+		assert.ErrorContains(t, err, "restart hippo-instance1-bcde-0:")
+	})
+}
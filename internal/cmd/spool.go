@@ -0,0 +1,137 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// spoolLineSearch is how far into a truncated spool to look for the start of
+// the first complete line.
+const spoolLineSearch = 64 * 1024
+
+// spool is a temporary file that holds content streamed from the Kubernetes
+// API or from a command until it can be written to the archive. When it has a
+// limit, it keeps only the last limit bytes written to it, so neither memory
+// nor disk grows with the size of the content.
+type spool struct {
+	file  *os.File
+	limit int64 // zero means no limit
+
+	offset  int64 // where the next byte is written in file
+	written int64 // how many bytes were written in total
+}
+
+// newSpool creates an empty spool in the default directory for temporary
+// files. The spool must be closed to remove that file.
+func newSpool(limit int64) (*spool, error) {
+	file, err := os.CreateTemp("", "pgo-support-export-*")
+	if err != nil {
+		return nil, err
+	}
+	return &spool{file: file, limit: limit}, nil
+}
+
+// Write implements io.Writer. Once the spool holds limit bytes, every write
+// replaces the oldest bytes.
+func (s *spool) Write(p []byte) (int, error) {
+	n := len(p)
+	s.written += int64(n)
+
+	if s.limit <= 0 {
+		_, err := s.file.WriteAt(p, s.offset)
+		s.offset += int64(n)
+		return n, err
+	}
+
+	// Only the end of p fits.
+	if int64(len(p)) >= s.limit {
+		p = p[int64(len(p))-s.limit:]
+		s.offset = 0
+	}
+
+	// Write up to the limit then wrap around to the start of the file.
+	head := p
+	if room := s.limit - s.offset; int64(len(head)) > room {
+		head = p[:room]
+	}
+	if _, err := s.file.WriteAt(head, s.offset); err != nil {
+		return 0, err
+	}
+	if tail := p[len(head):]; len(tail) > 0 {
+		if _, err := s.file.WriteAt(tail, 0); err != nil {
+			return 0, err
+		}
+	}
+	s.offset = (s.offset + int64(len(p))) % s.limit
+
+	return n, nil
+}
+
+// size returns how many bytes the spool holds.
+func (s *spool) size() int64 {
+	if s.limit > 0 && s.written > s.limit {
+		return s.limit
+	}
+	return s.written
+}
+
+// truncated returns true when the spool dropped some of what was written.
+func (s *spool) truncated() bool {
+	return s.limit > 0 && s.written > s.limit
+}
+
+// reader returns the content of the spool, oldest byte first, and its size.
+// When the spool is truncated, the content starts at the first complete line
+// found near the start.
+func (s *spool) reader() (io.Reader, int64, error) {
+	if !s.truncated() {
+		return io.NewSectionReader(s.file, 0, s.written), s.written, nil
+	}
+
+	ordered := func() io.Reader {
+		return io.MultiReader(
+			io.NewSectionReader(s.file, s.offset, s.limit-s.offset),
+			io.NewSectionReader(s.file, 0, s.offset))
+	}
+
+	head := make([]byte, spoolLineSearch)
+	n, err := io.ReadFull(ordered(), head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, 0, err
+	}
+
+	var skip int64
+	if i := bytes.IndexByte(head[:n], '\n'); i >= 0 && int64(i+1) < s.limit {
+		skip = int64(i + 1)
+	}
+
+	r := ordered()
+	if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+		return nil, 0, err
+	}
+	return r, s.limit - skip, nil
+}
+
+// Close removes the temporary file.
+func (s *spool) Close() error {
+	err := s.file.Close()
+	if rmErr := os.Remove(s.file.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func readSpool(t *testing.T, s *spool) string {
+	t.Helper()
+
+	r, size, err := s.reader()
+	assert.NilError(t, err)
+
+	b, err := io.ReadAll(r)
+	assert.NilError(t, err)
+	assert.Equal(t, int64(len(b)), size)
+	return string(b)
+}
+
+func TestSpool(t *testing.T) {
+	t.Run("NoLimit", func(t *testing.T) {
+		s, err := newSpool(0)
+		assert.NilError(t, err)
+		defer s.Close()
+
+		for i := 0; i < 3; i++ {
+			_, err := io.WriteString(s, "line\n")
+			assert.NilError(t, err)
+		}
+
+		assert.Assert(t, !s.truncated())
+		assert.Equal(t, readSpool(t, s), "line\nline\nline\n")
+	})
+
+	t.Run("UnderLimit", func(t *testing.T) {
+		s, err := newSpool(100)
+		assert.NilError(t, err)
+		defer s.Close()
+
+		_, err = io.WriteString(s, "one\ntwo\n")
+		assert.NilError(t, err)
+
+		assert.Assert(t, !s.truncated())
+		assert.Equal(t, readSpool(t, s), "one\ntwo\n")
+	})
+
+	t.Run("Wraps", func(t *testing.T) {
+		s, err := newSpool(10)
+		assert.NilError(t, err)
+		defer s.Close()
+
+		for _, line := range []string{"one\n", "two\n", "three\n", "four\n"} {
+			_, err := io.WriteString(s, line)
+			assert.NilError(t, err)
+		}
+
+		info, err := s.file.Stat()
+		assert.NilError(t, err)
+		assert.Equal(t, info.Size(), int64(10), "disk does not grow")
+
+		// The last ten bytes are "ree\nfour\n", which starts mid-line.
+		assert.Assert(t, s.truncated())
+		assert.Equal(t, s.written, int64(19))
+		assert.Equal(t, readSpool(t, s), "four\n")
+	})
+
+	t.Run("LargeWrite", func(t *testing.T) {
+		s, err := newSpool(8)
+		assert.NilError(t, err)
+		defer s.Close()
+
+		_, err = io.WriteString(s, "abc")
+		assert.NilError(t, err)
+		_, err = io.WriteString(s, strings.Repeat("x", 20)+"\nlast\n")
+		assert.NilError(t, err)
+
+		assert.Equal(t, readSpool(t, s), "last\n")
+	})
+
+	t.Run("NoNewline", func(t *testing.T) {
+		s, err := newSpool(4)
+		assert.NilError(t, err)
+		defer s.Close()
+
+		_, err = io.WriteString(s, "abcdefg")
+		assert.NilError(t, err)
+
+		assert.Equal(t, readSpool(t, s), "defg")
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		s, err := newSpool(0)
+		assert.NilError(t, err)
+
+		name := s.file.Name()
+		assert.NilError(t, s.Close())
+
+		_, err = os.Stat(name)
+		assert.Assert(t, os.IsNotExist(err), "file is removed")
+	})
+}
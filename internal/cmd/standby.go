@@ -0,0 +1,730 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/apis/postgres-operator.crunchydata.com/v1beta1"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+// standbyPath is where the standby is in the spec of a PostgresCluster.
+var standbyPath = []string{"spec", "standby"}
+
+// standbySourceAnnotation names the PostgresCluster that a standby was created
+// from so that 'pgo standby promote' can check it.
+const standbySourceAnnotation = "postgres-operator.crunchydata.com/standby-source"
+
+// newStandbyCommand returns the standby subcommand of the PGO plugin. Its
+// subcommands create, promote and inspect standby PostgresClusters.
+func newStandbyCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "standby",
+		Short: "Create, promote and inspect standby PostgresClusters",
+		Long: `Create, promote and inspect PostgresClusters that follow another PostgresCluster
+through "spec.standby", such as for disaster recovery.`,
+	}
+
+	cmd.AddCommand(
+		newStandbyCreateCommand(config),
+		newStandbyPromoteCommand(config),
+		newStandbyStatusCommand(config),
+	)
+
+	// No arguments for 'standby', but there are arguments for the subcommands.
+	cmd.Args = cobra.NoArgs
+
+	return cmd
+}
+
+func newStandbyCreateCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create SOURCE_CLUSTER --name=STANDBY_CLUSTER",
+		Short: "Create a standby of a PostgresCluster",
+		Long: `Create a PostgresCluster that is a standby of another. It has the spec of the
+source without its data source, shutdown and restore; "spec.standby" replays
+WAL from a pgBackRest repository of the source, from a host that streams it, or
+both.
+
+The repository must be one the standby can read, such as one in cloud storage.
+Streaming needs the custom TLS certificates of the source, which the standby
+shares; see 'pgo tls set'.
+
+The standby keeps the repositories of the source. Those in cloud storage are
+shared: both clusters write to the same pgBackRest stanza once the standby is
+promoted, so shut down the source before promoting the standby.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Create a standby of the 'hippo' postgrescluster that replays WAL from repo2
+pgo standby create hippo --name=hippo-dr --repoName=repo2
+
+# Create a standby that streams WAL from the primary of 'hippo'
+pgo standby create hippo --name=hippo-dr --host=hippo-primary.postgres-operator.svc
+`)
+
+	standby := standbyCluster{Config: config}
+
+	cmd.Flags().StringVar(&standby.Name, "name", "", "name of the standby PostgresCluster")
+	cobra.CheckErr(cmd.MarkFlagRequired("name"))
+	cmd.Flags().StringVar(&standby.RepoName, "repoName", "",
+		"pgBackRest repository of the source to replay WAL from")
+	cmd.Flags().StringVar(&standby.Host, "host", "", "host to stream WAL from")
+	cmd.Flags().Int32Var(&standby.Port, "port", 0, "port to stream WAL from; the default is 5432")
+
+	// Only one positional argument: the source PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		standby.PostgresCluster = args[0]
+		if standby.RepoName == "" && standby.Host == "" {
+			return errors.New("a standby needs --repoName, --host or both")
+		}
+		if standby.Port != 0 && standby.Host == "" {
+			return errors.New("--port needs --host")
+		}
+		return standby.Create(context.Background())
+	}
+
+	return cmd
+}
+
+func newStandbyPromoteCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "promote STANDBY_CLUSTER",
+		Short: "Promote a standby PostgresCluster",
+		Long: `Promote a standby PostgresCluster so that it accepts writes. Promotion waits
+until the standby leader has replayed all the WAL it received or, without
+streaming, the latest WAL archived in its repository.
+
+Promotion is refused until the source is shut down through "spec.shutdown" so
+that only one of them accepts writes and backs up to the repositories they
+share. With --force, the standby is promoted without checking its source or
+waiting for its WAL.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]
+    pods                                                [list]
+    pods/exec                                           [create]
+
+###### Note: The pods permissions are not required with --force.`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Shut down the 'hippo' source, then promote the 'hippo-dr' postgrescluster after it replays its WAL
+kubectl patch postgrescluster hippo --type=merge --patch='{"spec":{"shutdown":true}}'
+pgo standby promote hippo-dr
+
+# Promote it now, without waiting
+pgo standby promote hippo-dr --force
+`)
+
+	standby := standbyCluster{Config: config, Interval: 2 * time.Second}
+
+	cmd.Flags().BoolVar(&standby.Force, "force", false,
+		"promote without checking the source or waiting for WAL to be replayed")
+	cmd.Flags().DurationVar(&standby.Timeout, "timeout", time.Minute,
+		"how long to wait for WAL to be replayed")
+
+	// Only one positional argument: the standby PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		standby.PostgresCluster = args[0]
+		return standby.Promote(context.Background())
+	}
+
+	return cmd
+}
+
+func newStandbyStatusCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status STANDBY_CLUSTER",
+		Short: "Show the replication status of a standby PostgresCluster",
+		Long: `Show the WAL positions and replay lag of the standby leader of a PostgresCluster
+and whether it reaches its source: the WAL receiver when it streams and the
+pgBackRest repository when it replays from one.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get]
+    pods                                                [list]
+    pods/exec                                           [create]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Show the status of the 'hippo-dr' standby postgrescluster
+pgo standby status hippo-dr
+`)
+
+	standby := standbyCluster{Config: config}
+
+	// Only one positional argument: the standby PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		standby.PostgresCluster = args[0]
+		return standby.Status(context.Background())
+	}
+
+	return cmd
+}
+
+type standbyCluster struct {
+	*internal.Config
+
+	Name     string
+	RepoName string
+	Host     string
+	Port     int32
+
+	Force   bool
+	Timeout time.Duration
+
+	// Interval is the time between checks of the standby leader.
+	Interval time.Duration
+
+	PostgresCluster string
+}
+
+// standbyReplication is the status of Postgres in the standby leader as
+// returned by [Executor.standbyStatus].
+type standbyReplication struct {
+	InRecovery      bool       `json:"in_recovery"`
+	ReceiveLSN      string     `json:"receive_lsn"`
+	ReplayLSN       string     `json:"replay_lsn"`
+	ReplayTimestamp *time.Time `json:"replay_timestamp"`
+	ReplayLagBytes  *float64   `json:"replay_lag_bytes"`
+	ReceiverStatus  string     `json:"receiver_status"`
+	SenderHost      string     `json:"sender_host"`
+	SenderPort      *int64     `json:"sender_port"`
+}
+
+// standbyReport is everything 'pgo standby status' prints.
+type standbyReport struct {
+	Leader string
+	Spec   map[string]interface{}
+	Status standbyReplication
+
+	// Repo is the status of the repository and Archived is the latest WAL
+	// in it, when the standby replays from a repository.
+	Repo, Archived string
+}
+
+// Create applies a standby of the source PostgresCluster. The standby is
+// applied rather than created so that 'pgo standby promote' can change it.
+func (config standbyCluster) Create(ctx context.Context) error {
+	mapping, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	source, err := client.Namespace(namespace).Get(ctx,
+		config.PostgresCluster, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Namespace(namespace).Get(ctx, config.Name, metav1.GetOptions{})
+	if err == nil {
+		return fmt.Errorf("%s/%s already exists", mapping.Resource.Resource, config.Name)
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	standby, warnings, err := config.standbyFrom(source, namespace)
+	if err != nil {
+		return err
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(config.ErrOut, "Warning: %s\n", warning)
+	}
+
+	patch, err := standby.MarshalJSON()
+	if err == nil {
+		_, err = client.Namespace(namespace).Patch(ctx,
+			config.Name, types.ApplyPatchType, patch,
+			config.Patch.PatchOptions(metav1.PatchOptions{}))
+	}
+
+	if err == nil {
+		fmt.Fprintf(config.Out, "%s/%s created as a standby of %s\n",
+			mapping.Resource.Resource, config.Name, config.PostgresCluster)
+	}
+
+	return err
+}
+
+// standbyFrom returns a PostgresCluster named config.Name in namespace with the
+// spec of source and a standby section. It returns warnings about the standby
+// that do not prevent its creation.
+func (config standbyCluster) standbyFrom(
+	source *unstructured.Unstructured, namespace string,
+) (*unstructured.Unstructured, []string, error) {
+	var warnings []string
+
+	spec, _, _ := unstructured.NestedMap(source.Object, "spec")
+	if spec == nil {
+		spec = map[string]interface{}{}
+	}
+
+	// These belong to the source or to one moment in its life.
+	for _, path := range [][]string{
+		{"dataSource"}, {"standby"}, {"shutdown"},
+		{"backups", "pgbackrest", "manual"}, {"backups", "pgbackrest", "restore"},
+		{"service", "nodePort"}, {"proxy", "pgBouncer", "service", "nodePort"},
+	} {
+		unstructured.RemoveNestedField(spec, path...)
+	}
+
+	standby := map[string]interface{}{"enabled": true}
+
+	// Repositories in cloud storage are shared rather than copied.
+	repos, _, _ := unstructured.NestedSlice(spec, "backups", "pgbackrest", "repos")
+	for i := range repos {
+		if r, ok := repos[i].(map[string]interface{}); ok && r["volume"] == nil {
+			warnings = append(warnings, fmt.Sprintf(
+				"repository %q of %s is shared with the standby; shut down %s before promoting the standby so that only one of them writes to it",
+				r["name"], config.PostgresCluster, config.PostgresCluster))
+		}
+	}
+
+	if config.RepoName != "" {
+		var repo map[string]interface{}
+		for i := range repos {
+			if r, ok := repos[i].(map[string]interface{}); ok && r["name"] == config.RepoName {
+				repo = r
+			}
+		}
+		if repo == nil {
+			return nil, nil, fmt.Errorf("repository %q is not in %s", config.RepoName, config.PostgresCluster)
+		}
+		if _, ok := repo["volume"]; ok {
+			if config.Host == "" {
+				return nil, nil, fmt.Errorf(
+					"repository %q of %s is a volume that a standby cannot read; use a cloud repository or --host",
+					config.RepoName, config.PostgresCluster)
+			}
+			warnings = append(warnings, fmt.Sprintf(
+				"repository %q of %s is a volume; the standby has its own and streams from %s",
+				config.RepoName, config.PostgresCluster, config.Host))
+		} else {
+			standby["repoName"] = config.RepoName
+		}
+	}
+
+	if config.Host != "" {
+		standby["host"] = config.Host
+		if config.Port != 0 {
+			standby["port"] = int64(config.Port)
+		}
+
+		if _, ok := spec["customTLSSecret"]; !ok {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s has no custom TLS certificates; streaming needs certificates shared by both clusters, see 'pgo tls set'",
+				config.PostgresCluster))
+		} else if _, ok := spec["customReplicationTLSSecret"]; !ok {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s has no custom replication certificate; streaming needs one shared by both clusters, see 'pgo tls set --replication'",
+				config.PostgresCluster))
+		}
+	}
+
+	spec["standby"] = standby
+
+	cluster := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	cluster.SetAPIVersion(source.GetAPIVersion())
+	cluster.SetKind(source.GetKind())
+	cluster.SetName(config.Name)
+	cluster.SetNamespace(namespace)
+	cluster.SetAnnotations(map[string]string{standbySourceAnnotation: source.GetName()})
+
+	return cluster, warnings, nil
+}
+
+// Promote disables the standby of a PostgresCluster, after its source is shut
+// down and its standby leader replays its WAL unless config.Force is set.
+func (config standbyCluster) Promote(ctx context.Context) error {
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	return applyClusterIntent(ctx, config.Config, config.PostgresCluster, "promoted",
+		func(cluster, intent *unstructured.Unstructured) error {
+			if err := config.promoteIntent(cluster, intent); err != nil || config.Force {
+				return err
+			}
+			if err := config.checkSource(ctx, namespace, cluster); err != nil {
+				return err
+			}
+			return config.waitForReplay(ctx, namespace, cluster)
+		})
+}
+
+// promoteIntent disables the standby in intent. A standby that was not
+// created through this field manager cannot be promoted by it.
+func (config standbyCluster) promoteIntent(cluster, intent *unstructured.Unstructured) error {
+	if enabled, _, _ := unstructured.NestedBool(cluster.Object, append(standbyPath, "enabled")...); !enabled {
+		return fmt.Errorf("%s is not a standby", config.PostgresCluster)
+	}
+	if _, found, _ := unstructured.NestedMap(intent.Object, standbyPath...); !found {
+		return errors.New("the standby is managed by another field manager; promote it with the tool that created it")
+	}
+
+	return unstructured.SetNestedField(intent.Object, false, append(standbyPath, "enabled")...)
+}
+
+// checkSource returns an error unless the PostgresCluster that cluster was
+// created from is shut down or gone.
+func (config standbyCluster) checkSource(
+	ctx context.Context, namespace string, cluster *unstructured.Unstructured,
+) error {
+	name := cluster.GetAnnotations()[standbySourceAnnotation]
+	if name == "" {
+		return fmt.Errorf("the source of %s is unknown; shut it down and promote with --force",
+			config.PostgresCluster)
+	}
+
+	_, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	source, err := client.Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return sourceShutdown(source)
+}
+
+// sourceShutdown returns an error unless source is shut down.
+func sourceShutdown(source *unstructured.Unstructured) error {
+	if shutdown, _, _ := unstructured.NestedBool(source.Object, "spec", "shutdown"); !shutdown {
+		return fmt.Errorf(
+			"%s is not shut down; shut it down so that only one of them accepts writes, or promote with --force",
+			source.GetName())
+	}
+	return nil
+}
+
+// waitForReplay checks the standby leader of cluster until it has replayed
+// its WAL or the timeout passes.
+func (config standbyCluster) waitForReplay(
+	ctx context.Context, namespace string, cluster *unstructured.Unstructured,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	var report standbyReport
+	var last error
+	err := wait.PollImmediateUntilWithContext(ctx, config.Interval, func(ctx context.Context) (bool, error) {
+		var err error
+		report, err = config.report(ctx, namespace, cluster)
+		if err == nil {
+			err = report.replayed()
+		}
+		last = err
+		return err == nil, nil
+	})
+	if err != nil && last != nil {
+		return fmt.Errorf("%w: %v; promote anyway with --force", err, last)
+	}
+	if err == nil {
+		fmt.Fprintf(config.Out, "pod/%s replayed WAL to %s\n", report.Leader, report.Status.ReplayLSN)
+	}
+	return err
+}
+
+// Status prints the replication status of the standby leader of a
+// PostgresCluster.
+func (config standbyCluster) Status(ctx context.Context) error {
+	_, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	cluster, err := client.Namespace(namespace).Get(ctx,
+		config.PostgresCluster, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if _, found, _ := unstructured.NestedMap(cluster.Object, standbyPath...); !found {
+		return fmt.Errorf("%s is not a standby", config.PostgresCluster)
+	}
+
+	report, err := config.report(ctx, namespace, cluster)
+	if err != nil {
+		return err
+	}
+	return writeStandbyReport(config.Out, report, time.Now())
+}
+
+// report finds the standby leader of cluster and returns its status.
+func (config standbyCluster) report(
+	ctx context.Context, namespace string, cluster *unstructured.Unstructured,
+) (standbyReport, error) {
+	var report standbyReport
+	report.Spec, _, _ = unstructured.NestedMap(cluster.Object, standbyPath...)
+
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return report, err
+	}
+	core, err := corev1client.NewForConfig(rest)
+	if err != nil {
+		return report, err
+	}
+	podExec, err := util.NewPodExecutor(rest)
+	if err != nil {
+		return report, err
+	}
+
+	pods, err := core.Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: util.InstanceLabels(config.PostgresCluster),
+	})
+	if err != nil {
+		return report, err
+	}
+	if len(pods.Items) == 0 {
+		return report, fmt.Errorf("no instance Pods found for %s", config.PostgresCluster)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Name < pods.Items[j].Name
+	})
+
+	in := func(pod string) Executor {
+		return func(stdin io.Reader, stdout, stderr io.Writer, command ...string) error {
+			return podExec(namespace, pod, util.ContainerDatabase,
+				stdin, stdout, stderr, command...)
+		}
+	}
+
+	// Patroni calls the leader of a standby the "Standby Leader".
+	stdout, stderr, err := in(pods.Items[0].Name).patronictl("list --format json")
+	if err != nil {
+		return report, fmt.Errorf("patronictl list: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	members, err := parsePatroniMembers(stdout)
+	if err != nil {
+		return report, err
+	}
+	for _, member := range members {
+		if member.leader() {
+			report.Leader = member.Member
+		}
+	}
+	if report.Leader == "" {
+		return report, fmt.Errorf("%s has no leader", config.PostgresCluster)
+	}
+
+	exec := in(report.Leader)
+	stdout, stderr, err = exec.standbyStatus()
+	if err != nil {
+		return report, fmt.Errorf("pod/%s: %w: %s", report.Leader, err, strings.TrimSpace(stderr))
+	}
+	if report.Status, err = parseStandbyReplication(stdout); err != nil {
+		return report, fmt.Errorf("pod/%s: %w", report.Leader, err)
+	}
+
+	if repo, _ := report.Spec["repoName"].(string); repo != "" {
+		stdout, stderr, err = exec.pgBackRestInfo("json", strings.TrimPrefix(repo, "repo"))
+		if err == nil {
+			report.Repo, report.Archived, err = parseRepoArchive(stdout)
+		}
+		if err != nil {
+			report.Repo = strings.TrimSpace(fmt.Sprintf("%v: %s", err, strings.TrimSpace(stderr)))
+		}
+	}
+
+	return report, nil
+}
+
+// parseStandbyReplication parses the output of [Executor.standbyStatus].
+func parseStandbyReplication(stdout string) (standbyReplication, error) {
+	var status standbyReplication
+	if err := json.Unmarshal([]byte(strings.TrimSpace(stdout)), &status); err != nil {
+		return status, fmt.Errorf("unexpected psql output: %w", err)
+	}
+	return status, nil
+}
+
+// parseRepoArchive returns the status and latest archived WAL of the one
+// repository in the JSON output of [Executor.pgBackRestInfo].
+func parseRepoArchive(stdout string) (string, string, error) {
+	var stanzas []struct {
+		Archive []struct {
+			Max string `json:"max"`
+		} `json:"archive"`
+		Status struct {
+			Message string `json:"message"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &stanzas); err != nil {
+		return "", "", fmt.Errorf("unexpected pgbackrest output: %w", err)
+	}
+	if len(stanzas) == 0 {
+		return "", "", errors.New("no stanza in the repository")
+	}
+
+	var max string
+	for _, archive := range stanzas[0].Archive {
+		if archive.Max > max {
+			max = archive.Max
+		}
+	}
+	return stanzas[0].Status.Message, max, nil
+}
+
+// walSegment returns the log and segment parts of the name of the 16MiB WAL
+// file that contains lsn, without its timeline.
+func walSegment(lsn string) (string, error) {
+	high, low, found := strings.Cut(lsn, "/")
+	if !found {
+		return "", fmt.Errorf("invalid WAL position %q", lsn)
+	}
+	h, err := strconv.ParseUint(high, 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid WAL position %q", lsn)
+	}
+	l, err := strconv.ParseUint(low, 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid WAL position %q", lsn)
+	}
+	return fmt.Sprintf("%08X%08X", h, l>>24), nil
+}
+
+// replayed returns nil when the standby leader has replayed all the WAL it
+// received or, without streaming, the latest WAL in its repository.
+func (report standbyReport) replayed() error {
+	status := report.Status
+	switch {
+	case !status.InRecovery:
+		return nil
+	case status.ReplayLSN == "":
+		return errors.New("no WAL replayed yet")
+	case status.ReceiveLSN != "":
+		if status.ReplayLagBytes != nil && *status.ReplayLagBytes > 0 {
+			return fmt.Errorf("%.0f bytes of WAL not yet replayed", *status.ReplayLagBytes)
+		}
+		return nil
+	case report.Archived != "":
+		segment, err := walSegment(status.ReplayLSN)
+		if err != nil {
+			return err
+		}
+		if len(report.Archived) == 24 && segment < report.Archived[8:] {
+			return fmt.Errorf("replayed WAL %s is before the latest archived WAL %s",
+				status.ReplayLSN, report.Archived)
+		}
+		return nil
+	default:
+		return errors.New("the standby neither streams nor replays from a repository")
+	}
+}
+
+// writeStandbyReport prints report as a list of names and values.
+func writeStandbyReport(out io.Writer, report standbyReport, now time.Time) error {
+	status := report.Status
+	none := func(s string) string {
+		if s == "" {
+			return "<none>"
+		}
+		return s
+	}
+
+	recovery := "in recovery"
+	if !status.InRecovery {
+		recovery = "not in recovery; the standby is promoted"
+	}
+
+	var lag []string
+	if status.ReplayLagBytes != nil {
+		lag = append(lag, fmt.Sprintf("%.0f bytes", *status.ReplayLagBytes))
+	}
+	if status.ReplayTimestamp != nil {
+		lag = append(lag, fmt.Sprintf("last transaction replayed %s ago",
+			now.Sub(*status.ReplayTimestamp).Truncate(time.Second)))
+	}
+	if len(lag) == 0 {
+		lag = []string{"<none>"}
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Standby leader:\tpod/%s\n", report.Leader)
+	fmt.Fprintf(w, "Recovery:\t%s\n", recovery)
+	fmt.Fprintf(w, "Received WAL:\t%s\n", none(status.ReceiveLSN))
+	fmt.Fprintf(w, "Replayed WAL:\t%s\n", none(status.ReplayLSN))
+	fmt.Fprintf(w, "Replay lag:\t%s\n", strings.Join(lag, "; "))
+
+	if host, _ := report.Spec["host"].(string); host != "" {
+		port, ok := report.Spec["port"].(int64)
+		if !ok {
+			port = 5432
+		}
+		connected := "not connected"
+		if status.ReceiverStatus != "" {
+			connected = status.ReceiverStatus
+		}
+		fmt.Fprintf(w, "Source host:\t%s:%d (%s)\n", host, port, connected)
+	}
+	if repo, _ := report.Spec["repoName"].(string); repo != "" {
+		fmt.Fprintf(w, "Source repo:\t%s (%s; latest archived WAL %s)\n",
+			repo, none(report.Repo), none(report.Archived))
+	}
+
+	return w.Flush()
+}
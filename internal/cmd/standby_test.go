@@ -0,0 +1,279 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crunchydata/postgres-operator-client/internal/testing/cmp"
+)
+
+func TestStandbyFrom(t *testing.T) {
+	unmarshal := func(t *testing.T, doc string) *unstructured.Unstructured {
+		t.Helper()
+		object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		assert.NilError(t, yaml.Unmarshal([]byte(strings.TrimSpace(doc)), &object.Object))
+		return object
+	}
+
+	source := unmarshal(t, `
+apiVersion: postgres-operator.crunchydata.com/v1beta1
+kind: PostgresCluster
+metadata:
+  name: hippo
+  namespace: postgres-operator
+  uid: abc
+spec:
+  postgresVersion: 14
+  shutdown: false
+  dataSource:
+    postgresCluster: { clusterName: elephant, repoName: repo1 }
+  service: { type: NodePort, nodePort: 32000 }
+  backups:
+    pgbackrest:
+      manual: { repoName: repo1 }
+      repos:
+      - name: repo1
+        volume: { volumeClaimSpec: {} }
+      - name: repo2
+        s3: { bucket: hippo, endpoint: s3.example.com, region: us-east-1 }
+	`)
+
+	t.Run("Repository", func(t *testing.T) {
+		standby := standbyCluster{Name: "hippo-dr", RepoName: "repo2", PostgresCluster: "hippo"}
+		cluster, warnings, err := standby.standbyFrom(source, "postgres-operator")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, warnings, []string{
+			`repository "repo2" of hippo is shared with the standby; shut down hippo before promoting the standby so that only one of them writes to it`,
+		})
+		assert.Assert(t, cmp.MarshalMatches(cluster.Object, `
+apiVersion: postgres-operator.crunchydata.com/v1beta1
+kind: PostgresCluster
+metadata:
+  annotations:
+    postgres-operator.crunchydata.com/standby-source: hippo
+  name: hippo-dr
+  namespace: postgres-operator
+spec:
+  backups:
+    pgbackrest:
+      repos:
+      - name: repo1
+        volume:
+          volumeClaimSpec: {}
+      - name: repo2
+        s3:
+          bucket: hippo
+          endpoint: s3.example.com
+          region: us-east-1
+  postgresVersion: 14
+  service:
+    type: NodePort
+  standby:
+    enabled: true
+    repoName: repo2
+		`))
+
+		// The source is unchanged.
+		manual, _, _ := unstructured.NestedString(source.Object, "spec", "backups", "pgbackrest", "manual", "repoName")
+		assert.Equal(t, manual, "repo1")
+	})
+
+	t.Run("Host", func(t *testing.T) {
+		standby := standbyCluster{
+			Name: "hippo-dr", RepoName: "repo1", Host: "hippo-primary.postgres-operator.svc", Port: 5433,
+			PostgresCluster: "hippo",
+		}
+		cluster, warnings, err := standby.standbyFrom(source, "postgres-operator")
+		assert.NilError(t, err)
+		assert.DeepEqual(t, warnings, []string{
+			`repository "repo2" of hippo is shared with the standby; shut down hippo before promoting the standby so that only one of them writes to it`,
+			`repository "repo1" of hippo is a volume; the standby has its own and streams from hippo-primary.postgres-operator.svc`,
+			`hippo has no custom TLS certificates; streaming needs certificates shared by both clusters, see 'pgo tls set'`,
+		})
+
+		spec, _, _ := unstructured.NestedMap(cluster.Object, standbyPath...)
+		assert.DeepEqual(t, spec, map[string]interface{}{
+			"enabled": true, "host": "hippo-primary.postgres-operator.svc", "port": int64(5433),
+		})
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, _, err := standbyCluster{Name: "hippo-dr", RepoName: "repo1", PostgresCluster: "hippo"}.
+			standbyFrom(source, "postgres-operator")
+		assert.ErrorContains(t, err, `repository "repo1" of hippo is a volume that a standby cannot read`)
+
+		_, _, err = standbyCluster{Name: "hippo-dr", RepoName: "repo3", PostgresCluster: "hippo"}.
+			standbyFrom(source, "postgres-operator")
+		assert.ErrorContains(t, err, `repository "repo3" is not in hippo`)
+	})
+}
+
+func TestStandbyPromoteIntent(t *testing.T) {
+	unmarshal := func(t *testing.T, doc string) *unstructured.Unstructured {
+		t.Helper()
+		object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		assert.NilError(t, yaml.Unmarshal([]byte(strings.TrimSpace(doc)), &object.Object))
+		return object
+	}
+
+	cluster := unmarshal(t, `{ spec: { standby: { enabled: true, repoName: repo2 } } }`)
+	intent := unmarshal(t, `{ spec: { postgresVersion: 14, standby: { enabled: true, repoName: repo2 } } }`)
+
+	standby := standbyCluster{PostgresCluster: "hippo-dr"}
+	assert.NilError(t, standby.promoteIntent(cluster, intent))
+	assert.Assert(t, cmp.MarshalMatches(intent.Object, `
+spec:
+  postgresVersion: 14
+  standby:
+    enabled: false
+    repoName: repo2
+	`))
+
+	assert.ErrorContains(t, standby.promoteIntent(cluster, unmarshal(t, `{}`)),
+		"the standby is managed by another field manager")
+	assert.ErrorContains(t, standby.promoteIntent(intent, intent),
+		"hippo-dr is not a standby")
+}
+
+func TestStandbySourceShutdown(t *testing.T) {
+	source := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "hippo"},
+		"spec":     map[string]interface{}{"postgresVersion": int64(14)},
+	}}
+	assert.ErrorContains(t, sourceShutdown(source),
+		"hippo is not shut down; shut it down so that only one of them accepts writes, or promote with --force")
+
+	assert.NilError(t, unstructured.SetNestedField(source.Object, false, "spec", "shutdown"))
+	assert.ErrorContains(t, sourceShutdown(source), "hippo is not shut down")
+
+	assert.NilError(t, unstructured.SetNestedField(source.Object, true, "spec", "shutdown"))
+	assert.NilError(t, sourceShutdown(source))
+}
+
+func TestStandbyReplayed(t *testing.T) {
+	status, err := parseStandbyReplication(`{"in_recovery" : true, "receive_lsn" : "0/5000060", ` +
+		`"replay_lsn" : "0/5000000", "replay_timestamp" : "2023-03-01T10:00:00.5+00:00", ` +
+		`"replay_lag_bytes" : 96, "receiver_status" : "streaming", ` +
+		`"sender_host" : "hippo-primary.postgres-operator.svc", "sender_port" : 5432}` + "\n")
+	assert.NilError(t, err)
+	assert.Equal(t, status.ReplayTimestamp.Unix(), time.Date(2023, time.March, 1, 10, 0, 0, 0, time.UTC).Unix())
+
+	report := standbyReport{Status: status}
+	assert.ErrorContains(t, report.replayed(), "96 bytes of WAL not yet replayed")
+
+	lag := float64(0)
+	report.Status.ReplayLagBytes = &lag
+	assert.NilError(t, report.replayed())
+
+	// Without streaming, replay is compared to the repository.
+	report.Status.ReceiveLSN, report.Status.ReplayLagBytes = "", nil
+	report.Archived = "000000010000000000000006"
+	assert.ErrorContains(t, report.replayed(),
+		"replayed WAL 0/5000000 is before the latest archived WAL 000000010000000000000006")
+
+	report.Archived = "000000010000000000000005"
+	assert.NilError(t, report.replayed())
+
+	report.Status.InRecovery = false
+	report.Archived = "000000010000000000000009"
+	assert.NilError(t, report.replayed())
+
+	_, err = parseStandbyReplication("ERROR: nope")
+	assert.ErrorContains(t, err, "unexpected psql output")
+}
+
+func TestWALSegment(t *testing.T) {
+	for lsn, expected := range map[string]string{
+		"0/0":        "0000000000000000",
+		"0/5000060":  "0000000000000005",
+		"1/FF000000": "00000001000000FF",
+		"A/1C2D3E4F": "0000000A0000001C",
+	} {
+		segment, err := walSegment(lsn)
+		assert.NilError(t, err)
+		assert.Equal(t, segment, expected, "lsn %q", lsn)
+	}
+
+	_, err := walSegment("nope")
+	assert.ErrorContains(t, err, `invalid WAL position "nope"`)
+}
+
+func TestParseRepoArchive(t *testing.T) {
+	status, max, err := parseRepoArchive(`[{"archive":[` +
+		`{"database":{"id":1,"repo-key":2},"id":"13-1","max":"000000010000000000000003","min":"000000010000000000000001"},` +
+		`{"database":{"id":2,"repo-key":2},"id":"14-2","max":"000000020000000000000007","min":"000000020000000000000004"}],` +
+		`"name":"db","status":{"code":0,"message":"ok"}}]`)
+	assert.NilError(t, err)
+	assert.Equal(t, status, "ok")
+	assert.Equal(t, max, "000000020000000000000007")
+
+	_, _, err = parseRepoArchive(`[]`)
+	assert.ErrorContains(t, err, "no stanza in the repository")
+
+	_, _, err = parseRepoArchive(`nope`)
+	assert.ErrorContains(t, err, "unexpected pgbackrest output")
+}
+
+func TestWriteStandbyReport(t *testing.T) {
+	replayed := time.Date(2023, time.March, 1, 10, 0, 0, 0, time.UTC)
+	lag := float64(96)
+
+	var out bytes.Buffer
+	assert.NilError(t, writeStandbyReport(&out, standbyReport{
+		Leader: "hippo-dr-instance1-abcd-0",
+		Spec: map[string]interface{}{
+			"enabled": true, "repoName": "repo2", "host": "hippo-primary.postgres-operator.svc",
+		},
+		Status: standbyReplication{
+			InRecovery: true, ReceiveLSN: "0/5000060", ReplayLSN: "0/5000000",
+			ReplayTimestamp: &replayed, ReplayLagBytes: &lag, ReceiverStatus: "streaming",
+		},
+		Repo: "ok", Archived: "000000010000000000000005",
+	}, replayed.Add(12*time.Second+500*time.Millisecond)))
+
+	assert.Equal(t, out.String(), strings.TrimLeft(`
+Standby leader:  pod/hippo-dr-instance1-abcd-0
+Recovery:        in recovery
+Received WAL:    0/5000060
+Replayed WAL:    0/5000000
+Replay lag:      96 bytes; last transaction replayed 12s ago
+Source host:     hippo-primary.postgres-operator.svc:5432 (streaming)
+Source repo:     repo2 (ok; latest archived WAL 000000010000000000000005)
+`, "\n"))
+
+	out.Reset()
+	assert.NilError(t, writeStandbyReport(&out, standbyReport{
+		Leader: "hippo-dr-instance1-abcd-0",
+		Spec:   map[string]interface{}{"enabled": true, "host": "10.0.0.7", "port": int64(5433)},
+		Status: standbyReplication{InRecovery: true, ReplayLSN: "0/3000000"},
+	}, replayed))
+
+	assert.Equal(t, out.String(), strings.TrimLeft(`
+Standby leader:  pod/hippo-dr-instance1-abcd-0
+Recovery:        in recovery
+Received WAL:    <none>
+Replayed WAL:    0/3000000
+Replay lag:      <none>
+Source host:     10.0.0.7:5433 (not connected)
+`, "\n"))
+}
@@ -29,6 +29,7 @@ func newSupportCommand(config *internal.Config) *cobra.Command {
 	}
 
 	cmd.AddCommand(newSupportExportCommand(config))
+	cmd.AddCommand(newSupportInspectCommand())
 
 	return cmd
 }
@@ -0,0 +1,617 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/apis/postgres-operator.crunchydata.com/v1beta1"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+const (
+	// exitExpiring is the exit code of 'pgo tls show --warn-days' when a
+	// certificate expires within those days. Other failures exit with 1.
+	exitExpiring = 2
+
+	// replicationCommonName is the user that replicas connect as. PGO
+	// requires it in the common name of the replication certificate.
+	replicationCommonName = "_crunchyrepl"
+)
+
+// newTLSCommand returns the tls subcommand of the PGO plugin. Its subcommands
+// inspect and replace the certificates of a PostgresCluster.
+func newTLSCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tls",
+		Short: "Inspect and replace the TLS certificates of a PostgresCluster",
+		Long: `Inspect and replace the TLS certificates of a PostgresCluster. PGO generates
+them unless "spec.customTLSSecret" and "spec.customReplicationTLSSecret" are set.`,
+	}
+
+	cmd.AddCommand(
+		newTLSSetCommand(config),
+		newTLSShowCommand(config),
+	)
+
+	// No arguments for 'tls', but there are arguments for the subcommands.
+	cmd.Args = cobra.NoArgs
+
+	return cmd
+}
+
+func newTLSShowCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show CLUSTER_NAME",
+		Short: "Show the TLS certificates of a PostgresCluster",
+		Long: `Show the subject, alternative names, issuer and expiry of the server and
+replication certificates of a PostgresCluster and of their certificate
+authorities.
+
+With --warn-days, the command exits with code 2 when any certificate expires
+within that many days.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get]
+    secrets                                             [get]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Show the certificates of the 'hippo' postgrescluster
+pgo tls show hippo
+
+# Fail when a certificate expires within 30 days
+pgo tls show hippo --warn-days=30
+`)
+
+	show := clusterTLS{Config: config}
+
+	cmd.Flags().IntVar(&show.WarnDays, "warn-days", 0,
+		"exit with code 2 when a certificate expires within this many days")
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		show.PostgresCluster = args[0]
+		return show.Show(context.Background())
+	}
+
+	return cmd
+}
+
+func newTLSSetCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set CLUSTER_NAME --cert-file=FILE --key-file=FILE --ca-file=FILE",
+		Short: "Use your own TLS certificate in a PostgresCluster",
+		Long: `Store a certificate, its key and its certificate authority in a Secret and
+use it as the server certificate of a PostgresCluster. With --replication, it
+is used as the replication certificate instead and must have the common name
+"_crunchyrepl".
+
+The certificate must match its key and be signed by the certificate authority;
+intermediate certificates can follow it in its file. The server and replication
+certificates must be signed by the same certificate authority; when the other
+one is custom, the certificate is checked against its certificate authority.
+The Secret is written only after the PostgresCluster is changed, so a
+certificate that is refused leaves the Secret as it was.
+
+PGO uses a custom replication certificate only with a custom server
+certificate, so set the replication certificate first. A server certificate
+is refused while the replication certificate is generated by PGO because
+replicas could not authenticate.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]
+    secrets                                             [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Use your own replication certificate, stored in a Secret of your choice
+pgo tls set hippo --replication --secret=hippo-repl-tls \
+  --cert-file=repl.crt --key-file=repl.key --ca-file=ca.crt
+
+# Then use your own server certificate in the 'hippo' postgrescluster
+pgo tls set hippo --cert-file=server.crt --key-file=server.key --ca-file=ca.crt
+`)
+
+	set := clusterTLS{Config: config}
+
+	cmd.Flags().StringVar(&set.CertFile, "cert-file", "", "path to the PEM certificate")
+	cmd.Flags().StringVar(&set.KeyFile, "key-file", "", "path to the PEM private key")
+	cmd.Flags().StringVar(&set.CAFile, "ca-file", "", "path to the PEM certificate authority")
+	cmd.Flags().BoolVar(&set.Replication, "replication", false,
+		"set the replication certificate rather than the server certificate")
+	cmd.Flags().StringVar(&set.Secret, "secret", "",
+		"name of the Secret to store the certificate in (default CLUSTER_NAME-custom-tls or CLUSTER_NAME-custom-replication-tls)")
+	for _, name := range []string{"cert-file", "key-file", "ca-file"} {
+		cobra.CheckErr(cmd.MarkFlagRequired(name))
+	}
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		set.PostgresCluster = args[0]
+		return set.Set(context.Background())
+	}
+
+	return cmd
+}
+
+type clusterTLS struct {
+	*internal.Config
+
+	CAFile      string
+	CertFile    string
+	KeyFile     string
+	Replication bool
+	Secret      string
+	WarnDays    int
+
+	PostgresCluster string
+}
+
+// tlsSecretRef is a Secret that holds a certificate of a PostgresCluster.
+type tlsSecretRef struct {
+	Purpose string
+	Name    string
+
+	// Items are the keys of the Secret by the file they are projected to.
+	Items map[string]string
+}
+
+// key returns the key of the Secret that is projected to path.
+func (ref tlsSecretRef) key(path string) string {
+	if key, ok := ref.Items[path]; ok {
+		return key
+	}
+	return path
+}
+
+// clusterTLSSecrets returns the Secrets of the server and replication
+// certificates of cluster, either custom or generated by PGO.
+func clusterTLSSecrets(cluster *unstructured.Unstructured) []tlsSecretRef {
+	refs := []tlsSecretRef{
+		{Purpose: "server", Name: cluster.GetName() + "-cluster-cert"},
+		{Purpose: "replication", Name: cluster.GetName() + "-replication-cert"},
+	}
+	for i, field := range []string{"customTLSSecret", "customReplicationTLSSecret"} {
+		name, found, _ := unstructured.NestedString(cluster.Object, "spec", field, "name")
+		if !found {
+			continue
+		}
+		refs[i].Name = name
+
+		items, _, _ := unstructured.NestedSlice(cluster.Object, "spec", field, "items")
+		for _, item := range items {
+			if item, ok := item.(map[string]interface{}); ok {
+				key, _ := item["key"].(string)
+				path, _ := item["path"].(string)
+				if refs[i].Items == nil {
+					refs[i].Items = map[string]string{}
+				}
+				refs[i].Items[path] = key
+			}
+		}
+	}
+	return refs
+}
+
+// certificateInfo describes one certificate in a Secret.
+type certificateInfo struct {
+	Secret   string
+	Key      string
+	Subject  string
+	SANs     []string
+	Issuer   string
+	NotAfter time.Time
+}
+
+// describeCertificates returns a description of every certificate in data,
+// which is the PEM value of key in secret.
+func describeCertificates(secret, key string, data []byte) ([]certificateInfo, error) {
+	certs, err := parseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s key %s: %w", secret, key, err)
+	}
+
+	infos := make([]certificateInfo, 0, len(certs))
+	for _, cert := range certs {
+		info := certificateInfo{
+			Secret: secret, Key: key, NotAfter: cert.NotAfter,
+			Subject: cert.Subject.String(), Issuer: cert.Issuer.String(),
+		}
+		info.SANs = append(info.SANs, cert.DNSNames...)
+		for _, ip := range cert.IPAddresses {
+			info.SANs = append(info.SANs, ip.String())
+		}
+		for _, uri := range cert.URIs {
+			info.SANs = append(info.SANs, uri.String())
+		}
+		info.SANs = append(info.SANs, cert.EmailAddresses...)
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// parseCertificates returns the certificates in the PEM data.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificates found")
+	}
+	return certs, nil
+}
+
+func (config clusterTLS) Show(ctx context.Context) error {
+	_, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	core, err := corev1client.NewForConfig(rest)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	cluster, err := client.Namespace(namespace).Get(ctx,
+		config.PostgresCluster, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	var infos []certificateInfo
+	for _, ref := range clusterTLSSecrets(cluster) {
+		secret, err := core.Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("%s certificate: %w", ref.Purpose, err)
+		}
+
+		for _, path := range []string{"tls.crt", "ca.crt"} {
+			key := ref.key(path)
+			described, err := describeCertificates(secret.Name, key, secret.Data[key])
+			if err != nil {
+				return fmt.Errorf("%s certificate: %w", ref.Purpose, err)
+			}
+			infos = append(infos, described...)
+		}
+	}
+
+	now := time.Now()
+	if err := writeCertificates(config.Out, infos, now); err != nil {
+		return err
+	}
+	return expiringCertificates(infos, now, config.WarnDays)
+}
+
+// writeCertificates prints infos as a table with the days until each expires.
+func writeCertificates(out io.Writer, infos []certificateInfo, now time.Time) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SECRET\tKEY\tSUBJECT\tSANS\tISSUER\tNOT AFTER\tDAYS LEFT")
+
+	for _, info := range infos {
+		sans := strings.Join(info.SANs, ",")
+		if sans == "" {
+			sans = "<none>"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", info.Secret, info.Key,
+			info.Subject, sans, info.Issuer, info.NotAfter.UTC().Format(time.RFC3339),
+			daysLeft(info.NotAfter, now))
+	}
+	return w.Flush()
+}
+
+// daysLeft returns the whole days from now until then; it is negative after
+// then.
+func daysLeft(then, now time.Time) int {
+	left := then.Sub(now)
+	if left < 0 {
+		return -int((-left).Hours() / 24)
+	}
+	return int(left.Hours() / 24)
+}
+
+// expiringCertificates returns an [ExitError] when any certificate in infos
+// expires within days of now. It returns nil when days is zero or less.
+func expiringCertificates(infos []certificateInfo, now time.Time, days int) error {
+	if days <= 0 {
+		return nil
+	}
+
+	deadline := now.Add(time.Duration(days) * 24 * time.Hour)
+	var expiring []string
+	for _, info := range infos {
+		if info.NotAfter.Before(deadline) {
+			expiring = append(expiring, fmt.Sprintf("%s in %s/%s", info.Subject, info.Secret, info.Key))
+		}
+	}
+	if len(expiring) == 0 {
+		return nil
+	}
+
+	return &ExitError{Code: exitExpiring, Err: fmt.Errorf(
+		"%d certificates expire within %d days: %s",
+		len(expiring), days, strings.Join(expiring, "; "))}
+}
+
+// validateTLSBundle returns an error when the PEM certificate and key do not
+// belong together, are not signed by the PEM certificate authority, or are not
+// valid at now. A replication certificate must have the replication common
+// name. It returns warnings about a server certificate that does not name
+// host or any certificate that expires within 30 days.
+func validateTLSBundle(certPEM, keyPEM, caPEM []byte, replication bool, host string, now time.Time) ([]string, error) {
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return nil, fmt.Errorf("certificate and key do not match: %w", err)
+	}
+
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("certificate: %w", err)
+	}
+	cas, err := parseCertificates(caPEM)
+	if err != nil {
+		return nil, fmt.Errorf("certificate authority: %w", err)
+	}
+
+	leaf := certs[0]
+	switch {
+	case now.Before(leaf.NotBefore):
+		return nil, fmt.Errorf("certificate is not valid until %s", leaf.NotBefore.UTC().Format(time.RFC3339))
+	case now.After(leaf.NotAfter):
+		return nil, fmt.Errorf("certificate expired on %s", leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+
+	if err := verifySignedBy(certs, cas, now); err != nil {
+		return nil, fmt.Errorf("certificate is not signed by the certificate authority: %w", err)
+	}
+
+	if replication && leaf.Subject.CommonName != replicationCommonName {
+		return nil, fmt.Errorf("replication certificate must have the common name %q, not %q",
+			replicationCommonName, leaf.Subject.CommonName)
+	}
+
+	var warnings []string
+	if !replication {
+		if err := leaf.VerifyHostname(host); err != nil {
+			warnings = append(warnings, fmt.Sprintf(
+				"certificate is not valid for %s; clients that verify the host name cannot connect", host))
+		}
+	}
+	if days := daysLeft(leaf.NotAfter, now); days < 30 {
+		warnings = append(warnings, fmt.Sprintf("certificate expires in %d days", days))
+	}
+	return warnings, nil
+}
+
+// tlsSecretPatch returns a server-side apply patch of a Secret named name
+// with the certificate, key and certificate authority.
+func tlsSecretPatch(namespace, name, cluster string, certPEM, keyPEM, caPEM []byte) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name": name, "namespace": namespace,
+			"labels": map[string]interface{}{util.LabelCluster: cluster},
+		},
+		"type": string(corev1.SecretTypeTLS),
+		"data": map[string]interface{}{
+			corev1.TLSCertKey:       certPEM,
+			corev1.TLSPrivateKeyKey: keyPEM,
+			"ca.crt":                caPEM,
+		},
+	})
+}
+
+// pairedTLSSecret returns the Secret of the other certificate of the pair
+// that a server or replication certificate belongs to, and whether it is
+// custom rather than generated by PGO.
+func pairedTLSSecret(cluster *unstructured.Unstructured, replication bool) (tlsSecretRef, bool) {
+	refs, field := clusterTLSSecrets(cluster), "customReplicationTLSSecret"
+	other := refs[1]
+	if replication {
+		other, field = refs[0], "customTLSSecret"
+	}
+	_, custom, _ := unstructured.NestedMap(cluster.Object, "spec", field)
+	return other, custom
+}
+
+// verifyTLSPair returns an error when the PEM certificate is not signed by
+// the PEM certificate authority of the other certificate of its pair.
+func verifyTLSPair(certPEM, otherCAPEM []byte, other string, now time.Time) error {
+	certs, err := parseCertificates(certPEM)
+	if err != nil {
+		return fmt.Errorf("certificate: %w", err)
+	}
+	cas, err := parseCertificates(otherCAPEM)
+	if err != nil {
+		return fmt.Errorf("certificate authority in secrets/%s: %w", other, err)
+	}
+	if err := verifySignedBy(certs, cas, now); err != nil {
+		return fmt.Errorf(
+			"certificate is not signed by the certificate authority in secrets/%s; "+
+				"the server and replication certificates must share one: %w", other, err)
+	}
+	return nil
+}
+
+// verifySignedBy verifies that the first of certs is signed by one of cas,
+// possibly through the rest of certs.
+func verifySignedBy(certs, cas []*x509.Certificate, now time.Time) error {
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, ca := range cas {
+		roots.AddCert(ca)
+	}
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots: roots, Intermediates: intermediates, CurrentTime: now,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+func (config clusterTLS) Set(ctx context.Context) error {
+	certPEM, err := os.ReadFile(config.CertFile)
+	if err != nil {
+		return err
+	}
+	keyPEM, err := os.ReadFile(config.KeyFile)
+	if err != nil {
+		return err
+	}
+	caPEM, err := os.ReadFile(config.CAFile)
+	if err != nil {
+		return err
+	}
+
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	core, err := corev1client.NewForConfig(rest)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	purpose, field, secret := "server", "customTLSSecret", config.PostgresCluster+"-custom-tls"
+	if config.Replication {
+		purpose, field, secret = "replication", "customReplicationTLSSecret",
+			config.PostgresCluster+"-custom-replication-tls"
+	}
+	if config.Secret != "" {
+		secret = config.Secret
+	}
+
+	now := time.Now()
+	host := fmt.Sprintf("%s-primary.%s.svc", config.PostgresCluster, namespace)
+	warnings, err := validateTLSBundle(certPEM, keyPEM, caPEM, config.Replication, host, now)
+	if err != nil {
+		return err
+	}
+
+	applyCluster := func() error {
+		return applyClusterIntent(ctx, config.Config, config.PostgresCluster,
+			purpose+" certificate set from secrets/"+secret,
+			func(cluster, intent *unstructured.Unstructured) error {
+				// PGO uses a custom replication certificate only with a custom
+				// server certificate, and replicas authenticate with the
+				// replication certificate against the certificate authority of
+				// the server certificate.
+				other, custom := pairedTLSSecret(cluster, config.Replication)
+				switch {
+				case custom:
+					otherSecret, err := core.Secrets(namespace).Get(ctx, other.Name, metav1.GetOptions{})
+					if err != nil {
+						return err
+					}
+					if err := verifyTLSPair(certPEM, otherSecret.Data[other.key("ca.crt")],
+						other.Name, now); err != nil {
+						return err
+					}
+				case config.Replication:
+					warnings = append(warnings, fmt.Sprintf(
+						"the replication certificate is used once the server certificate is set, too; see 'pgo tls set %s'",
+						config.PostgresCluster))
+				default:
+					return fmt.Errorf(
+						"%s has no custom replication certificate; set one signed by the same certificate authority first with 'pgo tls set %s --replication'",
+						config.PostgresCluster, config.PostgresCluster)
+				}
+
+				for _, warning := range warnings {
+					fmt.Fprintf(config.ErrOut, "Warning: %s\n", warning)
+				}
+
+				return unstructured.SetNestedMap(intent.Object,
+					map[string]interface{}{"name": secret}, "spec", field)
+			})
+	}
+	applySecret := func() error {
+		patch, err := tlsSecretPatch(namespace, secret, config.PostgresCluster, certPEM, keyPEM, caPEM)
+		if err == nil {
+			_, err = core.Secrets(namespace).Patch(ctx, secret, types.ApplyPatchType, patch,
+				config.Patch.PatchOptions(metav1.PatchOptions{}))
+		}
+		return err
+	}
+
+	return applyTLSChange(applyCluster, applySecret)
+}
+
+// applyTLSChange points the cluster at the Secret with applyCluster and only
+// then writes the certificate to the Secret with applySecret. The certificate
+// is checked against the cluster as it is changed, so one that is rejected
+// leaves the Secret as it was.
+func applyTLSChange(applyCluster, applySecret func() error) error {
+	if err := applyCluster(); err != nil {
+		return err
+	}
+	return applySecret()
+}
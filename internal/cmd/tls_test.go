@@ -0,0 +1,318 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// testCertificate is a certificate and key in PEM.
+type testCertificate struct {
+	cert, key []byte
+	parsed    *x509.Certificate
+	signer    *ecdsa.PrivateKey
+}
+
+// newTestCertificate returns a certificate from template signed by parent, or
+// self-signed when parent is nil.
+func newTestCertificate(t *testing.T, template *x509.Certificate, parent *testCertificate) testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NilError(t, err)
+
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.parsed, parent.signer
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	assert.NilError(t, err)
+	parsed, err := x509.ParseCertificate(der)
+	assert.NilError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NilError(t, err)
+
+	return testCertificate{
+		cert:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:    pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		parsed: parsed, signer: key,
+	}
+}
+
+func TestValidateTLSBundle(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	year := func(serial int64, cn string) *x509.Certificate {
+		return &x509.Certificate{
+			SerialNumber: big.NewInt(serial), Subject: pkix.Name{CommonName: cn},
+			NotBefore: now.AddDate(0, 0, -1), NotAfter: now.AddDate(1, 0, 0),
+		}
+	}
+
+	caTemplate := year(1, "root")
+	caTemplate.IsCA, caTemplate.BasicConstraintsValid = true, true
+	caTemplate.KeyUsage = x509.KeyUsageCertSign
+	ca := newTestCertificate(t, caTemplate, nil)
+	other := newTestCertificate(t, caTemplate, nil)
+
+	serverTemplate := year(2, "hippo-primary")
+	serverTemplate.DNSNames = []string{"hippo-primary.postgres-operator.svc"}
+	server := newTestCertificate(t, serverTemplate, &ca)
+	replication := newTestCertificate(t, year(3, "_crunchyrepl"), &ca)
+
+	host := "hippo-primary.postgres-operator.svc"
+
+	t.Run("Valid", func(t *testing.T) {
+		warnings, err := validateTLSBundle(server.cert, server.key, ca.cert, false, host, now)
+		assert.NilError(t, err)
+		assert.Assert(t, len(warnings) == 0, "%v", warnings)
+
+		warnings, err = validateTLSBundle(replication.cert, replication.key, ca.cert, true, host, now)
+		assert.NilError(t, err)
+		assert.Assert(t, len(warnings) == 0, "%v", warnings)
+	})
+
+	t.Run("Warnings", func(t *testing.T) {
+		warnings, err := validateTLSBundle(server.cert, server.key, ca.cert, false,
+			"hippo-primary.elsewhere.svc", now.AddDate(0, 11, 20))
+		assert.NilError(t, err)
+		assert.DeepEqual(t, warnings, []string{
+			"certificate is not valid for hippo-primary.elsewhere.svc; clients that verify the host name cannot connect",
+			"certificate expires in 9 days",
+		})
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := validateTLSBundle(server.cert, replication.key, ca.cert, false, host, now)
+		assert.ErrorContains(t, err, "certificate and key do not match")
+
+		_, err = validateTLSBundle(server.cert, server.key, other.cert, false, host, now)
+		assert.ErrorContains(t, err, "certificate is not signed by the certificate authority")
+
+		_, err = validateTLSBundle(server.cert, server.key, []byte("nope"), false, host, now)
+		assert.ErrorContains(t, err, "certificate authority: no PEM certificates found")
+
+		_, err = validateTLSBundle(server.cert, server.key, ca.cert, false, host, now.AddDate(2, 0, 0))
+		assert.ErrorContains(t, err, "certificate expired on 2024-03-01T00:00:00Z")
+
+		_, err = validateTLSBundle(server.cert, server.key, ca.cert, false, host, now.AddDate(0, 0, -2))
+		assert.ErrorContains(t, err, "certificate is not valid until 2023-02-28T00:00:00Z")
+
+		_, err = validateTLSBundle(server.cert, server.key, ca.cert, true, host, now)
+		assert.ErrorContains(t, err, `must have the common name "_crunchyrepl", not "hippo-primary"`)
+	})
+
+	t.Run("Intermediate", func(t *testing.T) {
+		intermediateTemplate := year(4, "intermediate")
+		intermediateTemplate.IsCA, intermediateTemplate.BasicConstraintsValid = true, true
+		intermediateTemplate.KeyUsage = x509.KeyUsageCertSign
+		intermediate := newTestCertificate(t, intermediateTemplate, &ca)
+
+		leaf := newTestCertificate(t, serverTemplate, &intermediate)
+		chain := append(append([]byte{}, leaf.cert...), intermediate.cert...)
+
+		_, err := validateTLSBundle(chain, leaf.key, ca.cert, false, host, now)
+		assert.NilError(t, err)
+
+		_, err = validateTLSBundle(leaf.cert, leaf.key, ca.cert, false, host, now)
+		assert.ErrorContains(t, err, "not signed by the certificate authority")
+	})
+}
+
+func TestDescribeCertificates(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "hippo-primary", Organization: []string{"Zoo"}},
+		DNSNames:     []string{"hippo-primary", "hippo-primary.postgres-operator.svc"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.7")},
+		NotBefore:    now, NotAfter: now.AddDate(0, 0, 45),
+	}
+	cert := newTestCertificate(t, template, nil)
+
+	infos, err := describeCertificates("hippo-cluster-cert", "tls.crt", append(cert.key, cert.cert...))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, infos, []certificateInfo{{
+		Secret: "hippo-cluster-cert", Key: "tls.crt",
+		Subject: "CN=hippo-primary,O=Zoo", Issuer: "CN=hippo-primary,O=Zoo",
+		SANs:     []string{"hippo-primary", "hippo-primary.postgres-operator.svc", "10.0.0.7"},
+		NotAfter: now.AddDate(0, 0, 45),
+	}})
+
+	_, err = describeCertificates("hippo-cluster-cert", "ca.crt", nil)
+	assert.ErrorContains(t, err, "secret hippo-cluster-cert key ca.crt: no PEM certificates found")
+
+	var out bytes.Buffer
+	infos = append(infos, certificateInfo{
+		Secret: "hippo-cluster-cert", Key: "ca.crt", Subject: "CN=root", Issuer: "CN=root",
+		NotAfter: now.AddDate(0, 0, -3),
+	})
+	assert.NilError(t, writeCertificates(&out, infos, now.AddDate(0, 0, 15)))
+	assert.Equal(t, out.String(), strings.TrimLeft(`
+SECRET              KEY      SUBJECT                 SANS                                                        ISSUER                  NOT AFTER             DAYS LEFT
+hippo-cluster-cert  tls.crt  CN=hippo-primary,O=Zoo  hippo-primary,hippo-primary.postgres-operator.svc,10.0.0.7  CN=hippo-primary,O=Zoo  2023-04-15T00:00:00Z  30
+hippo-cluster-cert  ca.crt   CN=root                 <none>                                                      CN=root                 2023-02-26T00:00:00Z  -18
+`, "\n"))
+}
+
+func TestExpiringCertificates(t *testing.T) {
+	now := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	infos := []certificateInfo{
+		{Secret: "hippo-cluster-cert", Key: "tls.crt", Subject: "CN=hippo-primary", NotAfter: now.AddDate(0, 0, 20)},
+		{Secret: "hippo-cluster-cert", Key: "ca.crt", Subject: "CN=root", NotAfter: now.AddDate(10, 0, 0)},
+	}
+
+	assert.NilError(t, expiringCertificates(infos, now, 0))
+	assert.NilError(t, expiringCertificates(infos, now, 14))
+
+	err := expiringCertificates(infos, now, 30)
+	var exit *ExitError
+	assert.Assert(t, errors.As(err, &exit))
+	assert.Equal(t, exit.Code, exitExpiring)
+	assert.ErrorContains(t, err,
+		"1 certificates expire within 30 days: CN=hippo-primary in hippo-cluster-cert/tls.crt")
+}
+
+func TestClusterTLSSecrets(t *testing.T) {
+	cluster := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	assert.NilError(t, yaml.Unmarshal([]byte(strings.TrimSpace(`
+metadata:
+  name: hippo
+spec:
+  customReplicationTLSSecret:
+    name: hippo-repl
+    items:
+    - { key: repl.crt, path: tls.crt }
+    - { key: repl.key, path: tls.key }
+	`)), &cluster.Object))
+
+	refs := clusterTLSSecrets(cluster)
+	assert.Equal(t, len(refs), 2)
+	assert.Equal(t, refs[0].Name, "hippo-cluster-cert")
+	assert.Equal(t, refs[0].key("tls.crt"), "tls.crt")
+	assert.Equal(t, refs[1].Name, "hippo-repl")
+	assert.Equal(t, refs[1].key("tls.crt"), "repl.crt")
+	assert.Equal(t, refs[1].key("ca.crt"), "ca.crt")
+}
+
+func TestTLSSecretPatch(t *testing.T) {
+	patch, err := tlsSecretPatch("postgres-operator", "hippo-custom-tls", "hippo",
+		[]byte("cert"), []byte("key"), []byte("ca"))
+	assert.NilError(t, err)
+
+	var secret map[string]interface{}
+	assert.NilError(t, json.Unmarshal(patch, &secret))
+	assert.DeepEqual(t, secret, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name": "hippo-custom-tls", "namespace": "postgres-operator",
+			"labels": map[string]interface{}{"postgres-operator.crunchydata.com/cluster": "hippo"},
+		},
+		"type": "kubernetes.io/tls",
+		"data": map[string]interface{}{
+			"tls.crt": "Y2VydA==", "tls.key": "a2V5", "ca.crt": "Y2E=",
+		},
+	})
+}
+
+func TestTLSPair(t *testing.T) {
+	unmarshal := func(t *testing.T, doc string) *unstructured.Unstructured {
+		t.Helper()
+		object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		assert.NilError(t, yaml.Unmarshal([]byte(strings.TrimSpace(doc)), &object.Object))
+		return object
+	}
+
+	t.Run("Paired", func(t *testing.T) {
+		generated := unmarshal(t, `{ metadata: { name: hippo } }`)
+		other, custom := pairedTLSSecret(generated, false)
+		assert.Equal(t, other.Name, "hippo-replication-cert")
+		assert.Assert(t, !custom)
+
+		replication := unmarshal(t, `
+metadata: { name: hippo }
+spec:
+  customReplicationTLSSecret: { name: hippo-repl }
+		`)
+		other, custom = pairedTLSSecret(replication, false)
+		assert.Equal(t, other.Name, "hippo-repl")
+		assert.Assert(t, custom)
+
+		other, custom = pairedTLSSecret(replication, true)
+		assert.Equal(t, other.Name, "hippo-cluster-cert")
+		assert.Assert(t, !custom)
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		now := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+		template := func(serial int64, cn string, ca bool) *x509.Certificate {
+			return &x509.Certificate{
+				SerialNumber: big.NewInt(serial), Subject: pkix.Name{CommonName: cn},
+				NotBefore: now.AddDate(0, 0, -1), NotAfter: now.AddDate(1, 0, 0),
+				IsCA: ca, BasicConstraintsValid: ca,
+			}
+		}
+
+		ca := newTestCertificate(t, template(1, "root", true), nil)
+		other := newTestCertificate(t, template(2, "other", true), nil)
+		server := newTestCertificate(t, template(3, "hippo-primary", false), &ca)
+
+		assert.NilError(t, verifyTLSPair(server.cert, ca.cert, "hippo-repl", now))
+
+		err := verifyTLSPair(server.cert, other.cert, "hippo-repl", now)
+		assert.ErrorContains(t, err,
+			"certificate is not signed by the certificate authority in secrets/hippo-repl; "+
+				"the server and replication certificates must share one")
+
+		err = verifyTLSPair(server.cert, nil, "hippo-replication-cert", now)
+		assert.ErrorContains(t, err,
+			"certificate authority in secrets/hippo-replication-cert: no PEM certificates found")
+	})
+}
+
+func TestApplyTLSChange(t *testing.T) {
+	var calls []string
+	applyCluster := func(err error) func() error {
+		return func() error { calls = append(calls, "cluster"); return err }
+	}
+	applySecret := func() error { calls = append(calls, "secret"); return nil }
+
+	assert.NilError(t, applyTLSChange(applyCluster(nil), applySecret))
+	assert.DeepEqual(t, calls, []string{"cluster", "secret"})
+
+	calls = nil
+	err := applyTLSChange(applyCluster(errors.New("not signed")), applySecret)
+	assert.ErrorContains(t, err, "not signed")
+	assert.DeepEqual(t, calls, []string{"cluster"})
+}
@@ -0,0 +1,676 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/crunchydata/postgres-operator-client/internal"
+	"github.com/crunchydata/postgres-operator-client/internal/apis/postgres-operator.crunchydata.com/v1beta1"
+	"github.com/crunchydata/postgres-operator-client/internal/util"
+)
+
+// newUserCommand returns the user subcommand of the PGO plugin. Its
+// subcommands manage the users in the spec of a PostgresCluster.
+func newUserCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage PostgreSQL users of a PostgresCluster",
+		Long: `Manage the PostgreSQL users defined in "spec.users" of a PostgresCluster.
+
+PGO creates each user with the databases and options in the spec and stores
+its credentials in a Secret named <cluster>-pguser-<user>. When "spec.users"
+is empty, PGO creates one user and database named after the cluster.`,
+	}
+
+	cmd.AddCommand(
+		newUserCreateCommand(config),
+		newUserUpdateCommand(config),
+		newUserDeleteCommand(config),
+		newUserListCommand(config),
+		newUserRotatePasswordCommand(config),
+	)
+
+	// No arguments for 'user', but there are arguments for the subcommands.
+	cmd.Args = cobra.NoArgs
+
+	return cmd
+}
+
+func newUserCreateCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create CLUSTER_NAME USER_NAME",
+		Short: "Add a user to a PostgresCluster",
+		Long: `Add a user to "spec.users" of a PostgresCluster. PGO creates the user and its
+databases then generates its credentials in a Secret.
+
+NOTE: When "spec.users" is empty, PGO manages a user named after the cluster.
+That user is no longer managed once another user is added; add it too to keep it.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Add the 'rhino' user to the 'hippo' postgrescluster
+pgo user create hippo rhino
+
+# Add the 'rhino' user, owner of the 'zoo' database, that can create databases
+pgo user create hippo rhino --databases=zoo --options="CREATEDB"
+`)
+
+	user := postgresUser{Config: config}
+	user.addFlags(cmd)
+
+	// Two positional arguments: the PostgresCluster name and the user name.
+	cmd.Args = cobra.ExactArgs(2)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		user.PostgresCluster, user.Name = args[0], args[1]
+		return user.Run(context.Background(), "created", user.createIntent)
+	}
+
+	return cmd
+}
+
+func newUserUpdateCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update CLUSTER_NAME USER_NAME",
+		Short: "Change the databases or options of a user",
+		Long: `Change the databases or options of a user in "spec.users" of a PostgresCluster.
+Only the fields of the flags given are changed.
+
+PGO grants the user privileges on new databases and applies new options. It
+does not revoke privileges on databases that are removed.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Give the 'rhino' user of the 'hippo' postgrescluster the 'zoo' and 'savanna' databases
+pgo user update hippo rhino --databases=zoo --databases=savanna
+
+# Remove every option of the 'rhino' user
+pgo user update hippo rhino --options=""
+`)
+
+	user := postgresUser{Config: config}
+	user.addFlags(cmd)
+
+	// Two positional arguments: the PostgresCluster name and the user name.
+	cmd.Args = cobra.ExactArgs(2)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		user.PostgresCluster, user.Name = args[0], args[1]
+		user.setDatabases = cmd.Flags().Changed("databases")
+		user.setOptions = cmd.Flags().Changed("options")
+
+		if !user.setDatabases && !user.setOptions {
+			return fmt.Errorf("nothing to update: specify --databases or --options")
+		}
+
+		return user.Run(context.Background(), "updated", user.updateIntent)
+	}
+
+	return cmd
+}
+
+func newUserDeleteCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete CLUSTER_NAME USER_NAME",
+		Short: "Remove a user from a PostgresCluster",
+		Long: `Remove a user from "spec.users" of a PostgresCluster. PGO deletes the Secret
+of the user; the user and its databases remain in PostgreSQL.
+
+Only users added by this plugin can be removed by it.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get patch]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Remove the 'rhino' user from the 'hippo' postgrescluster
+pgo user delete hippo rhino
+`)
+
+	user := postgresUser{Config: config}
+
+	// Two positional arguments: the PostgresCluster name and the user name.
+	cmd.Args = cobra.ExactArgs(2)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		user.PostgresCluster, user.Name = args[0], args[1]
+		return user.Run(context.Background(), "deleted", user.deleteIntent)
+	}
+
+	return cmd
+}
+
+func newUserListCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list CLUSTER_NAME",
+		Short: "List the users of a PostgresCluster",
+		Long: `List the users of a PostgresCluster with their databases, options and Secret.
+A user is ready when its Secret has a password and a verifier.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get]
+    secrets                                             [list]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# List the users of the 'hippo' postgrescluster
+pgo user list hippo
+`)
+
+	// Only one positional argument: the PostgresCluster name.
+	cmd.Args = cobra.ExactArgs(1)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+
+		_, client, err := v1beta1.NewPostgresClusterClient(config)
+		if err != nil {
+			return err
+		}
+
+		rest, err := config.ToRESTConfig()
+		if err != nil {
+			return err
+		}
+		core, err := corev1client.NewForConfig(rest)
+		if err != nil {
+			return err
+		}
+
+		namespace, err := config.Namespace()
+		if err != nil {
+			return err
+		}
+
+		cluster, err := client.Namespace(namespace).Get(ctx, args[0], metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		secrets, err := core.Secrets(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: util.PostgresUserSecretLabels(args[0]),
+		})
+		if err != nil {
+			return err
+		}
+
+		return writePostgresUsers(cmd.OutOrStdout(), listPostgresUsers(cluster, secrets.Items))
+	}
+
+	return cmd
+}
+
+type postgresUser struct {
+	*internal.Config
+
+	Databases []string
+	Options   string
+
+	Name            string
+	PostgresCluster string
+
+	// setDatabases and setOptions are whether the fields should change
+	// during an update.
+	setDatabases, setOptions bool
+}
+
+func (config *postgresUser) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&config.Databases, "databases", nil,
+		"database the user can access; can be used multiple times")
+	cmd.Flags().StringVar(&config.Options, "options", "",
+		`ALTER ROLE options of the user, such as "SUPERUSER CREATEDB"`)
+}
+
+// Run applies the changes of modify to the PostgresCluster and prints action
+// when they are accepted.
+func (config postgresUser) Run(
+	ctx context.Context, action string,
+	modify func(cluster, intent *unstructured.Unstructured) error,
+) error {
+	return applyClusterIntent(ctx, config.Config, config.PostgresCluster,
+		"user "+config.Name+" "+action, modify)
+}
+
+// createIntent adds the user to intent. It is an error when the user already
+// exists in cluster.
+func (config postgresUser) createIntent(cluster, intent *unstructured.Unstructured) error {
+	if _, ok := findPostgresUser(cluster, config.Name); ok {
+		return fmt.Errorf("user %q already exists in %s", config.Name, config.PostgresCluster)
+	}
+
+	config.setDatabases, config.setOptions = true, true
+	return config.setIntent(intent)
+}
+
+// updateIntent sets the fields of an existing user in intent. Only the fields
+// chosen by the flags change.
+func (config postgresUser) updateIntent(cluster, intent *unstructured.Unstructured) error {
+	if _, ok := findPostgresUser(cluster, config.Name); !ok {
+		return fmt.Errorf("user %q not found in %s", config.Name, config.PostgresCluster)
+	}
+
+	return config.setIntent(intent)
+}
+
+// setIntent adds the user to intent or changes the fields of it that are
+// chosen by the flags.
+func (config postgresUser) setIntent(intent *unstructured.Unstructured) error {
+	users, _, _ := unstructured.NestedSlice(intent.Object, "spec", "users")
+	user, i := map[string]interface{}{"name": config.Name}, len(users)
+	if existing, ok := findPostgresUser(intent, config.Name); ok {
+		user, i = users[existing].(map[string]interface{}), existing
+	} else {
+		users = append(users, user)
+	}
+
+	if config.setDatabases {
+		if len(config.Databases) == 0 {
+			delete(user, "databases")
+		} else {
+			databases := make([]interface{}, len(config.Databases))
+			for j := range config.Databases {
+				databases[j] = config.Databases[j]
+			}
+			user["databases"] = databases
+		}
+	}
+	if config.setOptions {
+		if config.Options == "" {
+			delete(user, "options")
+		} else {
+			user["options"] = config.Options
+		}
+	}
+
+	users[i] = user
+	return unstructured.SetNestedSlice(intent.Object, users, "spec", "users")
+}
+
+// deleteIntent removes the user from intent. Users that were not added through
+// this field manager cannot be removed by it.
+func (config postgresUser) deleteIntent(cluster, intent *unstructured.Unstructured) error {
+	if _, ok := findPostgresUser(cluster, config.Name); !ok {
+		return fmt.Errorf("user %q not found in %s", config.Name, config.PostgresCluster)
+	}
+
+	users, _, _ := unstructured.NestedSlice(intent.Object, "spec", "users")
+	i, ok := findPostgresUser(intent, config.Name)
+	if !ok {
+		return fmt.Errorf("user %q is managed by another field manager; remove it with the tool that added it",
+			config.Name)
+	}
+
+	users = append(users[:i], users[i+1:]...)
+	if err := unstructured.SetNestedSlice(intent.Object, users, "spec", "users"); err != nil {
+		return err
+	}
+
+	internal.RemoveEmptySections(intent, "spec", "users")
+	return nil
+}
+
+// findPostgresUser returns the index of the user called name in "spec.users"
+// of object.
+func findPostgresUser(object *unstructured.Unstructured, name string) (int, bool) {
+	users, _, _ := unstructured.NestedSlice(object.Object, "spec", "users")
+	for i := range users {
+		if user, _ := users[i].(map[string]interface{}); user != nil && user["name"] == name {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// postgresUserStatus describes one user of a PostgresCluster.
+type postgresUserStatus struct {
+	Name      string
+	Databases []string
+	Options   string
+	Secret    string
+	Ready     bool
+}
+
+// listPostgresUsers joins the users in the spec of cluster with their Secrets.
+// Users without a Secret are not ready. Secrets without a user, such as the
+// one of the default user, are listed with the database in the Secret.
+func listPostgresUsers(cluster *unstructured.Unstructured, secrets []corev1.Secret) []postgresUserStatus {
+	var result []postgresUserStatus
+	index := map[string]int{}
+
+	users, _, _ := unstructured.NestedSlice(cluster.Object, "spec", "users")
+	for i := range users {
+		user, _ := users[i].(map[string]interface{})
+		name, _, _ := unstructured.NestedString(user, "name")
+		if user == nil || name == "" {
+			continue
+		}
+
+		status := postgresUserStatus{Name: name}
+		status.Databases, _, _ = unstructured.NestedStringSlice(user, "databases")
+		status.Options, _, _ = unstructured.NestedString(user, "options")
+
+		index[name] = len(result)
+		result = append(result, status)
+	}
+
+	for i := range secrets {
+		name := secrets[i].Labels[util.LabelPostgresUser]
+		if name == "" {
+			continue
+		}
+
+		j, ok := index[name]
+		if !ok {
+			status := postgresUserStatus{Name: name}
+			if db := string(secrets[i].Data["dbname"]); db != "" {
+				status.Databases = []string{db}
+			}
+			j = len(result)
+			index[name] = j
+			result = append(result, status)
+		}
+
+		result[j].Secret = secrets[i].Name
+		result[j].Ready = len(secrets[i].Data["password"]) > 0 &&
+			len(secrets[i].Data["verifier"]) > 0
+	}
+
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// writePostgresUsers prints users as a table.
+func writePostgresUsers(out io.Writer, users []postgresUserStatus) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDATABASES\tOPTIONS\tSECRET\tREADY")
+
+	orNone := func(s string) string {
+		if s == "" {
+			return "<none>"
+		}
+		return s
+	}
+
+	for _, user := range users {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", user.Name,
+			orNone(strings.Join(user.Databases, ",")), orNone(user.Options),
+			orNone(user.Secret), strconv.FormatBool(user.Ready))
+	}
+
+	return w.Flush()
+}
+
+func newUserRotatePasswordCommand(config *internal.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate-password CLUSTER_NAME USER_NAME",
+		Short: "Change the password of a user",
+		Long: `Change the password of a user of a PostgresCluster. PGO generates a new password
+unless one is read from stdin. The command waits for PGO to update the user then
+verifies that it can log in with psql on the primary instance.
+
+The new connection details are printed only when --show-connection is given.
+
+#### RBAC Requirements
+    Resources                                           Verbs
+    ---------                                           -----
+    postgresclusters.postgres-operator.crunchydata.com  [get]
+    secrets                                             [get list patch]
+    pods                                                [list]
+    pods/exec                                           [create]`,
+	}
+
+	cmd.Example = internal.FormatExample(`
+# Generate a new password for the 'rhino' user of the 'hippo' postgrescluster
+pgo user rotate-password hippo rhino
+
+# Set the password of the 'rhino' user from a file and print the new connection details
+pgo user rotate-password hippo rhino --password-from-stdin --show-connection < password.txt
+`)
+
+	rotate := postgresUserPassword{Config: config, Interval: 2 * time.Second}
+
+	cmd.Flags().BoolVar(&rotate.FromStdin, "password-from-stdin", false,
+		"read the new password from stdin rather than generating one")
+	cmd.Flags().BoolVar(&rotate.ShowConnection, "show-connection", false,
+		"print the new connection details of the user, including its password")
+	cmd.Flags().DurationVar(&rotate.Timeout, "timeout", 2*time.Minute,
+		"how long to wait for PGO to update the user")
+
+	// Two positional arguments: the PostgresCluster name and the user name.
+	cmd.Args = cobra.ExactArgs(2)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		rotate.PostgresCluster, rotate.Name = args[0], args[1]
+		return rotate.Run(context.Background())
+	}
+
+	return cmd
+}
+
+type postgresUserPassword struct {
+	*internal.Config
+
+	FromStdin      bool
+	ShowConnection bool
+	Timeout        time.Duration
+
+	// Interval is the time between checks of the Secret and logins.
+	Interval time.Duration
+
+	Name            string
+	PostgresCluster string
+}
+
+func (config postgresUserPassword) Run(ctx context.Context) error {
+	var password []byte
+	if config.FromStdin {
+		var err error
+		if password, err = readPassword(config.In); err != nil {
+			return err
+		}
+	}
+
+	mapping, client, err := v1beta1.NewPostgresClusterClient(config)
+	if err != nil {
+		return err
+	}
+
+	rest, err := config.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	core, err := corev1client.NewForConfig(rest)
+	if err != nil {
+		return err
+	}
+	namespace, err := config.Namespace()
+	if err != nil {
+		return err
+	}
+
+	// Check that the cluster exists before looking for its Secrets.
+	if _, err := client.Namespace(namespace).Get(ctx,
+		config.PostgresCluster, metav1.GetOptions{}); err != nil {
+		return err
+	}
+
+	secrets, err := core.Secrets(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: util.PostgresUserSecretLabels(config.PostgresCluster) + "," +
+			util.LabelPostgresUser + "=" + config.Name,
+	})
+	if err != nil {
+		return err
+	}
+	if len(secrets.Items) != 1 {
+		return fmt.Errorf("Secret of user %q not found in %s; see 'pgo user list'",
+			config.Name, config.PostgresCluster)
+	}
+	before := secrets.Items[0]
+
+	patch, err := rotatePasswordPatch(&before, password)
+	if err == nil {
+		_, err = core.Secrets(namespace).Patch(ctx,
+			before.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(config.Out, "%s/%s user %s password rotated; waiting for PGO\n",
+		mapping.Resource.Resource, config.PostgresCluster, config.Name)
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	// PGO generates the verifier, and the password when there is none, then
+	// changes the user in PostgreSQL.
+	var after *corev1.Secret
+	err = wait.PollImmediateUntilWithContext(ctx, config.Interval, func(ctx context.Context) (bool, error) {
+		after, err = core.Secrets(namespace).Get(ctx, before.Name, metav1.GetOptions{})
+		return err == nil && passwordRotated(&before, after, password), err
+	})
+	if err != nil {
+		return fmt.Errorf("PGO did not update Secret %s: %w", before.Name, err)
+	}
+
+	exec, pod, err := primaryExecutor(ctx, config.Config, namespace, config.PostgresCluster)
+	if err != nil {
+		return err
+	}
+
+	// The user might not have changed in PostgreSQL yet, so try until it can
+	// log in or time runs out.
+	database, port := string(after.Data["dbname"]), int32(5432)
+	if database == "" {
+		database = "postgres"
+	}
+	if p, err := strconv.ParseInt(string(after.Data["port"]), 10, 32); err == nil {
+		port = int32(p)
+	}
+
+	var stderr string
+	err = wait.PollImmediateUntilWithContext(ctx, config.Interval, func(context.Context) (bool, error) {
+		_, stderr, err = exec.psqlLogin(config.Name, database, port, after.Data["password"])
+		return err == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot log in as %q on %s: %w: %s",
+			config.Name, pod, err, strings.TrimSpace(stderr))
+	}
+
+	fmt.Fprintf(config.Out, "%s/%s user %s login verified on %s\n",
+		mapping.Resource.Resource, config.PostgresCluster, config.Name, pod)
+
+	if config.ShowConnection {
+		writeConnectionDetails(config.Out, after)
+	}
+
+	return nil
+}
+
+// readPassword returns one password read from r without its line ending.
+func readPassword(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b = bytes.TrimSuffix(bytes.TrimSuffix(b, []byte("\n")), []byte("\r"))
+	switch {
+	case len(b) == 0:
+		return nil, errors.New("no password on stdin")
+	case bytes.ContainsAny(b, "\r\n"):
+		return nil, errors.New("password on stdin must be one line")
+	}
+	return b, nil
+}
+
+// rotatePasswordPatch returns a JSON merge patch that clears the verifier of
+// secret so that PGO generates another. The password is replaced by password
+// or cleared when it is empty. The patch fails if secret has changed.
+func rotatePasswordPatch(secret *corev1.Secret, password []byte) ([]byte, error) {
+	data := map[string]interface{}{"verifier": nil, "password": nil}
+	if len(password) > 0 {
+		data["password"] = password
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"resourceVersion": secret.ResourceVersion},
+		"data":     data,
+	})
+}
+
+// passwordRotated returns whether PGO has finished with the Secret after it
+// was patched by [rotatePasswordPatch].
+func passwordRotated(before, after *corev1.Secret, password []byte) bool {
+	verifier := after.Data["verifier"]
+	if len(verifier) == 0 || bytes.Equal(verifier, before.Data["verifier"]) {
+		return false
+	}
+	if len(password) > 0 {
+		return bytes.Equal(after.Data["password"], password)
+	}
+	return len(after.Data["password"]) > 0 &&
+		!bytes.Equal(after.Data["password"], before.Data["password"])
+}
+
+// writeConnectionDetails prints every field of secret that describes how to
+// connect as its user.
+func writeConnectionDetails(out io.Writer, secret *corev1.Secret) {
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		if key != "verifier" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(out, "%s: %s\n", key, secret.Data[key])
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	"github.com/crunchydata/postgres-operator-client/internal/testing/cmp"
+)
+
+func TestPostgresUserModifyIntent(t *testing.T) {
+	unmarshal := func(t *testing.T, doc string) *unstructured.Unstructured {
+		t.Helper()
+		object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		assert.NilError(t, yaml.Unmarshal([]byte(strings.TrimSpace(doc)), &object.Object))
+		return object
+	}
+
+	cluster := unmarshal(t, `
+spec:
+  users:
+  - name: hippo
+  - name: rhino
+    databases: [zoo]
+    options: CREATEDB
+	`)
+
+	for _, tt := range []struct {
+		Name, Intent, After, Error string
+		User                       postgresUser
+		Modify                     func(postgresUser) func(cluster, intent *unstructured.Unstructured) error
+	}{
+		{
+			Name:   "Create",
+			User:   postgresUser{Name: "zebra", Databases: []string{"zoo", "savanna"}, Options: "NOLOGIN"},
+			Modify: func(u postgresUser) func(_, _ *unstructured.Unstructured) error { return u.createIntent },
+			Intent: `
+spec:
+  users:
+  - name: rhino
+    databases: [zoo]
+			`,
+			After: `
+spec:
+  users:
+  - databases:
+    - zoo
+    name: rhino
+  - databases:
+    - zoo
+    - savanna
+    name: zebra
+    options: NOLOGIN
+			`,
+		},
+		{
+			Name:   "CreateExisting",
+			User:   postgresUser{Name: "hippo", PostgresCluster: "hippo"},
+			Modify: func(u postgresUser) func(_, _ *unstructured.Unstructured) error { return u.createIntent },
+			Error:  `user "hippo" already exists in hippo`,
+		},
+		{
+			Name:   "UpdateOptions",
+			User:   postgresUser{Name: "rhino", Options: "SUPERUSER", setOptions: true},
+			Modify: func(u postgresUser) func(_, _ *unstructured.Unstructured) error { return u.updateIntent },
+			Intent: `
+spec:
+  users:
+  - name: rhino
+    databases: [zoo]
+    options: CREATEDB
+			`,
+			After: `
+spec:
+  users:
+  - databases:
+    - zoo
+    name: rhino
+    options: SUPERUSER
+			`,
+		},
+		{
+			Name:   "UpdateUnmanaged",
+			User:   postgresUser{Name: "hippo", Databases: []string{"zoo"}, setDatabases: true, setOptions: true},
+			Modify: func(u postgresUser) func(_, _ *unstructured.Unstructured) error { return u.updateIntent },
+			After: `
+spec:
+  users:
+  - databases:
+    - zoo
+    name: hippo
+			`,
+		},
+		{
+			Name:   "UpdateMissing",
+			User:   postgresUser{Name: "zebra", PostgresCluster: "hippo", setOptions: true},
+			Modify: func(u postgresUser) func(_, _ *unstructured.Unstructured) error { return u.updateIntent },
+			Error:  `user "zebra" not found in hippo`,
+		},
+		{
+			Name:   "Delete",
+			User:   postgresUser{Name: "rhino"},
+			Modify: func(u postgresUser) func(_, _ *unstructured.Unstructured) error { return u.deleteIntent },
+			Intent: `
+metadata:
+  annotations:
+    some: thing
+spec:
+  users:
+  - name: rhino
+			`,
+			After: `
+metadata:
+  annotations:
+    some: thing
+			`,
+		},
+		{
+			Name:   "DeleteUnmanaged",
+			User:   postgresUser{Name: "hippo"},
+			Modify: func(u postgresUser) func(_, _ *unstructured.Unstructured) error { return u.deleteIntent },
+			Intent: `
+spec:
+  users:
+  - name: rhino
+			`,
+			Error: `user "hippo" is managed by another field manager`,
+		},
+		{
+			Name:   "DeleteMissing",
+			User:   postgresUser{Name: "zebra", PostgresCluster: "hippo"},
+			Modify: func(u postgresUser) func(_, _ *unstructured.Unstructured) error { return u.deleteIntent },
+			Error:  `user "zebra" not found in hippo`,
+		},
+	} {
+		t.Run(tt.Name, func(t *testing.T) {
+			intent := unmarshal(t, tt.Intent)
+			err := tt.Modify(tt.User)(cluster, intent)
+
+			if tt.Error != "" {
+				assert.ErrorContains(t, err, tt.Error)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Assert(t, cmp.MarshalMatches(intent, strings.TrimSpace(tt.After)))
+		})
+	}
+}
+
+func TestListPostgresUsers(t *testing.T) {
+	cluster := new(unstructured.Unstructured)
+	assert.NilError(t, yaml.Unmarshal([]byte(strings.TrimSpace(`
+spec:
+  users:
+  - name: rhino
+    databases: [zoo, savanna]
+    options: CREATEDB
+  - name: zebra
+	`)), &cluster.Object))
+
+	secret := func(user string, data map[string]string) corev1.Secret {
+		s := corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:   "hippo-pguser-" + user,
+			Labels: map[string]string{"postgres-operator.crunchydata.com/pguser": user},
+		}, Data: map[string][]byte{}}
+		for k, v := range data {
+			s.Data[k] = []byte(v)
+		}
+		return s
+	}
+
+	users := listPostgresUsers(cluster, []corev1.Secret{
+		secret("rhino", map[string]string{"password": "p", "verifier": "v"}),
+		secret("hippo", map[string]string{"password": "p", "verifier": "v", "dbname": "hippo"}),
+		secret("zebra", map[string]string{"password": "p"}),
+	})
+
+	assert.DeepEqual(t, users, []postgresUserStatus{
+		{Name: "hippo", Databases: []string{"hippo"}, Secret: "hippo-pguser-hippo", Ready: true},
+		{Name: "rhino", Databases: []string{"zoo", "savanna"}, Options: "CREATEDB", Secret: "hippo-pguser-rhino", Ready: true},
+		{Name: "zebra", Secret: "hippo-pguser-zebra"},
+	})
+
+	var out bytes.Buffer
+	assert.NilError(t, writePostgresUsers(&out, append(users, postgresUserStatus{Name: "yak"})))
+	assert.Equal(t, out.String(), ``+
+		"NAME   DATABASES    OPTIONS   SECRET              READY\n"+
+		"hippo  hippo        <none>    hippo-pguser-hippo  true\n"+
+		"rhino  zoo,savanna  CREATEDB  hippo-pguser-rhino  true\n"+
+		"zebra  <none>       <none>    hippo-pguser-zebra  false\n"+
+		"yak    <none>       <none>    <none>              false\n")
+}
+
+func TestReadPassword(t *testing.T) {
+	for input, expected := range map[string]string{
+		"s3cr3t":          "s3cr3t",
+		"s3cr3t\n":        "s3cr3t",
+		"s3cr3t\r\n":      "s3cr3t",
+		" spaces kept \n": " spaces kept ",
+	} {
+		password, err := readPassword(strings.NewReader(input))
+		assert.NilError(t, err, "input %q", input)
+		assert.Equal(t, string(password), expected)
+	}
+
+	_, err := readPassword(strings.NewReader("\n"))
+	assert.ErrorContains(t, err, "no password")
+
+	_, err = readPassword(strings.NewReader("one\ntwo\n"))
+	assert.ErrorContains(t, err, "must be one line")
+}
+
+func TestRotatePassword(t *testing.T) {
+	before := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "hippo-pguser-rhino", ResourceVersion: "12"},
+		Data: map[string][]byte{
+			"password": []byte("old"), "verifier": []byte("SCRAM-old"), "dbname": []byte("zoo"),
+		},
+	}
+
+	t.Run("Patch", func(t *testing.T) {
+		patch, err := rotatePasswordPatch(before, nil)
+		assert.NilError(t, err)
+		assert.Equal(t, string(patch),
+			`{"data":{"password":null,"verifier":null},"metadata":{"resourceVersion":"12"}}`)
+
+		patch, err = rotatePasswordPatch(before, []byte("new"))
+		assert.NilError(t, err)
+		assert.Equal(t, string(patch),
+			`{"data":{"password":"bmV3","verifier":null},"metadata":{"resourceVersion":"12"}}`)
+	})
+
+	t.Run("Rotated", func(t *testing.T) {
+		secret := func(password, verifier string) *corev1.Secret {
+			return &corev1.Secret{Data: map[string][]byte{
+				"password": []byte(password), "verifier": []byte(verifier),
+			}}
+		}
+
+		assert.Assert(t, !passwordRotated(before, secret("", ""), nil))
+		assert.Assert(t, !passwordRotated(before, secret("old", "SCRAM-old"), nil))
+		assert.Assert(t, !passwordRotated(before, secret("old", "SCRAM-new"), nil))
+		assert.Assert(t, passwordRotated(before, secret("gen", "SCRAM-new"), nil))
+
+		assert.Assert(t, !passwordRotated(before, secret("new", ""), []byte("new")))
+		assert.Assert(t, !passwordRotated(before, secret("gen", "SCRAM-new"), []byte("new")))
+		assert.Assert(t, passwordRotated(before, secret("new", "SCRAM-new"), []byte("new")))
+	})
+
+	t.Run("ConnectionDetails", func(t *testing.T) {
+		var out bytes.Buffer
+		writeConnectionDetails(&out, before)
+		assert.Equal(t, out.String(), "dbname: zoo\npassword: old\n")
+	})
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeWindow is the period of interest of a support export. A zero since or
+// until leaves that side of the window open.
+type timeWindow struct {
+	since, until time.Time
+}
+
+// newTimeWindow returns the window described by the --since, --since-time
+// and --until flags. The since duration is relative to now.
+func newTimeWindow(now time.Time, since time.Duration, sinceTime, until string,
+) (timeWindow, error) {
+	var window timeWindow
+
+	if since < 0 {
+		return window, fmt.Errorf("invalid since %s: must be positive", since)
+	}
+	if since > 0 && sinceTime != "" {
+		return window, fmt.Errorf("only one of since or since-time may be used")
+	}
+	if since > 0 {
+		window.since = now.Add(-since)
+	}
+	if sinceTime != "" {
+		t, err := time.Parse(time.RFC3339, sinceTime)
+		if err != nil {
+			return window, fmt.Errorf("invalid since-time %q: expected RFC3339", sinceTime)
+		}
+		window.since = t
+	}
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return window, fmt.Errorf("invalid until %q: expected RFC3339", until)
+		}
+		window.until = t
+	}
+
+	if !window.since.IsZero() && !window.until.IsZero() && !window.since.Before(window.until) {
+		return window, fmt.Errorf("invalid time window: since must be before until")
+	}
+	return window, nil
+}
+
+// isZero returns true when the window is open on both sides.
+func (w timeWindow) isZero() bool {
+	return w.since.IsZero() && w.until.IsZero()
+}
+
+// overlaps returns true when something that happened from start to end
+// happened at least partly in the window.
+func (w timeWindow) overlaps(start, end time.Time) bool {
+	return (w.since.IsZero() || !end.Before(w.since)) &&
+		(w.until.IsZero() || !start.After(w.until))
+}
+
+// String returns the window in RFC3339 with "*" for an open side.
+func (w timeWindow) String() string {
+	format := func(t time.Time) string {
+		if t.IsZero() {
+			return "*"
+		}
+		return t.Format(time.RFC3339)
+	}
+	return format(w.since) + " - " + format(w.until)
+}
+
+// pgLogFile is a Postgres log file and when it was last modified.
+type pgLogFile struct {
+	path     string
+	modified time.Time
+}
+
+// parsePGLogFiles parses the output of [Executor.listPGLogFiles].
+func parsePGLogFiles(stdout string) ([]pgLogFile, error) {
+	var files []pgLogFile
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		if line == "" {
+			continue
+		}
+		seconds, path, found := strings.Cut(line, " ")
+		unix, err := strconv.ParseInt(seconds, 10, 64)
+		if !found || err != nil {
+			return nil, fmt.Errorf("unexpected log file listing %q", line)
+		}
+		files = append(files, pgLogFile{path: path, modified: time.Unix(unix, 0)})
+	}
+	return files, nil
+}
+
+// selectPGLogFiles returns the paths of the log files written during window,
+// newest first. A log file is assumed to start when the one before it was last
+// modified. When window is open on both sides, it returns the paths of the
+// count most recently modified files.
+func selectPGLogFiles(files []pgLogFile, count int, window timeWindow) []string {
+	sorted := append([]pgLogFile{}, files...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].modified.After(sorted[j].modified)
+	})
+
+	var paths []string
+	for i, file := range sorted {
+		if window.isZero() {
+			if len(paths) == count {
+				break
+			}
+			paths = append(paths, file.path)
+			continue
+		}
+
+		var start time.Time
+		if i+1 < len(sorted) {
+			start = sorted[i+1].modified
+		}
+		if window.overlaps(start, file.modified) {
+			paths = append(paths, file.path)
+		}
+	}
+	return paths
+}
+
+// errLogWindowEnd is returned by an untilWriter once it sees a line written
+// after the end of its window.
+var errLogWindowEnd = errors.New("end of time window")
+
+// untilWriter passes on the lines of a log, requested with timestamps, that
+// were written before until. It removes the timestamps as it goes. Lines are
+// in the order they were written, so it returns errLogWindowEnd at the first
+// line after until. It must be closed to pass on the last line.
+type untilWriter struct {
+	w       io.Writer
+	until   time.Time
+	pending []byte
+}
+
+// Write implements io.Writer.
+func (uw *untilWriter) Write(p []byte) (int, error) {
+	uw.pending = append(uw.pending, p...)
+
+	end := bytes.LastIndexByte(uw.pending, '\n') + 1
+	lines := uw.pending[:end]
+	for len(lines) > 0 {
+		i := bytes.IndexByte(lines, '\n') + 1
+		if err := uw.line(lines[:i]); err != nil {
+			return 0, err
+		}
+		lines = lines[i:]
+	}
+
+	uw.pending = append(uw.pending[:0], uw.pending[end:]...)
+	return len(p), nil
+}
+
+// Close passes on the last line, if any. It does not close the underlying
+// writer.
+func (uw *untilWriter) Close() error {
+	if len(uw.pending) == 0 {
+		return nil
+	}
+	err := uw.line(uw.pending)
+	uw.pending = nil
+	return err
+}
+
+// line passes on one line without its timestamp.
+func (uw *untilWriter) line(line []byte) error {
+	stamp, rest, found := bytes.Cut(line, []byte(" "))
+	if t, err := time.Parse(time.RFC3339Nano, string(stamp)); found && err == nil {
+		if t.After(uw.until) {
+			return errLogWindowEnd
+		}
+		line = rest
+	}
+	_, err := uw.w.Write(line)
+	return err
+}
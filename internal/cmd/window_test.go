@@ -0,0 +1,155 @@
+// Copyright 2021 - 2023 Crunchy Data Solutions, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNewTimeWindow(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Open", func(t *testing.T) {
+		window, err := newTimeWindow(now, 0, "", "")
+		assert.NilError(t, err)
+		assert.Assert(t, window.isZero())
+		assert.Equal(t, window.String(), "* - *")
+	})
+
+	t.Run("Since", func(t *testing.T) {
+		window, err := newTimeWindow(now, 2*time.Hour, "", "")
+		assert.NilError(t, err)
+		assert.Equal(t, window.since, now.Add(-2*time.Hour))
+		assert.Assert(t, window.until.IsZero())
+	})
+
+	t.Run("SinceTimeUntil", func(t *testing.T) {
+		window, err := newTimeWindow(now, 0, "2023-01-01T10:00:00Z", "2023-01-01T11:00:00Z")
+		assert.NilError(t, err)
+		assert.Equal(t, window.String(), "2023-01-01T10:00:00Z - 2023-01-01T11:00:00Z")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := newTimeWindow(now, time.Hour, "2023-01-01T10:00:00Z", "")
+		assert.ErrorContains(t, err, "only one of since or since-time")
+
+		_, err = newTimeWindow(now, -time.Hour, "", "")
+		assert.ErrorContains(t, err, "must be positive")
+
+		_, err = newTimeWindow(now, 0, "yesterday", "")
+		assert.ErrorContains(t, err, `invalid since-time "yesterday"`)
+
+		_, err = newTimeWindow(now, 0, "", "2023-01-01")
+		assert.ErrorContains(t, err, `invalid until "2023-01-01"`)
+
+		_, err = newTimeWindow(now, 0, "2023-01-01T11:00:00Z", "2023-01-01T10:00:00Z")
+		assert.ErrorContains(t, err, "since must be before until")
+	})
+}
+
+func TestTimeWindowOverlaps(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2023, 1, 1, hour, 0, 0, 0, time.UTC) }
+	window := timeWindow{since: at(10), until: at(12)}
+
+	assert.Assert(t, window.overlaps(at(9), at(10)))
+	assert.Assert(t, window.overlaps(at(11), at(11)))
+	assert.Assert(t, window.overlaps(at(9), at(13)))
+	assert.Assert(t, window.overlaps(at(12), at(13)))
+	assert.Assert(t, !window.overlaps(at(8), at(9)))
+	assert.Assert(t, !window.overlaps(at(13), at(14)))
+
+	assert.Assert(t, timeWindow{}.overlaps(at(1), at(2)))
+	assert.Assert(t, timeWindow{since: at(10)}.overlaps(at(20), at(21)))
+	assert.Assert(t, !timeWindow{until: at(10)}.overlaps(at(20), at(21)))
+}
+
+func TestSelectPGLogFiles(t *testing.T) {
+	files, err := parsePGLogFiles(strings.Join([]string{
+		"1672567200 pgdata/pg14/log/postgresql-Sun.log", // 2023-01-01T10:00:00Z
+		"1672574400 pgdata/pg14/log/postgresql-Mon.log", // 2023-01-01T12:00:00Z
+		"1672560000 pgdata/pg14/log/postgresql-Sat.log", // 2023-01-01T08:00:00Z
+		"",
+	}, "\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 3)
+
+	t.Run("Count", func(t *testing.T) {
+		assert.DeepEqual(t, selectPGLogFiles(files, 2, timeWindow{}), []string{
+			"pgdata/pg14/log/postgresql-Mon.log",
+			"pgdata/pg14/log/postgresql-Sun.log",
+		})
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		at := func(hour, minute int) time.Time {
+			return time.Date(2023, 1, 1, hour, minute, 0, 0, time.UTC)
+		}
+
+		// Sun.log was written from 08:00 to 10:00.
+		assert.DeepEqual(t, selectPGLogFiles(files, 1,
+			timeWindow{since: at(9, 0), until: at(9, 30)}), []string{
+			"pgdata/pg14/log/postgresql-Sun.log",
+		})
+		assert.DeepEqual(t, selectPGLogFiles(files, 1,
+			timeWindow{since: at(9, 0)}), []string{
+			"pgdata/pg14/log/postgresql-Mon.log",
+			"pgdata/pg14/log/postgresql-Sun.log",
+		})
+		assert.DeepEqual(t, selectPGLogFiles(files, 1,
+			timeWindow{until: at(7, 0)}), []string{
+			"pgdata/pg14/log/postgresql-Sat.log",
+		})
+		assert.Equal(t, len(selectPGLogFiles(files, 1,
+			timeWindow{since: at(13, 0)})), 0)
+	})
+
+	t.Run("Unexpected", func(t *testing.T) {
+		_, err := parsePGLogFiles("pgdata/pg14/log/postgresql-Sun.log")
+		assert.ErrorContains(t, err, "unexpected log file listing")
+	})
+}
+
+func TestUntilWriter(t *testing.T) {
+	var out strings.Builder
+	uw := &untilWriter{w: &out, until: time.Date(2023, 1, 1, 11, 0, 0, 0, time.UTC)}
+
+	_, err := io.WriteString(uw, "2023-01-01T10:00:00.123456789Z first line\n2023-01-01T10:30")
+	assert.NilError(t, err)
+	_, err = io.WriteString(uw, ":00Z second line\nno timestamp\n")
+	assert.NilError(t, err)
+
+	_, err = io.WriteString(uw, "2023-01-01T11:00:01Z too late\n2023-01-01T10:59:00Z never\n")
+	assert.Assert(t, errors.Is(err, errLogWindowEnd))
+
+	assert.Equal(t, out.String(), "first line\nsecond line\nno timestamp\n")
+
+	t.Run("Close", func(t *testing.T) {
+		var out strings.Builder
+		uw := &untilWriter{w: &out, until: time.Date(2023, 1, 1, 11, 0, 0, 0, time.UTC)}
+
+		_, err := io.WriteString(uw, "2023-01-01T10:00:00Z no newline")
+		assert.NilError(t, err)
+		assert.Equal(t, out.String(), "")
+
+		assert.NilError(t, uw.Close())
+		assert.Equal(t, out.String(), "no newline")
+	})
+}
@@ -42,27 +42,71 @@ func NewPodExecutor(config *rest.Config) (podExecutor, error) {
 		namespace, pod, container string,
 		stdin io.Reader, stdout, stderr io.Writer, command ...string,
 	) error {
-		request := client.RESTClient().Post().
-			Resource("pods").SubResource("exec").
-			Namespace(namespace).Name(pod).
-			VersionedParams(&corev1.PodExecOptions{
-				Container: container,
-				Command:   command,
-				Stdin:     stdin != nil,
-				Stdout:    stdout != nil,
-				Stderr:    stderr != nil,
-			}, scheme.ParameterCodec)
+		return streamPodExec(config, client, namespace, pod, &corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    stderr != nil,
+		}, remotecommand.StreamOptions{
+			Stdin:  stdin,
+			Stdout: stdout,
+			Stderr: stderr,
+		})
+	}, err
+}
+
+// podTerminalExecutor runs command on container in pod in namespace with a
+// terminal (TTY) attached. The terminal combines stdout and stderr, and sizes
+// sends changes to its size.
+type podTerminalExecutor func(
+	namespace, pod, container string,
+	stdin io.Reader, stdout io.Writer, sizes remotecommand.TerminalSizeQueue,
+	command ...string,
+) error
 
-		exec, err := remotecommand.NewSPDYExecutor(config, "POST", request.URL())
+// NewPodTerminalExecutor returns an executor function like [NewPodExecutor]
+// that attaches a terminal to the command.
+// The RBAC settings required for this are "resources=pods/exec,verbs=create"
+func NewPodTerminalExecutor(config *rest.Config) (podTerminalExecutor, error) {
 
-		if err == nil {
-			err = exec.Stream(remotecommand.StreamOptions{
-				Stdin:  stdin,
-				Stdout: stdout,
-				Stderr: stderr,
-			})
-		}
+	client, err := clientv1.NewForConfig(config)
 
-		return err
+	return func(
+		namespace, pod, container string,
+		stdin io.Reader, stdout io.Writer, sizes remotecommand.TerminalSizeQueue,
+		command ...string,
+	) error {
+		return streamPodExec(config, client, namespace, pod, &corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			TTY:       true,
+		}, remotecommand.StreamOptions{
+			Stdin:             stdin,
+			Stdout:            stdout,
+			Tty:               true,
+			TerminalSizeQueue: sizes,
+		})
 	}, err
 }
+
+// streamPodExec runs the command in options and attaches streams to it.
+func streamPodExec(
+	config *rest.Config, client *clientv1.CoreV1Client, namespace, pod string,
+	options *corev1.PodExecOptions, streams remotecommand.StreamOptions,
+) error {
+	request := client.RESTClient().Post().
+		Resource("pods").SubResource("exec").
+		Namespace(namespace).Name(pod).
+		VersionedParams(options, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(config, "POST", request.URL())
+
+	if err == nil {
+		err = exec.Stream(streams)
+	}
+
+	return err
+}
@@ -29,6 +29,21 @@ const (
 	// LabelRole is used to identify object roles.
 	LabelRole = labelPrefix + "role"
 
+	// LabelInstance is used to identify the Pods and StatefulSet of one
+	// instance, named after that StatefulSet.
+	LabelInstance = labelPrefix + "instance"
+
+	// LabelInstanceSet is used to identify the instances of one instance set.
+	LabelInstanceSet = labelPrefix + "instance-set"
+
+	// LabelPostgresUser is used to identify the Secret of one user defined in
+	// the spec of a PostgresCluster.
+	LabelPostgresUser = labelPrefix + "pguser"
+
+	// LabelControlPlane is used to identify the objects of the Postgres
+	// operator itself, such as its Deployment.
+	LabelControlPlane = labelPrefix + "control-plane"
+
 	// LabelMonitoring is used to identify monitoring Pods
 	LabelMonitoring = "app.kubernetes.io/name=postgres-operator-monitoring"
 )
@@ -46,6 +61,16 @@ const (
 	// RolePatroniLeader is the LabelRole that Patroni sets on the Pod that is
 	// currently the leader.
 	RolePatroniLeader = "master"
+
+	// RolePatroniReplica is the LabelRole that Patroni sets on the Pods of
+	// instances that are replicas.
+	RolePatroniReplica = "replica"
+
+	// RolePGBouncer is the LabelRole applied to PgBouncer objects.
+	RolePGBouncer = "pgbouncer"
+
+	// RolePostgresUser is the LabelRole applied to Secrets of PostgreSQL users.
+	RolePostgresUser = "pguser"
 )
 
 const (
@@ -54,11 +79,54 @@ const (
 	// ContainerDatabase is the name of the container running PostgreSQL and
 	// supporting tools: Patroni, pgBackRest, etc.
 	ContainerDatabase = "database"
+
+	// ContainerExporter is the name of the container running the Postgres
+	// exporter of pgMonitor.
+	ContainerExporter = "exporter"
+
+	// ContainerPGBouncer is the name of the container running PgBouncer.
+	ContainerPGBouncer = "pgbouncer"
 )
 
+// InstanceLabels provides labels for every instance of a PostgreSQL cluster
+func InstanceLabels(clusterName string) string {
+	return LabelCluster + "=" + clusterName + "," +
+		LabelData + "=" + DataPostgres
+}
+
 // PrimaryInstanceLabels provides labels for a PostgreSQL cluster primary instance
 func PrimaryInstanceLabels(clusterName string) string {
 	return LabelCluster + "=" + clusterName + "," +
 		LabelData + "=" + DataPostgres + "," +
 		LabelRole + "=" + RolePatroniLeader
 }
+
+// InstancePodLabels provides labels for the Pod of one instance of a
+// PostgreSQL cluster
+func InstancePodLabels(clusterName, instanceName string) string {
+	return LabelCluster + "=" + clusterName + "," +
+		LabelData + "=" + DataPostgres + "," +
+		LabelInstance + "=" + instanceName
+}
+
+// ReplicaInstanceLabels provides labels for the replica instances of a
+// PostgreSQL cluster
+func ReplicaInstanceLabels(clusterName string) string {
+	return LabelCluster + "=" + clusterName + "," +
+		LabelData + "=" + DataPostgres + "," +
+		LabelRole + "=" + RolePatroniReplica
+}
+
+// PostgresUserSecretLabels provides labels for the Secrets of every user of a
+// PostgreSQL cluster
+func PostgresUserSecretLabels(clusterName string) string {
+	return LabelCluster + "=" + clusterName + "," +
+		LabelRole + "=" + RolePostgresUser
+}
+
+// PGBouncerLabels provides labels for the PgBouncer objects of a PostgreSQL
+// cluster
+func PGBouncerLabels(clusterName string) string {
+	return LabelCluster + "=" + clusterName + "," +
+		LabelRole + "=" + RolePGBouncer
+}
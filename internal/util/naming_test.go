@@ -27,3 +27,40 @@ func TestPrimaryInstanceLabels(t *testing.T) {
 			"postgres-operator.crunchydata.com/data=postgres,"+
 			"postgres-operator.crunchydata.com/role=master")
 }
+
+func TestInstanceLabels(t *testing.T) {
+
+	assert.Equal(t, InstanceLabels("testcluster1"),
+		"postgres-operator.crunchydata.com/cluster=testcluster1,"+
+			"postgres-operator.crunchydata.com/data=postgres")
+}
+
+func TestInstancePodLabels(t *testing.T) {
+
+	assert.Equal(t, InstancePodLabels("testcluster1", "testcluster1-instance1-abcd"),
+		"postgres-operator.crunchydata.com/cluster=testcluster1,"+
+			"postgres-operator.crunchydata.com/data=postgres,"+
+			"postgres-operator.crunchydata.com/instance=testcluster1-instance1-abcd")
+}
+
+func TestReplicaInstanceLabels(t *testing.T) {
+
+	assert.Equal(t, ReplicaInstanceLabels("testcluster1"),
+		"postgres-operator.crunchydata.com/cluster=testcluster1,"+
+			"postgres-operator.crunchydata.com/data=postgres,"+
+			"postgres-operator.crunchydata.com/role=replica")
+}
+
+func TestPostgresUserSecretLabels(t *testing.T) {
+
+	assert.Equal(t, PostgresUserSecretLabels("testcluster1"),
+		"postgres-operator.crunchydata.com/cluster=testcluster1,"+
+			"postgres-operator.crunchydata.com/role=pguser")
+}
+
+func TestPGBouncerLabels(t *testing.T) {
+
+	assert.Equal(t, PGBouncerLabels("testcluster1"),
+		"postgres-operator.crunchydata.com/cluster=testcluster1,"+
+			"postgres-operator.crunchydata.com/role=pgbouncer")
+}